@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// a paragraph-ish chunk of a page: everything between two blank lines,
+// or a whole code block
+type block struct {
+	kind string   // "text", "heading", "list", "quote", "image", "code", "shortcode", "comment" or "rule"
+	line int      // where it starts, for people
+	segs []string // what doXlate would send to the API, in order
+	size int      // characters, to compare lengths across languages
+}
+
+// does anything in here get translated?
+func (b *block) translatable() bool {
+	return len(b.segs) > 0
+}
+
+// the text the API would see for an image line, the alt text
+func imageAlt(ln string) (string, bool) {
+	bar := strings.Split(ln, "]")
+	desc := strings.Split(bar[0], "[")
+	if len(bar) < 2 || len(desc) < 2 {
+		return "", false
+	}
+	return desc[1], true
+}
+
+func blockKind(ln string) string {
+	t := strings.TrimSpace(ln)
+	switch {
+	case strings.HasPrefix(t, "#"):
+		return "heading"
+	case strings.HasPrefix(t, ">"):
+		return "quote"
+	case strings.HasPrefix(ln, "!"):
+		return "image"
+	case listItem.MatchString(ln):
+		return "list"
+	}
+	return "text"
+}
+
+// split a page up the same way doXlate walks it, so what comes out is
+// exactly what would have been sent for translation. The title and
+// description come back separately.
+func pageBlocks(src []byte) (map[string]string, []*block) {
+	src = bytes.TrimPrefix(src, bom)
+	fields := map[string]string{}
+	var blocks []*block
+	var cur *block
+	end := func() {
+		if cur != nil {
+			blocks = append(blocks, cur)
+			cur = nil
+		}
+	}
+	start := func(kind string, lineNo int) {
+		if cur == nil {
+			cur = &block{kind: kind, line: lineNo}
+		}
+	}
+	head := false
+	code := false
+	var fc fence
+	indented := false
+	inList := false
+	prevBlank := true
+	comment := false
+	commentTr := false
+	scBody := ""
+	lineNo := 0
+	scanner := bufio.NewScanner(bytes.NewReader(src))
+	for scanner.Scan() {
+		ln := scanner.Text()
+		lineNo++
+		blank := strings.TrimSpace(ln) == ""
+		if (lineNo == 1 && strings.TrimRight(ln, " \r") == "---") || (head && isFrontMatterEnd(ln)) {
+			head = !head
+			continue
+		}
+		if head {
+			kv := strings.SplitN(ln, ":", 2)
+			if len(kv) == 2 && (kv[0] == "title" || kv[0] == "description") {
+				fields[kv[0]] = kv[1]
+			}
+			continue
+		}
+		if code {
+			cur.size += utf8.RuneCountInString(ln)
+			if fc.closes(ln) {
+				code = false
+				end()
+			}
+			continue
+		}
+		if isIndentedCode(ln) && (indented || (prevBlank && !inList)) {
+			if !indented {
+				end()
+			}
+			start("code", lineNo)
+			cur.size += utf8.RuneCountInString(ln)
+			indented = true
+			prevBlank = false
+			continue
+		}
+		if !blank {
+			if indented {
+				end()
+			}
+			indented = false
+			if listItem.MatchString(ln) {
+				inList = true
+			} else if !isIndentedCode(ln) {
+				inList = false
+			}
+		}
+		prevBlank = blank
+		if f, ok := openFence(ln); ok {
+			end()
+			start("code", lineNo)
+			cur.size += utf8.RuneCountInString(ln)
+			fc = f
+			code = true
+			continue
+		}
+		if !comment && opensComment(ln) {
+			end()
+			start("comment", lineNo)
+			comment = true
+			commentTr = translatableComment(ln)
+		}
+		if comment {
+			comment = !strings.Contains(ln, "-->")
+			if _, text, _ := splitComment(ln); commentTr && text != "" {
+				cur.segs = append(cur.segs, text)
+			}
+			cur.size += utf8.RuneCountInString(ln)
+			if !comment {
+				end()
+			}
+			continue
+		}
+		if blank {
+			end()
+			continue
+		}
+		if scBody != "" {
+			start("shortcode", lineNo)
+			cur.size += utf8.RuneCountInString(ln)
+			if closesShortcode(ln, scBody) {
+				scBody = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(ln, "{{") {
+			start("shortcode", lineNo)
+			cur.size += utf8.RuneCountInString(ln)
+			_, scBody = shortcodeLine(ln, func(t string) string {
+				cur.segs = append(cur.segs, t)
+				return t
+			})
+			continue
+		}
+		if ln == "---" {
+			end()
+			blocks = append(blocks, &block{kind: "rule", line: lineNo})
+			continue
+		}
+		start(blockKind(ln), lineNo)
+		if strings.HasPrefix(ln, "!") {
+			if alt, ok := imageAlt(ln); ok {
+				cur.segs = append(cur.segs, alt)
+				cur.size += utf8.RuneCountInString(alt)
+				translateImageTitle(strings.Split(ln, "]")[1], func(t string) string {
+					cur.segs = append(cur.segs, t)
+					return t
+				})
+			}
+			continue
+		}
+		if start, end, ok := captionText(ln); ok {
+			cur.segs = append(cur.segs, ln[start:end])
+			cur.size += utf8.RuneCountInString(ln)
+			continue
+		}
+		if summaryDivider.MatchString(ln) { // translated either side of it
+			for _, p := range summaryDivider.Split(ln, -1) {
+				if t := strings.TrimSpace(p); t != "" {
+					cur.segs = append(cur.segs, t)
+				}
+			}
+		} else {
+			cur.segs = append(cur.segs, ln)
+		}
+		cur.size += utf8.RuneCountInString(ln)
+	}
+	end()
+	checkError(scanner.Err())
+	return fields, blocks
+}
+
+// a source block and whatever it lines up with in the translation,
+// either one can be nil if the other side has nothing for it
+type blockPair struct {
+	src, dst *block
+}
+
+// leaving a block out costs this much, pairing two costs how different
+// their lengths are
+const gapCost = 1.5
+
+func matchCost(a *block, b *block) float64 {
+	if a.kind != b.kind {
+		return math.Inf(1)
+	}
+	// translations run longer or shorter than the source, but not wildly
+	return math.Abs(math.Log(float64(a.size+10) / float64(b.size+10)))
+}
+
+// line up the blocks of a page and its translation. Paragraphs that were
+// added, dropped or restructured by hand end up paired with nil.
+func alignBlocks(src []*block, dst []*block) []blockPair {
+	n, m := len(src), len(dst)
+	cost := make([][]float64, n+1)
+	for i := range cost {
+		cost[i] = make([]float64, m+1)
+	}
+	for i := 1; i <= n; i++ {
+		cost[i][0] = float64(i) * gapCost
+	}
+	for j := 1; j <= m; j++ {
+		cost[0][j] = float64(j) * gapCost
+	}
+	for i := 1; i <= n; i++ {
+		for j := 1; j <= m; j++ {
+			best := cost[i-1][j-1] + matchCost(src[i-1], dst[j-1])
+			best = math.Min(best, cost[i-1][j]+gapCost)
+			best = math.Min(best, cost[i][j-1]+gapCost)
+			cost[i][j] = best
+		}
+	}
+	// walk back from the end to find how we got there
+	var pairs []blockPair
+	for i, j := n, m; i > 0 || j > 0; {
+		switch {
+		case i > 0 && j > 0 && cost[i][j] == cost[i-1][j-1]+matchCost(src[i-1], dst[j-1]):
+			pairs = append(pairs, blockPair{src[i-1], dst[j-1]})
+			i--
+			j--
+		case i > 0 && cost[i][j] == cost[i-1][j]+gapCost:
+			pairs = append(pairs, blockPair{src[i-1], nil})
+			i--
+		default:
+			pairs = append(pairs, blockPair{nil, dst[j-1]})
+			j--
+		}
+	}
+	for l, r := 0, len(pairs)-1; l < r; l, r = l+1, r-1 {
+		pairs[l], pairs[r] = pairs[r], pairs[l]
+	}
+	return pairs
+}
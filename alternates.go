@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// every language a page exists in, source first
+func pageLanguages() []string {
+	return append([]string{conf.Source}, conf.Languages...)
+}
+
+// the languages source is translated into, and it, after pages.languages
+// has had its say
+func sourceLanguages(source string) []string {
+	return append([]string{conf.Source}, languagesFor(source)...)
+}
+
+// front matter listing the other language versions of a page, for themes
+// that don't have a language switcher of their own
+func alternatesFrontMatter(lang string, source string, writeFile string) string {
+	key := conf.Alternates.Key
+	if key == "" {
+		key = "alternates"
+	}
+	base := filepath.Base(writeFile)
+	var b strings.Builder
+	b.WriteString(key + ":\n")
+	for _, l := range sourceLanguages(source) {
+		if l == lang {
+			continue
+		}
+		file := strings.Replace(base, "."+fileCode(lang)+".", "."+fileCode(l)+".", 1)
+		fmt.Fprintf(&b, "  - lang: %s\n    file: %s\n", l, file)
+	}
+	return b.String()
+}
+
+// the same thing as a shortcode at the bottom of the page, from the
+// alternates.template in the config
+func alternatesShortcode(lang string, source string) string {
+	var others []string
+	for _, l := range sourceLanguages(source) {
+		if l != lang {
+			others = append(others, l)
+		}
+	}
+	r := strings.NewReplacer("{lang}", lang, "{langs}", strings.Join(others, ","))
+	return r.Replace(conf.Alternates.Template)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+)
+
+// one thing that would be sent to the API
+type segment struct {
+	file  string
+	line  int
+	chars int
+	text  string
+}
+
+// segment sizes in characters, the upper end of each bucket
+var sizeBuckets = []int{50, 100, 200, 500, 1000, 2000}
+
+// translator analyze [--top 10] [dir]
+func analyzeCmd(args []string) {
+	flags := flag.NewFlagSet("analyze", flag.ExitOnError)
+	configFile := flags.String("config", defaultConfigFile, "config file")
+	flags.StringVar(&profile, "profile", profile, "config profile to use")
+	top := flags.Int("top", 10, "how many of the longest segments and biggest files to list")
+	flags.Parse(args)
+	conf = loadConfig(*configFile)
+	dir := "."
+	if flags.NArg() > 0 {
+		dir = flags.Arg(0)
+	}
+	var segs []segment
+	files := map[string]int{} // chars per file
+	for _, p := range sourcePages(conf.Source, dir) {
+		if skipReason(p) != "" {
+			continue
+		}
+		src, err := os.ReadFile(p)
+		checkError(err)
+		fields, blocks := pageBlocks(src)
+		for _, k := range []string{"title", "description"} {
+			if v, ok := fields[k]; ok {
+				segs = append(segs, segment{p, 0, utf8.RuneCountInString(v), v})
+			}
+		}
+		for _, b := range blocks {
+			for _, s := range b.segs {
+				segs = append(segs, segment{p, b.line, utf8.RuneCountInString(s), s})
+			}
+		}
+	}
+	if len(segs) == 0 {
+		fmt.Println("Nothing to translate")
+		return
+	}
+	total := 0
+	for _, s := range segs {
+		total += s.chars
+		files[s.file] += s.chars
+	}
+	sort.Slice(segs, func(i, j int) bool { return segs[i].chars > segs[j].chars })
+	pct := func(n int) int { return segs[len(segs)*(100-n)/100].chars }
+	fmt.Printf("%d pages, %d segments, %d characters, about $%.2f per language ($%.2f for all %d)\n",
+		len(files), len(segs), total, float64(total)*pricePerMillion/1000000,
+		float64(total*len(conf.Languages))*pricePerMillion/1000000, len(conf.Languages))
+	fmt.Printf("segment length: median %d, 95th percentile %d, longest %d\n\n", pct(50), pct(95), segs[0].chars)
+
+	counts := make([]int, len(sizeBuckets)+1)
+	chars := make([]int, len(sizeBuckets)+1)
+	for _, s := range segs {
+		i := sort.SearchInts(sizeBuckets, s.chars+1)
+		counts[i]++
+		chars[i] += s.chars
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "CHARACTERS\tSEGMENTS\tOF TOTAL CHARS\t")
+	for i, n := range counts {
+		label := fmt.Sprintf("%d+", sizeBuckets[len(sizeBuckets)-1])
+		if i < len(sizeBuckets) {
+			from := 0
+			if i > 0 {
+				from = sizeBuckets[i-1]
+			}
+			label = fmt.Sprintf("%d-%d", from, sizeBuckets[i]-1)
+		}
+		share := 100 * float64(chars[i]) / float64(total)
+		fmt.Fprintf(w, "%s\t%d\t%.1f%%\t%s\n", label, n, share, strings.Repeat("#", int(share/2+0.5)))
+	}
+	w.Flush()
+
+	fmt.Printf("\nLongest segments:\n")
+	w = tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for i := 0; i < *top && i < len(segs); i++ {
+		s := segs[i]
+		fmt.Fprintf(w, "%d\t%s:%d\t%s\n", s.chars, s.file, s.line, snippet(s.text, 60))
+	}
+	w.Flush()
+
+	var names []string
+	for f := range files {
+		names = append(names, f)
+	}
+	sort.Slice(names, func(i, j int) bool { return files[names[i]] > files[names[j]] })
+	fmt.Printf("\nBiggest pages:\n")
+	w = tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "CHARACTERS\tOF TOTAL\tRUNNING\tPAGE")
+	running := 0
+	for i := 0; i < *top && i < len(names); i++ {
+		n := files[names[i]]
+		running += n
+		fmt.Fprintf(w, "%d\t%.1f%%\t%.1f%%\t%s\n", n, 100*float64(n)/float64(total), 100*float64(running)/float64(total), names[i])
+	}
+	w.Flush()
+}
+
+// the start of a segment, on one line
+func snippet(text string, n int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	return string([]rune(text)[:n-1]) + "…"
+}
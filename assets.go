@@ -0,0 +1,67 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// front matter fields holding images, when assets.fields isn't set
+var defaultAssetFields = []string{"image", "og_image", "images", "featured_image"}
+
+func assetFields() []string {
+	if len(conf.Assets.Fields) > 0 {
+		return conf.Assets.Fields
+	}
+	return defaultAssetFields
+}
+
+// the first rewrite whose from starts the path, with {lang} filled in
+func remapAsset(path string, lang string) string {
+	for _, r := range conf.Assets.Rewrite {
+		if strings.HasPrefix(path, r.From) {
+			return strings.Replace(r.To, "{lang}", lang, -1) + path[len(r.From):]
+		}
+	}
+	return path
+}
+
+// a path, maybe quoted
+var assetValue = regexp.MustCompile(`^(\s*)(["']?)([^"'\[\]]+?)(["']?)(\s*)$`)
+
+func remapValue(v string, lang string) string {
+	m := assetValue.FindStringSubmatch(v)
+	if m == nil || m[3] == "" {
+		return v
+	}
+	return m[1] + m[2] + remapAsset(m[3], lang) + m[4] + m[5]
+}
+
+// a front matter line with one of the asset fields on it, key: value or
+// key: [a, b], or a "- path" under one. key is the field the lines that
+// follow belong to ("" if it isn't an asset field) so list items can be
+// done too.
+func assetLine(ln string, lang string, key string) (string, string, bool) {
+	if len(conf.Assets.Rewrite) == 0 {
+		return ln, "", false
+	}
+	if key != "" && strings.HasPrefix(strings.TrimSpace(ln), "- ") {
+		dash := strings.Index(ln, "- ")
+		return ln[:dash+2] + remapValue(ln[dash+2:], lang), key, true
+	}
+	colon := strings.Index(ln, ":")
+	if colon < 0 || strings.HasPrefix(ln, " ") || !isValueInList(ln[:colon], assetFields()) {
+		return ln, "", false
+	}
+	value := ln[colon+1:]
+	if open, end := strings.Index(value, "["), strings.LastIndex(value, "]"); open >= 0 && end > open {
+		items := strings.Split(value[open+1:end], ",")
+		for i, item := range items {
+			items[i] = remapValue(item, lang)
+		}
+		return ln[:colon+1] + value[:open+1] + strings.Join(items, ",") + value[end:], "", true
+	}
+	if strings.TrimSpace(value) == "" { // a list on the lines that follow
+		return ln, ln[:colon], true
+	}
+	return ln[:colon+1] + remapValue(value, lang), "", true
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	bigquery "google.golang.org/api/bigquery/v2"
+	"google.golang.org/api/option"
+)
+
+// audit.csv and audit.bigquery: a row for every call that goes to a
+// provider, when, who by, how much and for which page, so a Translate
+// project that several teams share can be charged back. Cache hits cost
+// nothing and aren't in it.
+
+type auditRow struct {
+	Time     time.Time
+	Run      string
+	Seq      int // the run's nth call, for BigQuery to spot a row sent twice
+	User     string
+	Profile  string
+	Provider string
+	From     string
+	To       string
+	Lang     string // who pays, a pivot leg is the final language's
+	Segments int
+	Chars    int
+	Where    string // file:line, or batch
+	Label    string // billing.labels
+}
+
+var auditColumns = []string{"time", "run", "user", "profile", "provider", "from", "to", "lang", "segments", "chars", "where", "label"}
+
+func (r auditRow) fields() []string {
+	return []string{
+		r.Time.UTC().Format(time.RFC3339),
+		r.Run,
+		r.User,
+		r.Profile,
+		r.Provider,
+		r.From,
+		r.To,
+		r.Lang,
+		strconv.Itoa(r.Segments),
+		strconv.Itoa(r.Chars),
+		r.Where,
+		r.Label,
+	}
+}
+
+var (
+	auditRows   []auditRow // waiting to go to BigQuery
+	auditSeq    int
+	auditWarned bool // a broken audit log is said once, not once a call
+	auditMu     sync.Mutex
+)
+
+func auditing() bool {
+	return conf.Audit.CSV != "" || conf.Audit.BigQuery != ""
+}
+
+// note a call to provider. Never stops a run, a log that can't be
+// written is a warning.
+func audit(provider string, from string, to string, lang string, texts []string, where string) {
+	if !auditing() {
+		return
+	}
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	auditSeq++
+	r := auditRow{
+		Time:     time.Now(),
+		Run:      runID,
+		Seq:      auditSeq,
+		User:     os.Getenv("USER"),
+		Profile:  profile,
+		Provider: provider,
+		From:     from,
+		To:       to,
+		Lang:     lang,
+		Segments: len(texts),
+		Where:    where,
+		Label:    billingLabel(where),
+	}
+	for _, t := range texts {
+		r.Chars += utf8.RuneCountInString(t)
+	}
+	if conf.Audit.CSV != "" {
+		auditWarn(appendAuditCSV(conf.Audit.CSV, r))
+	}
+	if conf.Audit.BigQuery != "" {
+		auditRows = append(auditRows, r)
+		if len(auditRows) >= 500 { // as many as BigQuery likes in one go
+			auditWarn(flushAudit())
+		}
+	}
+}
+
+func auditWarn(err error) {
+	if err != nil && !auditWarned {
+		fmt.Printf("warning: audit log: %v\n", err)
+		auditWarned = true
+	}
+}
+
+// one row, with the header first if the file's new
+func appendAuditCSV(path string, r auditRow) error {
+	_, err := os.Stat(path)
+	fresh := os.IsNotExist(err)
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	if fresh {
+		w.Write(auditColumns)
+	}
+	w.Write(r.fields())
+	w.Flush()
+	return w.Error()
+}
+
+// audit.bigquery is project.dataset.table, or dataset.table in the
+// translation project
+func bigQueryTable() (string, string, string, error) {
+	parts := strings.Split(conf.Audit.BigQuery, ".")
+	if len(parts) == 2 {
+		parts = append([]string{googleProject()}, parts...)
+	}
+	if len(parts) != 3 || parts[0] == "" {
+		return "", "", "", fmt.Errorf("audit.bigquery should be project.dataset.table, not %q", conf.Audit.BigQuery)
+	}
+	return parts[0], parts[1], parts[2], nil
+}
+
+// send what's waiting to BigQuery, with auditMu held
+func flushAudit() error {
+	if len(auditRows) == 0 {
+		return nil
+	}
+	rows := auditRows
+	auditRows = nil
+	project, dataset, table, err := bigQueryTable()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	svc, err := bigquery.NewService(ctx, option.WithCredentialsFile(conf.Translation.Credentials))
+	if err != nil {
+		return err
+	}
+	req := &bigquery.TableDataInsertAllRequest{}
+	for _, r := range rows {
+		row := map[string]bigquery.JsonValue{}
+		for i, v := range r.fields() {
+			row[auditColumns[i]] = v
+		}
+		row["segments"], row["chars"] = r.Segments, r.Chars
+		req.Rows = append(req.Rows, &bigquery.TableDataInsertAllRequestRows{
+			InsertId: fmt.Sprintf("%s-%d", r.Run, r.Seq),
+			Json:     row,
+		})
+	}
+	resp, err := svc.Tabledata.InsertAll(project, dataset, table, req).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("%d rows didn't get to BigQuery: %v", len(rows), err)
+	}
+	if len(resp.InsertErrors) > 0 && len(resp.InsertErrors[0].Errors) > 0 {
+		return fmt.Errorf("BigQuery turned down %d rows: %s", len(resp.InsertErrors), resp.InsertErrors[0].Errors[0].Message)
+	}
+	return nil
+}
+
+// at the end of the run, whatever's left
+func closeAudit() {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	auditWarn(flushAudit())
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Azure AI Translator (Translator Text API v3)
+type azureProvider struct {
+	endpoint string
+	key      string
+	region   string
+}
+
+func newAzureProvider() *azureProvider {
+	c := conf.Translation.Azure
+	p := &azureProvider{
+		endpoint: strings.TrimRight(c.Endpoint, "/"),
+		key:      c.Key,
+		region:   c.Region,
+	}
+	if p.endpoint == "" {
+		p.endpoint = "https://api.cognitive.microsofttranslator.com"
+	}
+	if p.key == "" {
+		keyEnv := c.KeyEnv
+		if keyEnv == "" {
+			keyEnv = "AZURE_TRANSLATOR_KEY"
+		}
+		p.key = os.Getenv(keyEnv)
+		if p.key == "" {
+			checkError(fmt.Errorf("the azure provider needs a subscription key in translation.azure.key or $%s", keyEnv))
+		}
+	}
+	return p
+}
+
+func (p *azureProvider) Name() string {
+	return "azure"
+}
+
+// call the API, in is sent as JSON and the answer decoded into out
+func (p *azureProvider) call(method string, path string, query url.Values, in interface{}, out interface{}) error {
+	query.Set("api-version", "3.0")
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, p.endpoint+path+"?"+query.Encode(), body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Ocp-Apim-Subscription-Key", p.key)
+	if p.region != "" { // needed for regional and multi-service resources
+		req.Header.Set("Ocp-Apim-Subscription-Region", p.region)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("azure: %s: %s", resp.Status, raw)
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("azure: %v", err)
+	}
+	return nil
+}
+
+func (p *azureProvider) Translate(texts []string, from string, to string) ([]string, error) {
+	var in []map[string]string
+	for _, t := range texts {
+		in = append(in, map[string]string{"Text": t})
+	}
+	var answer []struct {
+		Translations []struct {
+			Text string `json:"text"`
+		} `json:"translations"`
+	}
+	err := p.call("POST", "/translate", url.Values{"from": {apiCode(from)}, "to": {apiCode(to)}}, in, &answer)
+	if err != nil {
+		return nil, err
+	}
+	if len(answer) != len(texts) {
+		return nil, fmt.Errorf("azure: sent %d strings, got %d back", len(texts), len(answer))
+	}
+	var out []string
+	for _, a := range answer {
+		if len(a.Translations) == 0 {
+			return nil, fmt.Errorf("azure: no translation")
+		}
+		out = append(out, a.Translations[0].Text)
+	}
+	return out, nil
+}
+
+func (p *azureProvider) Languages() ([]string, error) {
+	var answer struct {
+		Translation map[string]json.RawMessage `json:"translation"`
+	}
+	if err := p.call("GET", "/languages", url.Values{"scope": {"translation"}}, nil, &answer); err != nil {
+		return nil, err
+	}
+	var codes []string
+	for code := range answer.Translation {
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
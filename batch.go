@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/option"
+	storage "google.golang.org/api/storage/v1"
+	translatev3 "google.golang.org/api/translate/v3"
+)
+
+// For the first translation of a big site, one segment per request is
+// mostly waiting. --batch works out every segment the run is going to
+// need, has Google translate them all in one batch job through Cloud
+// Storage, and puts the results in the cache. The run after that is then
+// almost all cache hits, with the masking, fixes and checks done the same
+// as always.
+
+// a provider that translates nothing and remembers what it was asked
+type collector struct {
+	texts map[string]map[string]bool // target language -> segments
+	mu    sync.Mutex                 // a page's segments can come all at once
+}
+
+func (c *collector) Name() string {
+	return "collect"
+}
+
+func (c *collector) Translate(texts []string, from string, to string) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if from == conf.Source { // pivots' second legs go one at a time later
+		if c.texts[to] == nil {
+			c.texts[to] = map[string]bool{}
+		}
+		for _, t := range texts {
+			c.texts[to][t] = true
+		}
+	}
+	return texts, nil
+}
+
+// go through everything translateDir is going to translate, without
+// translating it, and return the segments that aren't cached yet
+func collectSegments(from string, dir string) map[string][]string {
+	warmUp()
+	col := &collector{texts: map[string]map[string]bool{}}
+	saved, savedTranscript, savedRefine := current, transcript, conf.Refine.Sections
+	current, transcript, conf.Refine.Sections = col, nil, nil
+	for _, p := range sourcePages(from, dir) {
+		if skipReason(p) != "" {
+			continue
+		}
+		for _, lang := range languagesFor(p) {
+			toFile := targetFor(p, from, lang)
+			if exists(toFile) && !retranslate(p, toFile) {
+				continue
+			}
+			doXlate(from, lang, p, toFile)
+			dropPending(toFile)
+		}
+	}
+	current, transcript, conf.Refine.Sections = saved, savedTranscript, savedRefine
+	// none of that happened as far as the run is concerned
+	consistent = map[string]map[string]*reused{}
+	runStats = map[string]*langStats{}
+	c := loadCache()
+	segments := map[string][]string{}
+	for lang, texts := range col.texts {
+		for t := range texts {
+			if _, ok := c.getMasked(providerKey(provider(), lang), from, lang, t); ok || strings.Contains(t, "\n") {
+				continue // lines are how the batch keeps segments apart
+			}
+			segments[lang] = append(segments[lang], t)
+		}
+		sort.Strings(segments[lang])
+	}
+	return segments
+}
+
+// gs://bucket/some/path -> bucket, some/path
+func splitGCS(uri string) (string, string) {
+	uri = strings.TrimPrefix(uri, "gs://")
+	parts := strings.SplitN(uri, "/", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], strings.Trim(parts[1], "/")
+}
+
+// translate the segments every page in dir is going to need in one go,
+// straight into the cache
+func batchPrefetch(from string, dir string) {
+	if sampleSpec != "" {
+		checkError(fmt.Errorf("--batch would translate every page, not just the --sample"))
+	}
+	if cacheReadonly {
+		checkError(fmt.Errorf("--batch translates everything up front, it can't be used with --cache-readonly"))
+	}
+	if provider().Name() != "google" {
+		checkError(fmt.Errorf("--batch only works with the google provider, not %s", provider().Name()))
+	}
+	if conf.Translation.Batch.URI == "" || googleProject() == "" {
+		checkError(fmt.Errorf("--batch needs translation.batch.uri (gs://bucket/path) and translation.project_id"))
+	}
+	segments := collectSegments(from, dir)
+	var union []string
+	seen := map[string]bool{}
+	var langs []string
+	for lang, texts := range segments {
+		if len(texts) == 0 {
+			continue
+		}
+		langs = append(langs, lang)
+		for _, t := range texts {
+			if !seen[t] {
+				seen[t] = true
+				union = append(union, t)
+			}
+		}
+	}
+	if len(union) == 0 {
+		fmt.Println("Batch:\t\t nothing that isn't cached already")
+		return
+	}
+	sort.Strings(langs)
+	var codes []string // what the API calls them
+	for _, lang := range langs {
+		codes = append(codes, apiCode(lang))
+	}
+	ctx := context.Background()
+	opt := option.WithCredentialsFile(conf.Translation.Credentials)
+	gcs, err := storage.NewService(ctx, opt)
+	checkError(err)
+	tr, err := googleV3()
+	checkError(err)
+	bucket, prefix := splitGCS(conf.Translation.Batch.URI)
+	input := path.Join(prefix, runID, "input.txt")
+	output := path.Join(prefix, runID, "output") + "/"
+	fmt.Printf("Batch:\t\t %d segments into %s, uploading to gs://%s/%s\n", len(union), strings.Join(langs, ", "), bucket, input)
+	_, err = gcs.Objects.Insert(bucket, &storage.Object{Name: input}).Media(strings.NewReader(strings.Join(union, "\n") + "\n")).Context(ctx).Do()
+	checkError(err)
+	location := conf.Translation.Batch.Location
+	if location == "" {
+		location = "us-central1" // batch jobs don't run in global
+	}
+	req := &translatev3.BatchTranslateTextRequest{
+		SourceLanguageCode:  apiCode(from),
+		TargetLanguageCodes: codes,
+		InputConfigs: []*translatev3.InputConfig{{
+			GcsSource: &translatev3.GcsSource{InputUri: "gs://" + bucket + "/" + input},
+			MimeType:  "text/plain",
+		}},
+		OutputConfig: &translatev3.OutputConfig{
+			GcsDestination: &translatev3.GcsDestination{OutputUriPrefix: "gs://" + bucket + "/" + output},
+		},
+	}
+	parent, err := googleParent(location)
+	checkError(err)
+	if m := conf.Translation.Model; m != "" && m != "nmt" {
+		req.Models = map[string]string{}
+		for _, lang := range langs {
+			req.Models[apiCode(lang)] = parent + "/models/general/" + m
+		}
+	}
+	for _, lang := range langs {
+		if g := glossaryFor(from, lang); g != "" {
+			if req.Glossaries == nil {
+				req.Glossaries = map[string]translatev3.TranslateTextGlossaryConfig{}
+			}
+			req.Glossaries[apiCode(lang)] = translatev3.TranslateTextGlossaryConfig{Glossary: glossaryName(parent, g)}
+		}
+	}
+	op, err := tr.Projects.Locations.BatchTranslateText(parent, req).Context(ctx).Do()
+	checkError(err)
+	for !op.Done {
+		time.Sleep(10 * time.Second)
+		op, err = tr.Projects.Locations.Operations.Get(op.Name).Context(ctx).Do()
+		checkError(err)
+	}
+	if op.Error != nil {
+		checkError(fmt.Errorf("batch translation: %s", op.Error.Message))
+	}
+	fetchBatch(ctx, gcs, bucket, output, from, union)
+}
+
+func download(ctx context.Context, gcs *storage.Service, bucket string, name string) []byte {
+	resp, err := gcs.Objects.Get(bucket, name).Context(ctx).Download()
+	checkError(err)
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	checkError(err)
+	return b
+}
+
+// the job writes index.csv, a line per input file and language with
+// where its translation went. Each output has a line per line we sent.
+func fetchBatch(ctx context.Context, gcs *storage.Service, bucket string, output string, from string, sent []string) {
+	var index []string
+	checkError(gcs.Objects.List(bucket).Prefix(output).Pages(ctx, func(objs *storage.Objects) error {
+		for _, o := range objs.Items {
+			if path.Base(o.Name) == "index.csv" {
+				index = append(index, o.Name)
+			}
+		}
+		return nil
+	}))
+	if len(index) == 0 {
+		checkError(fmt.Errorf("batch translation: no index.csv under gs://%s/%s", bucket, output))
+	}
+	c := loadCache()
+	for _, name := range index {
+		rows, err := csv.NewReader(bytes.NewReader(download(ctx, gcs, bucket, name))).ReadAll()
+		checkError(err)
+		for _, row := range rows {
+			if len(row) < 3 || row[2] == "" {
+				continue // the language failed, the errors file has why
+			}
+			lang := siteLanguage(row[1])
+			_, outName := splitGCS(row[2])
+			lines := strings.Split(strings.TrimSuffix(string(download(ctx, gcs, bucket, outName)), "\n"), "\n")
+			if len(lines) != len(sent) {
+				fmt.Printf("warning: batch sent %d lines for %s and got %d back, they'll be translated one at a time\n", len(sent), lang, len(lines))
+				continue
+			}
+			for i, t := range sent {
+				c.put(providerKey(provider(), lang), from, lang, t, lines[i])
+				countSent(lang, t)
+				countLabel("", t) // the batch doesn't know which page it was from
+			}
+			audit("google batch", from, lang, lang, sent, "batch")
+			fmt.Printf("Batch:\t\t %d segments in %s\n", len(lines), lang)
+		}
+	}
+	checkError(c.save())
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// billing.labels: whose spend a section of the site is, like
+// "content/docs": "docs", so each team can see its share in the summary
+// and the audit log. The most specific section a page is in wins.
+func billingLabel(where string) string {
+	if len(conf.Billing.Labels) == 0 {
+		return ""
+	}
+	file := where // file:line, from xlAt
+	if i := strings.LastIndex(where, ":"); i >= 0 {
+		if _, err := strconv.Atoi(where[i+1:]); err == nil {
+			file = where[:i]
+		}
+	}
+	file = filepath.ToSlash(filepath.Clean(file))
+	label, longest := "", -1
+	for section, l := range conf.Billing.Labels {
+		s := filepath.ToSlash(filepath.Clean(section))
+		if ok, _ := filepath.Match(s, file); (ok || strings.HasPrefix(file, s+"/")) && len(s) > longest {
+			label, longest = l, len(s)
+		}
+	}
+	return label
+}
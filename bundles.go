@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// translations are written next to where they're going and only moved
+// into place once every language of the bundle is done, so a run that
+// dies never leaves a bundle half translated
+const pendingSuffix = ".translating"
+
+func pendingFile(target string) string {
+	return target + pendingSuffix
+}
+
+// the pending files this run has written and not moved into place, so a
+// run that stops can take them back out with it
+var pending = struct {
+	sync.Mutex
+	targets map[string]bool
+}{targets: map[string]bool{}}
+
+// the translation of target, written where it waits to be committed
+func writePending(target string, data []byte) error {
+	pending.Lock()
+	pending.targets[target] = true
+	pending.Unlock()
+	return files.WriteFile(pendingFile(target), data)
+}
+
+func commitTranslation(target string) {
+	backupTarget(target)
+	checkError(files.Rename(pendingFile(target), target))
+	pending.Lock()
+	delete(pending.targets, target)
+	pending.Unlock()
+}
+
+// throw away whatever's waiting for targets
+func dropPending(targets ...string) {
+	pending.Lock()
+	defer pending.Unlock()
+	for _, target := range targets {
+		if pending.targets[target] {
+			files.Remove(pendingFile(target))
+			delete(pending.targets, target)
+		}
+	}
+}
+
+// the run is ending on an error: nothing it hasn't committed stays
+func rollbackPending() {
+	pending.Lock()
+	var targets []string
+	for target := range pending.targets {
+		targets = append(targets, target)
+	}
+	pending.Unlock()
+	dropPending(targets...)
+}
+
+// whatever a run that died left behind
+func cleanPending(dir string) {
+	err := walkFiles(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.HasSuffix(path, pendingSuffix) {
+			fmt.Printf("Removing:\t %s (left over from a run that didn't finish)\n", path)
+			return files.Remove(path)
+		}
+		return nil
+	})
+	checkError(err)
+}
+
+// translate one page bundle into every language it's missing, all or
+// nothing
+func translateBundle(from string, fromFile string) {
+	name := strings.Split(filepath.Base(fromFile), ".")[0]
+	syncTranslationKey(from, fromFile)
+	var todo []string
+	for _, lang := range languagesFor(fromFile) {
+		toFile := targetFor(fromFile, from, lang)
+		if exists(toFile) && !retranslate(fromFile, toFile) {
+			if name != "_index" {
+				addReadingTime(fromFile)
+				addReadingTime(toFile)
+			}
+			countSkipped(lang)
+			continue
+		}
+		todo = append(todo, lang)
+	}
+	if len(todo) == 0 {
+		return
+	}
+	if reason := skipReason(fromFile); reason != "" {
+		fmt.Printf("Skipping:\t %s (%s)\n", fromFile, reason)
+		for _, lang := range todo {
+			countSkipped(lang)
+		}
+		return
+	}
+	if strictSkip(fromFile) {
+		for _, lang := range todo {
+			countSkipped(lang)
+		}
+		return
+	}
+	addReadingTime(fromFile) // get the reading time first.
+	warnLint(fromFile)
+	// with more than one worker the languages go at the same time too,
+	// their segments share the same slots as everyone else's
+	var wg sync.WaitGroup
+	status := make([]string, len(todo)) // what each one ended up as, see translatePage
+	for i, lang := range todo {
+		toFile := targetFor(fromFile, from, lang)
+		fmt.Printf("Translating:\t %s\nto: \t\t%s\n", fromFile, toFile)
+		checkError(files.MkdirAll(filepath.Dir(toFile))) // a new tree in the directory layout
+		state.mark(fromFile, toFile, lang, "failed")
+		if workerCount() == 1 {
+			status[i] = translatePage(from, lang, fromFile, toFile)
+			continue
+		}
+		wg.Add(1)
+		go func(i int, lang string, toFile string) {
+			defer wg.Done()
+			status[i] = translatePage(from, lang, fromFile, toFile)
+		}(i, lang, toFile)
+	}
+	wg.Wait()
+	for i, lang := range todo {
+		if status[i] != "" {
+			continue
+		}
+		// one of them was skipped, so none of them go in
+		for j, other := range todo {
+			toFile := targetFor(fromFile, from, other)
+			if status[j] != "" {
+				fmt.Printf("Rolling back:\t %s (%s failed, the bundle goes in all or nothing)\n", toFile, lang)
+				countSkipped(other)
+			}
+			dropPending(toFile)
+		}
+		return
+	}
+	for i, lang := range todo {
+		toFile := targetFor(fromFile, from, lang)
+		commitTranslation(toFile)
+		state.mark(fromFile, toFile, lang, status[i])
+		if status[i] == "done" {
+			countCreated(lang)
+		}
+	}
+}
+
+// load everything that's loaded lazily before there's more than one
+// goroutine that could want it
+func warmUp() {
+	providerChain()
+	loadCache()
+	getRemote()
+	loadCaptionPatterns()
+	loadProtectPatterns()
+	loadLiteralPatterns()
+	loadFilters()
+	loadPlugins()
+	getRefiner()
+	getLimiter()
+	getRateLimiter(provider())
+	for _, lang := range conf.Languages {
+		loadBlocklist(lang)
+	}
+}
+
+// every bundle under dir, conf.Workers of them at a time
+func translateDir(from string, dir string) {
+	cleanPending(dir)
+	warmUp()
+	workers := workerCount()
+	pages := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range pages {
+				translateBundle(from, p)
+			}
+		}()
+	}
+	for _, p := range samplePages(from, sourcePages(from, dir)) {
+		pages <- p
+	}
+	close(pages)
+	wg.Wait()
+}
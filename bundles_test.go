@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// a provider that can't do German
+type noGerman struct{}
+
+func (noGerman) Name() string {
+	return "no-german"
+}
+
+func (noGerman) Translate(texts []string, from string, to string) ([]string, error) {
+	if to == "de" {
+		return nil, errors.New("de isn't supported")
+	}
+	return markProvider{}.Translate(texts, from, to)
+}
+
+func TestBundleAllOrNothing(t *testing.T) {
+	m := memSite(t, map[string]string{
+		"content/a/index.en.md": "---\ntitle: Hi\n---\n\nBody.\n",
+	})
+	conf.Languages = []string{"fr", "de"}
+	conf.Failures.Then = "skip"
+	current = noGerman{}
+	translateBundle("en", "content/a/index.en.md")
+	for name := range m.dump() {
+		if strings.HasSuffix(name, pendingSuffix) || strings.HasPrefix(name, "content/a/index.fr") || strings.HasPrefix(name, "content/a/index.de") {
+			t.Errorf("%s is there, the bundle should have been rolled back", name)
+		}
+	}
+	if len(pending.targets) != 0 {
+		t.Errorf("still pending: %v", pending.targets)
+	}
+}
+
+func TestRollbackPending(t *testing.T) {
+	m := memSite(t, map[string]string{
+		"content/a/index.en.md": "Body.\n",
+	})
+	checkError(writePending("content/a/index.fr.md", []byte("Corps.\n")))
+	checkError(writePending("content/a/index.de.md", []byte("Körper.\n")))
+	commitTranslation("content/a/index.de.md")
+	rollbackPending()
+	got := m.dump()
+	if _, ok := got["content/a/index.fr.md"+pendingSuffix]; ok {
+		t.Errorf("the pending French page wasn't rolled back")
+	}
+	if got["content/a/index.de.md"] != "Körper.\n" {
+		t.Errorf("the committed German page went too: %v", got)
+	}
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// a translation we've already paid for
+type cacheEntry struct {
+	Source   string    `json:"source,omitempty"` // what was translated, for tm export
+	Text     string    `json:"text"`
+	From     string    `json:"from"`
+	Lang     string    `json:"lang"`
+	Provider string    `json:"provider"`
+	Created  time.Time `json:"created"`
+}
+
+// the translation cache, keyed by a hash of provider, languages and text.
+// Rewriting all of it after every page gets slow once it's big, so what a
+// page adds is appended to a journal next to it instead, and the journal's
+// folded back in (compacted) once, at the end of the run.
+type transCache struct {
+	Entries map[string]*cacheEntry `json:"entries"`
+	dirty   bool
+	fresh   []string   // keys that aren't in the file yet, only the journal
+	rewrite bool       // something's gone, so the journal won't do
+	journal bool       // there's a journal to fold in
+	mu      sync.Mutex // bundles are translated in parallel
+}
+
+// a line of the journal
+type journalEntry struct {
+	Key   string      `json:"key"`
+	Entry *cacheEntry `json:"entry"`
+}
+
+var tm *transCache
+
+func cachePath() string {
+	if conf.Cache.Path != "" {
+		return conf.Cache.Path
+	}
+	return filepath.Join(stateDir, "cache.json")
+}
+
+func journalPath() string {
+	return cachePath() + ".log"
+}
+
+func loadCache() *transCache {
+	if tm != nil {
+		return tm
+	}
+	tm = &transCache{Entries: map[string]*cacheEntry{}}
+	f, err := os.ReadFile(cachePath())
+	if os.IsNotExist(err) {
+		tm.replay()
+		return tm
+	}
+	checkError(err)
+	checkError(json.Unmarshal(f, tm))
+	if tm.Entries == nil {
+		tm.Entries = map[string]*cacheEntry{}
+	}
+	tm.replay()
+	return tm
+}
+
+// what was added since the cache was last written out in full. A line
+// that was being written when a run died is skipped.
+func (c *transCache) replay() {
+	f, err := os.ReadFile(journalPath())
+	if os.IsNotExist(err) {
+		return
+	}
+	checkError(err)
+	for i, ln := range strings.Split(string(f), "\n") {
+		if strings.TrimSpace(ln) == "" {
+			continue
+		}
+		var j journalEntry
+		if err := json.Unmarshal([]byte(ln), &j); err != nil || j.Entry == nil {
+			fmt.Printf("warning: %s:%d isn't a cached translation, skipping it\n", journalPath(), i+1)
+			continue
+		}
+		c.Entries[j.Key] = j.Entry
+	}
+	c.dirty, c.rewrite = true, true // so it's compacted at the next save
+}
+
+// key's been added or changed; called with c.mu held where it's shared
+func (c *transCache) added(key string) {
+	c.fresh = append(c.fresh, key)
+	c.dirty = true
+}
+
+// write what's changed to the journal. The error's returned rather than
+// checked: checkError saves the cache too, and c.mu is held here.
+func (c *transCache) save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty || cacheReadonly {
+		return nil
+	}
+	if c.rewrite {
+		return c.write()
+	}
+	if err := os.MkdirAll(filepath.Dir(cachePath()), 0755); err != nil {
+		return err
+	}
+	var out []byte
+	for _, key := range c.fresh {
+		ln, err := json.Marshal(journalEntry{key, c.Entries[key]})
+		if err != nil {
+			return err
+		}
+		out = append(append(out, ln...), '\n')
+	}
+	j, err := os.OpenFile(journalPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := j.Write(out); err != nil {
+		j.Close()
+		return err
+	}
+	if err := j.Close(); err != nil {
+		return err
+	}
+	c.fresh, c.dirty, c.journal = nil, false, true
+	return nil
+}
+
+// the whole cache written out, and the journal folded into it
+func (c *transCache) compact() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cacheReadonly || (!c.dirty && !c.rewrite && !c.journal) {
+		return nil
+	}
+	return c.write()
+}
+
+// the whole cache, to a temporary file that's moved over the old one, so
+// dying halfway through the write doesn't lose everything that's been
+// paid for; called with c.mu held
+func (c *transCache) write() error {
+	if err := os.MkdirAll(filepath.Dir(cachePath()), 0755); err != nil {
+		return err
+	}
+	f, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	tmp := cachePath() + ".tmp"
+	if err := os.WriteFile(tmp, f, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, cachePath()); err != nil {
+		return err
+	}
+	if err := os.Remove(journalPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	c.fresh, c.dirty, c.rewrite, c.journal = nil, false, false, false
+	return nil
+}
+
+// some providers translate the same text differently depending on their
+// settings (the LLM's style guides) so those go in the key too
+type variantProvider interface {
+	variant(lang string) string
+}
+
+func providerKey(p Provider, lang string) string {
+	if v, ok := p.(variantProvider); ok && v.variant(lang) != "" {
+		return p.Name() + ":" + v.variant(lang)
+	}
+	return p.Name()
+}
+
+func cacheKey(provider string, from string, to string, text string) string {
+	sum := sha256.Sum256([]byte(provider + "\x00" + from + "\x00" + to + "\x00" + text))
+	return hex.EncodeToString(sum[:])
+}
+
+// how long a cached translation is good for. The most specific setting
+// wins: "fr/google", then "fr", then "google", then "*". 0 is forever.
+// provider is the cache's, llm:<variant> and the like, and it's the name
+// before the : that's looked up, so the variants all go together.
+func maxAge(lang string, provider string) time.Duration {
+	provider = strings.SplitN(provider, ":", 2)[0]
+	for _, k := range []string{lang + "/" + provider, lang, provider, "*"} {
+		if days, ok := conf.Cache.MaxAgeDays[k]; ok {
+			return time.Duration(days) * 24 * time.Hour
+		}
+	}
+	return 0
+}
+
+func (e *cacheEntry) expired(now time.Time) bool {
+	if e.Provider == humanProvider { // people don't get worse with age
+		return false
+	}
+	age := maxAge(e.Lang, e.Provider)
+	return age > 0 && now.Sub(e.Created) > age
+}
+
+func (c *transCache) get(provider string, from string, to string, text string) (string, bool) {
+	key := cacheKey(provider, from, to, text)
+	c.mu.Lock()
+	e, ok := c.Entries[key]
+	c.mu.Unlock()
+	if ok && !e.expired(time.Now()) {
+		return e.Text, true
+	}
+	if r := getRemote(); r != nil { // read through to the shared cache
+		if e, ok := r.get(key); ok && !e.expired(time.Now()) {
+			c.mu.Lock()
+			c.Entries[key] = e
+			c.added(key)
+			c.mu.Unlock()
+			return e.Text, true
+		}
+	}
+	return "", false
+}
+
+func (c *transCache) put(provider string, from string, to string, text string, translated string) {
+	key := cacheKey(provider, from, to, text)
+	e := &cacheEntry{
+		Source:   text,
+		Text:     translated,
+		From:     from,
+		Lang:     to,
+		Provider: provider,
+		Created:  time.Now(),
+	}
+	c.mu.Lock()
+	c.Entries[key] = e
+	c.added(key)
+	c.mu.Unlock()
+	if r := getRemote(); r != nil {
+		r.put(key, e)
+	}
+}
+
+// translator cache stats|prune|clear
+func cacheCmd(args []string) {
+	if len(args) == 0 {
+		fmt.Println("usage: translate cache stats|prune [--older-than 90d]|clear [--human] [--config translator.json]")
+		os.Exit(1)
+	}
+	flags := flag.NewFlagSet("cache", flag.ExitOnError)
+	configFile := flags.String("config", defaultConfigFile, "config file")
+	flags.StringVar(&profile, "profile", profile, "config profile to use")
+	olderThan := flags.String("older-than", "", "prune: also what's older than this, like 90d or 720h")
+	human := flags.Bool("human", false, "clear: human translations too")
+	flags.Parse(args[1:])
+	conf = loadConfig(*configFile)
+	c := loadCache()
+	switch args[0] {
+	case "stats":
+		cacheStats(c)
+	case "prune":
+		now := time.Now()
+		var cutoff time.Time
+		if *olderThan != "" {
+			age, err := parseAge(*olderThan)
+			checkError(err)
+			cutoff = now.Add(-age)
+		}
+		n := c.remove(func(e *cacheEntry) bool {
+			// people don't get worse with age, here either
+			return e.expired(now) || (!cutoff.IsZero() && e.Provider != humanProvider && e.Created.Before(cutoff))
+		})
+		fmt.Printf("Pruned %d of %d cached translations\n", n, n+len(c.Entries))
+	case "clear":
+		n := c.remove(func(e *cacheEntry) bool {
+			return *human || e.Provider != humanProvider
+		})
+		fmt.Printf("Cleared %d cached translations, %d left\n", n, len(c.Entries))
+		if conf.Cache.Remote.URL != "" {
+			fmt.Println("The remote cache wasn't touched.")
+		}
+	default:
+		checkError(fmt.Errorf("unknown cache command %q", args[0]))
+	}
+}
+
+// drop the entries drop says to, and say how many went
+func (c *transCache) remove(drop func(e *cacheEntry) bool) int {
+	n := 0
+	for k, e := range c.Entries {
+		if drop(e) {
+			delete(c.Entries, k)
+			n++
+		}
+	}
+	c.dirty = n > 0
+	c.rewrite = c.rewrite || n > 0
+	checkError(c.save())
+	return n
+}
+
+// 90d, or anything time.ParseDuration takes
+func parseAge(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err == nil && days >= 0 {
+			return time.Duration(days) * 24 * time.Hour, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("--older-than %q should be like 90d or 720h", s)
+	}
+	return d, nil
+}
+
+func cacheStats(c *transCache) {
+	type bucket struct {
+		lang, provider   string
+		entries, expired int
+		oldest, newest   time.Time
+	}
+	now := time.Now()
+	buckets := map[string]*bucket{}
+	for _, e := range c.Entries {
+		k := e.Lang + "/" + e.Provider
+		b, ok := buckets[k]
+		if !ok {
+			b = &bucket{lang: e.Lang, provider: e.Provider, oldest: e.Created, newest: e.Created}
+			buckets[k] = b
+		}
+		b.entries++
+		if e.expired(now) {
+			b.expired++
+		}
+		if e.Created.Before(b.oldest) {
+			b.oldest = e.Created
+		}
+		if e.Created.After(b.newest) {
+			b.newest = e.Created
+		}
+	}
+	var keys []string
+	for k := range buckets {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fmt.Printf("%s: %d cached translations\n", cachePath(), len(c.Entries))
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "LANG\tPROVIDER\tENTRIES\tEXPIRED\tOLDEST\tNEWEST")
+	for _, k := range keys {
+		b := buckets[k]
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%s\n", b.lang, b.provider, b.entries, b.expired, b.oldest.Format("2006-01-02"), b.newest.Format("2006-01-02"))
+	}
+	w.Flush()
+	// and how much use it's been, from the run history
+	runs, hits, sent, saved := 0, 0, 0, 0
+	for _, r := range loadHistory() {
+		if r.Total == nil || r.Total.Sent+r.Total.CacheHits == 0 {
+			continue // nothing to go on, or from before these were counted
+		}
+		runs++
+		hits += r.Total.CacheHits + r.Total.Fuzzy
+		sent += r.Total.Sent
+		saved += r.Total.Saved
+	}
+	if runs == 0 {
+		return
+	}
+	fmt.Printf("\nOver the last %d runs, %.1f%% of segments came from the cache (%d of %d),\n", runs, 100*float64(hits)/float64(hits+sent), hits, hits+sent)
+	fmt.Printf("%d characters that weren't sent, about $%.2f saved\n", saved, float64(saved)*pricePerMillion/1000000)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaxAge(t *testing.T) {
+	conf = defaultConfig()
+	conf.Cache.MaxAgeDays = map[string]int{"*": 365, "llm": 90, "ja/google": 30}
+	day := 24 * time.Hour
+	for _, c := range []struct {
+		lang, provider string
+		want           time.Duration
+	}{
+		{"fr", "llm:3f2a9c", 90 * day},
+		{"fr", "llm", 90 * day},
+		{"ja", "google:glossary-1a2b", 30 * day},
+		{"ja", "google", 30 * day},
+		{"fr", "google:glossary-1a2b", 365 * day},
+		{"de", "azure", 365 * day},
+	} {
+		if got := maxAge(c.lang, c.provider); got != c.want {
+			t.Errorf("maxAge(%s, %s) = %v, want %v", c.lang, c.provider, got, c.want)
+		}
+	}
+}
+
+func TestSaveCache(t *testing.T) {
+	conf = defaultConfig()
+	dir := t.TempDir()
+	conf.Cache.Path = filepath.Join(dir, "cache.json")
+	defer func() { tm = nil }()
+	c := &transCache{Entries: map[string]*cacheEntry{}}
+	c.put("mark", "en", "fr", "Hi", "Salut")
+	if err := c.compact(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(conf.Cache.Path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("the temporary file is still there: %v", err)
+	}
+	full, _ := os.ReadFile(conf.Cache.Path)
+
+	// a page's worth goes in the journal, and the cache is left alone
+	c.put("mark", "en", "fr", "Bye", "Salut")
+	c.put("mark", "en", "fr", "Hi", "Bonjour")
+	if err := c.save(); err != nil {
+		t.Fatal(err)
+	}
+	if g, _ := os.ReadFile(conf.Cache.Path); string(g) != string(full) {
+		t.Errorf("the cache was rewritten for a page")
+	}
+	j, _ := os.ReadFile(journalPath())
+	if n := strings.Count(string(j), "\n"); n != 2 {
+		t.Errorf("%d lines in the journal, want 2:\n%s", n, j)
+	}
+
+	// a run that died halfway through a line
+	f, _ := os.OpenFile(journalPath(), os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(`{"key":"abc","entry":{"te`)
+	f.Close()
+	tm = nil
+	loaded := loadCache()
+	for text, want := range map[string]string{"Hi": "Bonjour", "Bye": "Salut"} {
+		if got, ok := loaded.get("mark", "en", "fr", text); !ok || got != want {
+			t.Errorf("%s: %q, %v, want %q", text, got, ok, want)
+		}
+	}
+	if err := loaded.compact(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(journalPath()); !os.IsNotExist(err) {
+		t.Errorf("the journal is still there after compacting: %v", err)
+	}
+	saved := &transCache{}
+	full, _ = os.ReadFile(conf.Cache.Path)
+	if err := json.Unmarshal(full, saved); err != nil || len(saved.Entries) != 2 {
+		t.Errorf("compacted %s, %v", full, err)
+	}
+
+	// the end of a run with nothing new since the last page
+	loaded.put("mark", "en", "fr", "No", "Non")
+	if err := loaded.save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.compact(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(journalPath()); !os.IsNotExist(err) {
+		t.Errorf("the journal wasn't folded in at the end of the run: %v", err)
+	}
+	full, _ = os.ReadFile(conf.Cache.Path)
+
+	// somewhere it can't be written: an error back, not a hang, and the
+	// old cache as it was
+	if err := os.Mkdir(conf.Cache.Path+".tmp", 0755); err != nil {
+		t.Fatal(err)
+	}
+	loaded.put("mark", "en", "fr", "Yes", "Oui")
+	loaded.rewrite = true
+	done := make(chan error)
+	go func() { done <- loaded.save() }()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Errorf("saving over a directory worked")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("save hung")
+	}
+	if g, _ := os.ReadFile(conf.Cache.Path); string(g) != string(full) {
+		t.Errorf("the cache changed: %s", g)
+	}
+	loaded.mu.Lock() // and let go of
+	loaded.mu.Unlock()
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// blockquotes, and the callouts (admonitions) written as them:
+//
+//	> [!note] Before you start
+//	> Back up the database.
+//
+// The > (or > > when they're nested) is taken off before a line goes to
+// the provider and put back on what comes back, and the [!note] that
+// says what kind of callout it is is never sent, only its title is.
+
+var (
+	quotePrefix = regexp.MustCompile(`^(?: {0,3}>[ \t]?)+`)
+	calloutType = regexp.MustCompile(`^\[![A-Za-z][\w-]*\][+-]?`)
+)
+
+// a blockquote line with its text translated, and whether it was one
+func translateQuote(ln string, tr func(string) string) (string, bool) {
+	prefix := quotePrefix.FindString(ln)
+	if prefix == "" {
+		return ln, false
+	}
+	rest := ln[len(prefix):]
+	if strings.TrimSpace(rest) == "" {
+		return ln, true
+	}
+	if m := listItem.FindString(rest); m != "" { // a list in the quote
+		prefix += m
+		rest = rest[len(m):]
+	}
+	if tok := calloutType.FindString(rest); tok != "" {
+		prefix += tok
+		rest = rest[len(tok):]
+		if strings.TrimSpace(rest) == "" {
+			return ln, true
+		}
+		prefix += rest[:len(rest)-len(strings.TrimLeft(rest, " \t"))]
+		rest = strings.TrimLeft(rest, " \t")
+	}
+	return prefix + tr(rest), true
+}
@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestTranslateQuote(t *testing.T) {
+	tr := func(s string) string { return "<" + s + ">" }
+	for _, c := range []struct {
+		ln, want string
+		quote    bool
+	}{
+		{"> Back up the database.", "> <Back up the database.>", true},
+		{"> > Nested.", "> > <Nested.>", true},
+		{">Tight.", "><Tight.>", true},
+		{">", ">", true},
+		{"> [!note] Before you start", "> [!note] <Before you start>", true},
+		{"> [!warning]-", "> [!warning]-", true},
+		{"> [!tip]+ Folded", "> [!tip]+ <Folded>", true},
+		{"> - A list item", "> - <A list item>", true},
+		{"Not a quote.", "Not a quote.", false},
+		{"    > indented code", "    > indented code", false},
+	} {
+		got, quote := translateQuote(c.ln, tr)
+		if got != c.want || quote != c.quote {
+			t.Errorf("translateQuote(%q) = %q, %v, want %q, %v", c.ln, got, quote, c.want, c.quote)
+		}
+	}
+}
+
+func TestCalloutsInPages(t *testing.T) {
+	conf = defaultConfig()
+	src := "> [!note] Before you start\n> Back up the database.\n>\n> > Nested too.\n\nAfter.\n"
+	want := "> [!note] <Before you start>\n> <Back up the database.>\n>\n> > <Nested too.>\n\n<After.>\n"
+	if got := xlatePage(t, src); got != want {
+		t.Errorf("got\n%q\nwant\n%q", got, want)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"regexp"
+)
+
+// ![alt](src "caption") or 'caption', some themes show the title as a
+// caption under the image
+var imageTitle = regexp.MustCompile(`^(\(\S+\s+["'])(.+)(["']\))`)
+
+// the caption part of what comes after ![alt]
+func translateImageTitle(rest string, tr func(string) string) string {
+	m := imageTitle.FindStringSubmatchIndex(rest)
+	if m == nil {
+		return rest
+	}
+	return rest[:m[4]] + tr(rest[m[4]:m[5]]) + rest[m[5]:]
+}
+
+var captionPatterns []*regexp.Regexp
+
+// the captions.patterns from the config. Each one matches a whole line
+// and its first group is the caption, everything else (markers, classes)
+// stays as it is.
+func loadCaptionPatterns() []*regexp.Regexp {
+	if captionPatterns == nil {
+		captionPatterns = []*regexp.Regexp{}
+		for _, p := range conf.Captions.Patterns {
+			captionPatterns = append(captionPatterns, regexp.MustCompile(p))
+		}
+	}
+	return captionPatterns
+}
+
+// where the caption text is in a line, if it is one
+func captionText(ln string) (int, int, bool) {
+	for _, re := range loadCaptionPatterns() {
+		if m := re.FindStringSubmatchIndex(ln); m != nil && len(m) >= 4 && m[2] >= 0 {
+			return m[2], m[3], true
+		}
+	}
+	return 0, 0, false
+}
+
+func translateCaption(ln string, tr func(string) string) (string, bool) {
+	start, end, ok := captionText(ln)
+	if !ok {
+		return ln, false
+	}
+	return ln[:start] + tr(ln[start:end]) + ln[end:], true
+}
@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestTranslateImageTitle(t *testing.T) {
+	tr := func(s string) string { return "<" + s + ">" }
+	for rest, want := range map[string]string{
+		`(cat.png "A cat asleep")`: `(cat.png "<A cat asleep>")`,
+		`(cat.png 'A cat asleep')`: `(cat.png '<A cat asleep>')`,
+		`(cat.png)`:                `(cat.png)`,
+		`(cat.png "")`:             `(cat.png "")`,
+	} {
+		if got := translateImageTitle(rest, tr); got != want {
+			t.Errorf("translateImageTitle(%q) = %q, want %q", rest, got, want)
+		}
+	}
+}
+
+func TestCaptionsInPages(t *testing.T) {
+	conf = defaultConfig()
+	conf.Captions.Patterns = []string{`^Figure: (.+)$`, `^\{\.caption\} (.+)$`}
+	captionPatterns = nil
+	defer func() { captionPatterns = nil }()
+	for _, c := range []struct {
+		name, src, want string
+	}{
+		{"an image with a title", "![A cat](cat.png \"Asleep\")\n", "![<A cat>](cat.png \"<Asleep>\")\n"},
+		{"a caption line", "Figure: A cat asleep\n", "Figure: <A cat asleep>\n"},
+		{"a class marker", "{.caption} A cat asleep\n", "{.caption} <A cat asleep>\n"},
+		{"not a caption", "Figures: one\n", "<Figures: one>\n"},
+	} {
+		if got := xlatePage(t, c.src); got != c.want {
+			t.Errorf("%s:\n%q\nwant\n%q", c.name, got, c.want)
+		}
+	}
+}
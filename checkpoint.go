@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// a run that's interrupted, by Ctrl-C or by the provider running out of
+// quota, leaves a checkpoint behind. translate --resume picks it up: the
+// same arguments and the same run id, so rollback undoes all of it, and
+// the pages it got through aren't done again. The segments it paid for
+// are in the cache, so the page it was in the middle of is mostly free.
+var checkpointPath = filepath.Join(stateDir, "checkpoint.json")
+
+type checkpoint struct {
+	ID       string    `json:"id"`
+	Started  time.Time `json:"started"`
+	Args     []string  `json:"args"`
+	Done     []string  `json:"done"` // translations it finished, or gave up on for now
+	Segments int       `json:"segments_sent"`
+	Chars    int       `json:"chars_sent"`
+}
+
+var (
+	resume   bool
+	progress *checkpoint
+	resumed  = map[string]bool{} // what the interrupted run got through
+
+	sentBefore, charsBefore int // by the interrupted run
+)
+
+func loadCheckpoint() *checkpoint {
+	f, err := os.ReadFile(checkpointPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	checkError(err)
+	cp := &checkpoint{}
+	checkError(json.Unmarshal(f, cp))
+	return cp
+}
+
+// before the config's loaded: --resume on its own runs with the
+// interrupted run's arguments
+func resumeRun() {
+	cp := loadCheckpoint()
+	if !resume {
+		if cp != nil {
+			fmt.Printf("warning: run %s didn't finish, starting again (translate --resume carries on from where it stopped)\n", cp.ID)
+		}
+		return
+	}
+	if cp == nil {
+		checkError(fmt.Errorf("nothing to resume, %s isn't there", checkpointPath))
+	}
+	if flag.NArg() == 0 {
+		checkError(flag.CommandLine.Parse(cp.Args))
+	}
+	runID = cp.ID
+	if f, err := os.ReadFile(backupManifest(runID)); err == nil {
+		checkError(json.Unmarshal(f, &backups))
+	}
+	for _, target := range cp.Done {
+		resumed[target] = true
+	}
+	progress = cp
+	sentBefore, charsBefore = cp.Segments, cp.Chars
+	fmt.Printf("Resuming:\t run %s, %d translations and %d segments done already\n", cp.ID, len(cp.Done), cp.Segments)
+}
+
+// at the start of the run
+func startCheckpoint() {
+	if progress == nil {
+		progress = &checkpoint{ID: runID, Started: time.Now(), Args: os.Args[1:]}
+	}
+	checkError(saveCheckpoint())
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-interrupted
+		fmt.Println("\nInterrupted")
+		state.mu.Lock() // let a save that's under way finish, and stop any more
+		finishRun(errors.New("interrupted"))
+		os.Exit(130)
+	}()
+}
+
+// a translation's finished, or given up on for now; called with runMu
+// held, so the error's for the caller to check once it's let go of it
+func checkpointPage(target string) error {
+	if progress == nil {
+		return nil
+	}
+	progress.Done = append(progress.Done, target)
+	return saveCheckpoint()
+}
+
+func saveCheckpoint() error {
+	_, total := summarize()
+	progress.Segments, progress.Chars = sentBefore+total.Sent, charsBefore+total.Chars
+	out, err := json.MarshalIndent(progress, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(checkpointPath, out, 0644)
+}
+
+// did the run we're resuming get to the end of target?
+func doneBefore(target string) bool {
+	runMu.Lock()
+	defer runMu.Unlock()
+	return resumed[target]
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// <!-- more -->, build directives and notes to reviewers. None of them
+// should go anywhere near the API unless they start with one of the
+// comments.translate prefixes.
+var htmlComment = regexp.MustCompile(`(?s)<!--.*?-->`)
+
+// should this comment be translated? Pass it the whole comment or just
+// the line it starts on.
+func translatableComment(c string) bool {
+	c = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(c), "<!--"))
+	for _, p := range conf.Comments.Translate {
+		if strings.HasPrefix(c, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// for masking, the comments that are staying as they are
+func hiddenComment(c string) bool {
+	return !translatableComment(c)
+}
+
+// does this line start a comment that takes up the whole line (or runs
+// on past it)? A comment with text after it on the same line is left to
+// the masker instead.
+func opensComment(ln string) bool {
+	t := strings.TrimSpace(ln)
+	return strings.HasPrefix(t, "<!--") && (strings.HasSuffix(t, "-->") || !strings.Contains(t, "-->"))
+}
+
+// split a comment line into the delimiters and prefix we keep and the
+// text in between that gets translated
+func splitComment(ln string) (string, string, string) {
+	before, body := "", ln
+	if i := strings.Index(body, "<!--"); i >= 0 {
+		before, body = body[:i+4], body[i+4:]
+		t := strings.TrimLeft(body, " ")
+		for _, p := range conf.Comments.Translate {
+			if strings.HasPrefix(t, p) {
+				n := len(body) - len(t) + len(p)
+				before, body = before+body[:n], body[n:]
+				break
+			}
+		}
+	}
+	after := ""
+	if i := strings.Index(body, "-->"); i >= 0 {
+		body, after = body[:i], body[i:]
+	}
+	text := strings.TrimSpace(body)
+	if text == "" {
+		return ln, "", ""
+	}
+	start := strings.Index(body, text)
+	return before + body[:start], text, body[start+len(text):] + after
+}
+
+func translateComment(ln string, tr func(string) string) string {
+	before, text, after := splitComment(ln)
+	if text == "" {
+		return ln
+	}
+	return before + tr(text) + after
+}
@@ -0,0 +1,55 @@
+package main
+
+import "testing"
+
+func TestTranslatableComment(t *testing.T) {
+	conf = defaultConfig()
+	conf.Comments.Translate = []string{"NOTE:", "TODO"}
+	for c, want := range map[string]bool{
+		"<!-- NOTE: check the numbers -->": true,
+		"<!--NOTE: no space -->":           true,
+		"  <!-- TODO translate":            true,
+		"<!-- build: keep -->":             false,
+		"<!-- more -->":                    false,
+		"<!-- note: lower case -->":        false,
+	} {
+		if got := translatableComment(c); got != want {
+			t.Errorf("translatableComment(%q) = %v, want %v", c, got, want)
+		}
+	}
+}
+
+func TestTranslateComment(t *testing.T) {
+	conf = defaultConfig()
+	conf.Comments.Translate = []string{"NOTE:"}
+	tr := func(s string) string { return "<" + s + ">" }
+	for ln, want := range map[string]string{
+		"<!-- NOTE: check this -->": "<!-- NOTE: <check this> -->",
+		"<!-- NOTE: starts here":    "<!-- NOTE: <starts here>",
+		"and carries on":            "<and carries on>",
+		"until here -->":            "<until here> -->",
+		"<!-- NOTE: -->":            "<!-- NOTE: -->",
+		"  -->":                     "  -->",
+	} {
+		if got := translateComment(ln, tr); got != want {
+			t.Errorf("translateComment(%q) = %q, want %q", ln, got, want)
+		}
+	}
+}
+
+func TestCommentsInPages(t *testing.T) {
+	conf = defaultConfig()
+	conf.Comments.Translate = []string{"NOTE:"}
+	for _, c := range []struct {
+		name, src, want string
+	}{
+		{"kept", "<!-- build: keep -->\n\nText.\n", "<!-- build: keep -->\n\n<Text.>\n"},
+		{"for people", "<!-- NOTE: check this -->\n\nText.\n", "<!-- NOTE: <check this> -->\n\n<Text.>\n"},
+		{"over several lines", "<!--\nmulti\nline\n-->\n\nText.\n", "<!--\nmulti\nline\n-->\n\n<Text.>\n"},
+		{"for people, over several lines", "<!-- NOTE: one\ntwo -->\n\nText.\n", "<!-- NOTE: <one>\n<two> -->\n\n<Text.>\n"},
+	} {
+		if got := xlatePage(t, c.src); got != c.want {
+			t.Errorf("%s:\n%q\nwant\n%q", c.name, got, c.want)
+		}
+	}
+}
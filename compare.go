@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html/template"
+	"os"
+	"strings"
+	"time"
+)
+
+var comparePage = template.Must(template.New("compare").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Provider comparison</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.4em 0.8em; text-align: left; vertical-align: top; }
+td.same { color: #888; }
+</style>
+</head>
+<body>
+<h1>{{.File}} in {{.Lang}}</h1>
+<p>Generated {{.Generated.Format "2006-01-02 15:04"}}</p>
+<table>
+<tr><th>Line</th><th>Source</th>{{range .Providers}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr><td>{{.Line}}</td><td>{{.Source}}</td>{{$same := .Same}}{{range .Out}}<td{{if $same}} class="same"{{end}}>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+type compareRow struct {
+	Line   int
+	Source string
+	Out    []string // one per provider
+	Same   bool     // they all agree
+}
+
+// translator compare --providers google,llm --to de file.md
+func compareCmd(args []string) {
+	flags := flag.NewFlagSet("compare", flag.ExitOnError)
+	configFile := flags.String("config", defaultConfigFile, "config file")
+	flags.StringVar(&profile, "profile", profile, "config profile to use")
+	providers := flags.String("providers", "google,llm", "comma separated providers to compare")
+	to := flags.String("to", "", "language to translate into")
+	html := flags.String("html", "", "write the report as HTML to this file")
+	flags.Parse(args)
+	if flags.NArg() != 1 || *to == "" {
+		fmt.Println("usage: translate compare [--providers google,llm] --to de [--html report.html] <file.md>")
+		os.Exit(1)
+	}
+	conf = loadConfig(*configFile)
+	conf.Translation.Fallbacks = nil // each provider on its own
+	defer closeFilters()
+	file := flags.Arg(0)
+	src, err := os.ReadFile(file)
+	checkError(err)
+	fields, blocks := pageBlocks(src)
+	var rows []*compareRow
+	for _, k := range []string{"title", "description"} {
+		if v, ok := fields[k]; ok {
+			rows = append(rows, &compareRow{Line: 0, Source: v})
+		}
+	}
+	for _, b := range blocks {
+		for _, s := range b.segs {
+			rows = append(rows, &compareRow{Line: b.line, Source: s})
+		}
+	}
+	names := strings.Split(*providers, ",")
+	for _, name := range names {
+		current = newProvider(strings.TrimSpace(name))
+		consistent = map[string]map[string]*reused{} // no borrowing from the last provider
+		for _, r := range rows {
+			var out string
+			checkError(tryPage(func() { out = xl(conf.Source, *to, r.Source) })) // failures.then is for pages
+			r.Out = append(r.Out, out)
+		}
+	}
+	checkError(loadCache().compact())
+	for _, r := range rows {
+		r.Same = true
+		for _, o := range r.Out {
+			r.Same = r.Same && o == r.Out[0]
+		}
+	}
+	if *html != "" {
+		f, err := os.Create(*html)
+		checkError(err)
+		defer f.Close()
+		checkError(comparePage.Execute(f, struct {
+			File      string
+			Lang      string
+			Generated time.Time
+			Providers []string
+			Rows      []*compareRow
+		}{file, *to, time.Now(), names, rows}))
+		fmt.Printf("Wrote %s\n", *html)
+		return
+	}
+	same := 0
+	for _, r := range rows {
+		if r.Same {
+			same++
+		}
+		fmt.Printf("%d: %s\n", r.Line, strings.TrimSpace(r.Source))
+		for i, o := range r.Out {
+			fmt.Printf("\t%s:\t%s\n", names[i], strings.TrimSpace(o))
+		}
+		fmt.Println()
+	}
+	fmt.Printf("%d of %d segments came out the same from every provider\n", same, len(rows))
+}
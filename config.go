@@ -0,0 +1,371 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// where the config lives unless told otherwise. It's optional, without
+// it we translate English into the same four languages as always.
+const defaultConfigFile = "translator.json"
+
+type Config struct {
+	Source      string                     `json:"source"`    // the language we translate from
+	Languages   []string                   `json:"languages"` // the languages we translate to
+	Codes       map[string]LanguageCodes   `json:"codes"`     // when the API or the file names call one something else
+	Translation Translation                `json:"translation"`
+	Partial     Partial                    `json:"partial"`
+	Privacy     Privacy                    `json:"privacy"`
+	Safety      Safety                     `json:"safety"`
+	Consistency Consistency                `json:"consistency"`
+	Alternates  Alternates                 `json:"alternates"`
+	Cache       Cache                      `json:"cache"`
+	Lastmod     Lastmod                    `json:"lastmod"`
+	Mounts      []Mount                    `json:"mounts"`
+	Data        Data                       `json:"data"`
+	Params      Params                     `json:"params"`
+	OGImages    OGImages                   `json:"og_images"`
+	Links       Links                      `json:"links"`
+	Markdown    Markdown                   `json:"markdown"`
+	Paragraphs  Paragraphs                 `json:"paragraphs"`
+	Pages       Pages                      `json:"pages"`
+	Comments    Comments                   `json:"comments"`
+	Captions    Captions                   `json:"captions"`
+	Protect     Protect                    `json:"protect"`
+	Literals    Literals                   `json:"literals"`
+	Audit       Audit                      `json:"audit"`
+	Billing     Billing                    `json:"billing"`
+	Keys        Keys                       `json:"keys"`
+	Shortcodes  Shortcodes                 `json:"shortcodes"`
+	Workers     int                        `json:"workers"` // bundles translated at the same time
+	Adaptive    Adaptive                   `json:"adaptive"`
+	Failures    Failures                   `json:"failures"`
+	Filters     []Filter                   `json:"filters"`
+	Plugins     []string                   `json:"plugins"` // Go plugins with fixers or providers, see plugins.go
+	Refine      Refine                     `json:"refine"`
+	Assets      Assets                     `json:"assets"`
+	FrontMatter FrontMatter                `json:"front_matter"`
+	Layout      Layout                     `json:"layout"`
+	Profiles    map[string]json.RawMessage `json:"profiles"` // named overrides, picked with --profile
+}
+
+// how to talk to the translation API
+type Translation struct {
+	Provider     string               `json:"provider"`    // "google" (the default), "azure", "llm", "pseudo", "noop" or "plugin:<name>"
+	Credentials  string               `json:"credentials"` // the Google API json file
+	ProjectID    string               `json:"project_id"`
+	Model        string               `json:"model"`    // Either "nmt" or "base".
+	API          string               `json:"api"`      // Google's "v3" (the default) or the older "v2"
+	Location     string               `json:"location"` // for v3, "global" if it's not set
+	LLM          LLM                  `json:"llm"`
+	Azure        Azure                `json:"azure"`
+	Batch        Batch                `json:"batch"`
+	Pivots       map[string]string    `json:"pivots"`      // "nl/pt" or "*/pt" -> the language to go through
+	Fallbacks    []string             `json:"fallbacks"`   // providers to try, in order, when the one before can't
+	Glossaries   map[string]string    `json:"glossaries"`  // "en/fr" or "*/fr" -> a Google glossary, v3 only
+	RateLimits   map[string]RateLimit `json:"rate_limits"` // provider name -> its quota
+	Retry        Retry                `json:"retry"`
+	Requests     Requests             `json:"requests"`
+	MaxChars     int                  `json:"max_chars"`    // a segment longer than this goes a few sentences at a time
+	Placeholders []string             `json:"placeholders"` // "brackets", "underscores": the styles to try, in order, see mask.go
+}
+
+// how much of a page goes in one call to the provider
+type Requests struct {
+	Segments int `json:"segments"` // at most, 1 sends them one at a time
+	Chars    int `json:"chars"`    // at most, a segment longer than this goes on its own
+}
+
+// a provider's quota, so a big run waits for it instead of running into it
+type RateLimit struct {
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	CharsPerMinute    int     `json:"chars_per_minute"`
+}
+
+// trying a call again when it fails in a way that might not last
+type Retry struct {
+	Attempts   int `json:"attempts"`    // in all, 1 never tries again
+	MaxSeconds int `json:"max_seconds"` // the longest wait between them
+}
+
+// what a page gets when the provider keeps failing on it, see failures.go
+type Failures struct {
+	Attempts int               `json:"attempts"` // at the whole page, 1 if it's not set
+	Then     string            `json:"then"`     // "stop" (the default), "skip", "copy" or "notice"
+	Notice   map[string]string `json:"notice"`   // per language, for "notice"
+}
+
+// as many requests at once as the API will take, instead of workers
+type Adaptive struct {
+	MaxWorkers int `json:"max_workers"` // 0 turns it off
+}
+
+// where --batch puts its files for Google's batch translation
+type Batch struct {
+	URI      string `json:"uri"`      // gs://bucket/path
+	Location string `json:"location"` // defaults to us-central1
+}
+
+// Azure AI Translator
+type Azure struct {
+	Key      string `json:"key"`      // the subscription key, better left in key_env
+	KeyEnv   string `json:"key_env"`  // env var with the key, defaults to AZURE_TRANSLATOR_KEY
+	Region   string `json:"region"`   // the resource's region, unless it's global
+	Endpoint string `json:"endpoint"` // defaults to https://api.cognitive.microsofttranslator.com
+}
+
+// an OpenAI compatible chat completions API
+type LLM struct {
+	Endpoint    string            `json:"endpoint"` // defaults to https://api.openai.com/v1
+	Model       string            `json:"model"`
+	KeyEnv      string            `json:"key_env"`      // env var with the API key, defaults to OPENAI_API_KEY
+	StyleGuides map[string]string `json:"style_guides"` // language -> style guide file
+}
+
+// translate only the first part of very long pages
+type Partial struct {
+	WordBudget int               `json:"word_budget"` // 0 translates everything
+	Marker     map[string]string `json:"marker"`      // per language "continued in English" text
+}
+
+// what never gets sent to the API
+type Privacy struct {
+	MaskPII bool `json:"mask_pii"` // emails, phone numbers, API keys
+}
+
+// things that should never show up in a translation
+type Safety struct {
+	Blocklists map[string]string `json:"blocklists"` // language -> file with one term per line
+	Report     string            `json:"report"`     // defaults to .translator/review.log
+}
+
+// short strings get translated the same way on every page
+type Consistency struct {
+	MaxWords int `json:"max_words"` // strings up to this long, 0 turns it off
+}
+
+// link each translated page to its other languages
+type Alternates struct {
+	Mode     string `json:"mode"`     // "front_matter", "shortcode", or empty for neither
+	Key      string `json:"key"`      // front matter key, defaults to "alternates"
+	Template string `json:"template"` // shortcode, {lang} is this page, {langs} the others
+}
+
+// translations we've already paid for
+type Cache struct {
+	Path       string         `json:"path"`         // defaults to .translator/cache.json
+	MaxAgeDays map[string]int `json:"max_age_days"` // by "fr/google", "fr", "google" or "*"
+	Remote     RemoteCache    `json:"remote"`
+	Fuzzy      float64        `json:"fuzzy"` // reuse the translation of cached text at least this alike (0.9), 0 is off
+}
+
+// who pays for which part of the site
+type Billing struct {
+	Labels map[string]string `json:"labels"` // section (glob or path prefix) -> label, like "content/docs": "docs"
+}
+
+// a log of every call to a provider, for charging teams back
+type Audit struct {
+	CSV      string `json:"csv"`      // append a row a call to this file
+	BigQuery string `json:"bigquery"` // and/or stream them to project.dataset.table
+}
+
+// a cache shared between machines
+type RemoteCache struct {
+	URL      string `json:"url"`       // GET and PUT <url>/<key>
+	TokenEnv string `json:"token_env"` // env var with a bearer token, if it needs one
+}
+
+// tell Hugo when a translation was refreshed
+type Lastmod struct {
+	Bump   bool   `json:"bump"`   // set lastmod when a translation is redone
+	Format string `json:"format"` // Go time layout, defaults to 2006-01-02
+}
+
+// content mounted from a Hugo module or theme, which we can't write to
+// data files (data/authors.yaml and the like) to translate, see data.go
+type Data struct {
+	Files []DataFile `json:"files"`
+	Names Names      `json:"names"`
+}
+
+type DataFile struct {
+	Path      string   `json:"path"`      // a .yaml, .yml, .toml or .json file, {lang} for the language's directory and * for more than one
+	Translate []string `json:"translate"` // keys whose values are translated, like bio, "-" for the strings in a list
+	Names     []string `json:"names"`     // keys whose values are people's names, never translated
+	Out       string   `json:"out"`       // where a language's copy goes, {lang} is the language; data/{lang}/authors.yaml if it's not set
+	MaxChars  int      `json:"max_chars"` // warn about a translated value longer than this
+}
+
+// the text the Open Graph card generator draws on the images, see og.go
+type OGImages struct {
+	Manifest  string   `json:"manifest"`  // a .yaml, .toml or .json file, anywhere
+	Translate []string `json:"translate"` // keys to translate, "-" for a list of strings; title and "-" if it's not set
+	Out       string   `json:"out"`       // {lang} is the language; cards.fr.json next to cards.json if it's not set
+	MaxChars  int      `json:"max_chars"` // warn about titles longer than this, they won't fit on the card
+}
+
+// site params in the Hugo config to translate, see params.go
+type Params struct {
+	Translate []string `json:"translate"` // paths under params, like footer.text
+	Out       string   `json:"out"`       // where a language's go, {lang} is the language; config/_default/params.{lang}.toml if it's not set
+}
+
+// what becomes of names in data files
+type Names struct {
+	Transliterate []string                     `json:"transliterate"` // languages to write them in their own script for, like ru and ja, by the llm provider
+	Known         map[string]map[string]string `json:"known"`         // language -> name -> how it's written there, which always wins
+}
+
+type Mount struct {
+	Source string `json:"source"` // where the module's content is on disk
+	Target string `json:"target"` // where it's mounted, like content/docs
+}
+
+// which pages are worth translating at all
+type Pages struct {
+	TranslateHidden bool                `json:"translate_hidden"` // headless bundles and _build render: never
+	SkipExpired     bool                `json:"skip_expired"`     // expiryDate has passed
+	FutureDays      int                 `json:"future_days"`      // skip publishDate further away than this, 0 doesn't
+	TranslateSlugs  bool                `json:"translate_slugs"`  // give translations their own slug, see slugs.go
+	Languages       map[string][]string `json:"languages"`        // section (glob or path prefix) -> the languages it's translated into, see languagesFor
+}
+
+// how the site keeps its languages apart: "filename" (index.fr.md next to
+// index.en.md, the default) or "directory" (content/fr/... next to
+// content/en/...)
+type Layout struct {
+	Mode string            `json:"mode"`
+	Dirs map[string]string `json:"dirs"` // language -> its content directory, content/<lang> if it's not here
+}
+
+// fields added to every translation's front matter
+type FrontMatter struct {
+	Inject  map[string]string `json:"inject"`  // key -> value, with {lang}, {source_path} and {date}
+	Noindex map[string]string `json:"noindex"` // key -> value, in machine translations until they're reviewed
+}
+
+// HTML comments are left alone except for these
+type Comments struct {
+	Translate []string `json:"translate"` // prefixes, like "NOTE:"
+}
+
+// theme specific caption lines, like *A caption*{.caption}
+// where images in the front matter live for each language's build
+type Assets struct {
+	Fields  []string       `json:"fields"` // defaults to image, og_image, images and featured_image
+	Rewrite []AssetRewrite `json:"rewrite"`
+}
+
+type AssetRewrite struct {
+	From string `json:"from"` // a path prefix
+	To   string `json:"to"`   // what it becomes, {lang} is the language
+}
+
+type Captions struct {
+	Patterns []string `json:"patterns"` // regexps for a whole line, group 1 is the caption
+}
+
+// tokens in prose that aren't words, never sent to the API
+type Protect struct {
+	Patterns []string `json:"patterns"` // regexps, [] turns the built in ones off
+}
+
+// what the site calls "pt" can be pt-BR to the API, or pt-br in the
+// file names
+type LanguageCodes struct {
+	API  string `json:"api"`  // the BCP-47 code providers get
+	File string `json:"file"` // index.<file>.md, or content/<file> in the directory layout
+}
+
+// segments that are really values (colors, booleans...) and are kept
+type Literals struct {
+	Kinds    []string `json:"kinds"`    // numbers, booleans, colors, dates, urls, emails; [] turns them off
+	Patterns []string `json:"patterns"` // more, as regexps the whole segment has to match
+}
+
+// keyboard shortcuts are never translated, but key names can be
+type Keys struct {
+	Names map[string]map[string]string `json:"names"` // language -> key -> what it's called there
+}
+
+// what to translate in theme shortcodes
+type Shortcodes struct {
+	Themes []string                   `json:"themes"` // presets: "docsy", "hextra", "doks", "relearn"
+	Custom map[string]ShortcodePolicy `json:"custom"` // shortcode name -> policy, wins over the presets
+}
+
+// an external program that fixes up segments, see filters.go
+type Filter struct {
+	Command []string `json:"command"` // the program and its arguments
+	Stages  []string `json:"stages"`  // "pre", "post", or both if empty
+}
+
+// paragraphs wrapped over several lines, see paragraphs.go
+type Paragraphs struct {
+	Join bool `json:"join"` // translate them as one segment, true by default
+	Wrap int  `json:"wrap"` // wrap the translation at this many characters, 0 leaves it on one line
+}
+
+// how a page's body is read, see markdown.go
+type Markdown struct {
+	Parser string `json:"parser"` // "lines" (the default) or "goldmark"
+}
+
+// what's checked in the translations at the end of a run, see links.go
+type Links struct {
+	Check  bool   `json:"check"`  // relative links, anchors and image paths
+	Output string `json:"output"` // "as-is" (the default) or "render-hooks", see hooks.go
+}
+
+// sections that get a second, LLM, pass over the machine translation
+type Refine struct {
+	Sections []string `json:"sections"` // globs or path prefixes, like content/docs
+}
+
+func defaultConfig() *Config {
+	return &Config{
+		Source:    "en",
+		Languages: []string{"nl", "fr", "de", "es"},
+		Translation: Translation{
+			Credentials:  "google-secret.json",
+			ProjectID:    "103373479946395174633",
+			Model:        "nmt",
+			Retry:        Retry{Attempts: 5, MaxSeconds: 30},
+			Requests:     Requests{Segments: 100, Chars: 5000},
+			MaxChars:     5000,
+			Placeholders: []string{"brackets", "underscores"},
+		},
+		Paragraphs:  Paragraphs{Join: true},
+		Consistency: Consistency{MaxWords: 4},
+		Protect:     Protect{Patterns: defaultProtect},
+		Literals:    Literals{Kinds: defaultLiterals},
+	}
+}
+
+var conf = defaultConfig()
+
+// which of the config's profiles to use, from --profile or
+// $TRANSLATOR_PROFILE
+var profile = os.Getenv("TRANSLATOR_PROFILE")
+
+// anything not in the file keeps its default, and anything in the
+// profile replaces what's in the file
+func loadConfig(path string) *Config {
+	c := defaultConfig()
+	f, err := os.ReadFile(path)
+	if os.IsNotExist(err) && path == defaultConfigFile && profile == "" {
+		return c
+	}
+	checkError(err)
+	checkError(json.Unmarshal(f, c))
+	if profile != "" {
+		p, ok := c.Profiles[profile]
+		if !ok {
+			checkError(fmt.Errorf("%s has no profile called %q", path, profile))
+		}
+		checkError(json.Unmarshal(p, c))
+	}
+	return c
+}
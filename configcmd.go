@@ -0,0 +1,539 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// translate config init|validate|schema|hugo
+func configCmd(args []string) {
+	if len(args) == 0 {
+		fmt.Println("usage: translate config init|validate|schema|hugo [--config translator.json]")
+		os.Exit(1)
+	}
+	flags := flag.NewFlagSet("config", flag.ExitOnError)
+	configFile := flags.String("config", defaultConfigFile, "config file")
+	force := flags.Bool("force", false, "init: replace a config that's already there")
+	write := flags.Bool("write", false, "hugo: add the missing languages to the Hugo config instead of printing a patch")
+	flags.Parse(args[1:])
+	switch args[0] {
+	case "init":
+		configInit(*configFile, *force)
+	case "validate":
+		problems := validateConfig(*configFile)
+		for _, p := range problems {
+			fmt.Printf("%s: %s\n", *configFile, p)
+		}
+		if len(problems) > 0 {
+			os.Exit(1)
+		}
+		fmt.Printf("%s is fine\n", *configFile)
+	case "schema":
+		out, err := json.MarshalIndent(configSchema(), "", "  ")
+		checkError(err)
+		fmt.Println(string(out))
+	case "hugo":
+		conf = loadConfig(*configFile)
+		configHugo(*write)
+	default:
+		checkError(fmt.Errorf("unknown config command %q", args[0]))
+	}
+}
+
+// a JSON schema for translator.json, worked out from Config so the two
+// can't drift apart
+func configSchema() map[string]interface{} {
+	s := schemaFor(reflect.TypeOf(Config{}))
+	s["$schema"] = "http://json-schema.org/draft-07/schema#"
+	s["title"] = "translator.json"
+	return s
+}
+
+var rawMessage = reflect.TypeOf(json.RawMessage{})
+
+func schemaFor(t reflect.Type) map[string]interface{} {
+	if t == rawMessage { // only profiles, which are configs themselves
+		return map[string]interface{}{"$ref": "#"}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaFor(t.Elem())
+	case reflect.Struct:
+		props := map[string]interface{}{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := strings.Split(f.Tag.Get("json"), ",")[0]
+			if f.PkgPath != "" || name == "" || name == "-" {
+				continue
+			}
+			props[name] = schemaFor(f.Type)
+		}
+		return map[string]interface{}{"type": "object", "properties": props, "additionalProperties": false}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaFor(t.Elem())}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": schemaFor(t.Elem())}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	}
+	return map[string]interface{}{}
+}
+
+// everything wrong with a config file, and with each of its profiles
+func validateConfig(path string) []string {
+	f, err := os.ReadFile(path)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	c := defaultConfig()
+	if err := strictUnmarshal(f, c); err != nil {
+		return []string{err.Error()}
+	}
+	problems := checkConfig(c)
+	for name, raw := range c.Profiles {
+		pc := defaultConfig()
+		strictUnmarshal(f, pc)
+		if err := strictUnmarshal(raw, pc); err != nil {
+			problems = append(problems, fmt.Sprintf("profile %s: %v", name, err))
+			continue
+		}
+		for _, p := range checkConfig(pc) {
+			problems = append(problems, fmt.Sprintf("profile %s: %s", name, p))
+		}
+	}
+	return problems
+}
+
+// like json.Unmarshal, but a misspelled key is an error
+func strictUnmarshal(data []byte, v interface{}) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.DisallowUnknownFields()
+	return d.Decode(v)
+}
+
+func checkConfig(c *Config) []string {
+	var problems []string
+	bad := func(format string, a ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, a...))
+	}
+	if len(c.Languages) == 0 {
+		bad("no languages to translate into")
+	}
+	if isValueInList(c.Source, c.Languages) {
+		bad("languages includes the source language %s", c.Source)
+	}
+	for l, codes := range c.Codes {
+		if l != c.Source && !isValueInList(l, c.Languages) {
+			bad("codes: %s isn't the source or one of the languages", l)
+		}
+		if codes.API == "" && codes.File == "" {
+			bad("codes.%s: needs an api code, a file code or both", l)
+		}
+	}
+	for section, langs := range c.Pages.Languages {
+		for _, l := range langs {
+			if l != "*" && !isValueInList(l, c.Languages) {
+				bad("pages.languages.%s: %s isn't one of the languages", section, l)
+			}
+		}
+	}
+	files := map[string]string{} // file code -> language
+	for _, l := range append([]string{c.Source}, c.Languages...) {
+		file := l
+		if f := c.Codes[l].File; f != "" {
+			file = f
+		}
+		if other, ok := files[file]; ok && other != l {
+			bad("codes: %s and %s would both be %s in the file names", other, l, file)
+		}
+		files[file] = l
+	}
+	p := c.Translation.Provider
+	switch {
+	case p == "" || p == "google":
+		if c.Translation.Credentials != "" && !exists(c.Translation.Credentials) {
+			bad("translation.credentials: %s doesn't exist", c.Translation.Credentials)
+		}
+		if a := c.Translation.API; a != "" && a != "v2" && a != "v3" {
+			bad("translation.api should be v2 or v3, not %q", a)
+		}
+		if c.Translation.API == "v2" && len(c.Translation.Glossaries) > 0 {
+			bad("translation.glossaries need the v3 API, and translation.api is v2")
+		}
+		for k := range c.Translation.Glossaries {
+			if !strings.Contains(k, "/") {
+				bad("translation.glossaries: %q should be a language pair, like en/fr or */fr", k)
+			}
+		}
+	case p == "azure":
+		keyEnv := c.Translation.Azure.KeyEnv
+		if keyEnv == "" {
+			keyEnv = "AZURE_TRANSLATOR_KEY"
+		}
+		if c.Translation.Azure.Key == "" && os.Getenv(keyEnv) == "" {
+			bad("the azure provider needs translation.azure.key or $%s", keyEnv)
+		}
+	case offline(p):
+	case p == "llm":
+		if c.Translation.LLM.Model == "" {
+			bad("the llm provider needs translation.llm.model")
+		}
+	case strings.HasPrefix(p, "plugin:"):
+		if len(c.Plugins) == 0 {
+			bad("translation.provider is %s but there are no plugins", p)
+		}
+	default:
+		bad("unknown translation.provider %q", p)
+	}
+	for _, f := range c.Translation.Fallbacks {
+		if f != "google" && f != "azure" && f != "llm" && !offline(f) && !strings.HasPrefix(f, "plugin:") {
+			bad("unknown provider %q in translation.fallbacks", f)
+		}
+	}
+	if c.Translation.Retry.Attempts < 1 {
+		bad("translation.retry.attempts should be at least 1 (which never tries again), not %d", c.Translation.Retry.Attempts)
+	}
+	if c.Translation.Retry.MaxSeconds < 0 {
+		bad("translation.retry.max_seconds can't be negative")
+	}
+	if c.Translation.MaxChars < 0 {
+		bad("translation.max_chars can't be negative")
+	}
+	if c.Paragraphs.Wrap < 0 {
+		bad("paragraphs.wrap can't be negative")
+	}
+	if p := c.Markdown.Parser; p != "" && p != "lines" && p != "goldmark" {
+		bad("markdown.parser should be \"lines\" or \"goldmark\", not %q", p)
+	}
+	if o := c.Links.Output; o != "" && o != "as-is" && o != "render-hooks" {
+		bad("links.output should be \"as-is\" or \"render-hooks\", not %q", o)
+	}
+	if len(c.Translation.Placeholders) == 0 {
+		bad("translation.placeholders needs at least one style")
+	}
+	for _, p := range c.Translation.Placeholders {
+		if _, ok := placeholderStyles[p]; !ok {
+			bad("translation.placeholders: %q should be \"brackets\" or \"underscores\"", p)
+		}
+	}
+	if r := c.Translation.Requests; r.Segments < 1 || r.Chars < 1 {
+		bad("translation.requests: segments and chars should be at least 1")
+	}
+	for name, rate := range c.Translation.RateLimits {
+		if rate.RequestsPerSecond < 0 || rate.CharsPerMinute < 0 {
+			bad("translation.rate_limits.%s: limits can't be negative", name)
+		}
+		if rate.RequestsPerSecond == 0 && rate.CharsPerMinute == 0 {
+			bad("translation.rate_limits.%s: needs requests_per_second, chars_per_minute or both", name)
+		}
+	}
+	source := c.Source
+	if code := c.Codes[c.Source].File; code != "" {
+		source = code
+	}
+	for _, f := range c.Data.Files {
+		found := dataSources(f, source)
+		if len(found) == 0 {
+			bad("data.files: %s doesn't exist", strings.Replace(f.Path, "{lang}", source, -1))
+		}
+		if f.Out != "" && len(found) > 1 {
+			bad("data.files: %s is %d files, out can only say where one of them goes", f.Path, len(found))
+		}
+		if len(f.Translate) == 0 && len(f.Names) == 0 {
+			bad("data.files: %s has no keys to translate", f.Path)
+		}
+	}
+	if f := c.OGImages; f.Manifest != "" {
+		if !exists(f.Manifest) {
+			bad("og_images.manifest: %s doesn't exist", f.Manifest)
+		}
+		if f.Out != "" && !strings.Contains(f.Out, "{lang}") {
+			bad("og_images.out: %s needs {lang} in it, or every language writes the same file", f.Out)
+		}
+		if f.MaxChars < 0 {
+			bad("og_images.max_chars can't be negative")
+		}
+	}
+	for _, p := range c.Params.Translate {
+		if p == "" || strings.HasPrefix(p, ".") || strings.HasSuffix(p, ".") || strings.HasPrefix(p, "params.") {
+			bad("params.translate: %q should be a path under params, like footer.text", p)
+		}
+	}
+	if o := c.Params.Out; o != "" {
+		if !strings.Contains(o, "{lang}") {
+			bad("params.out: %s needs {lang} in it, or every language writes the same file", o)
+		}
+		if e := filepath.Ext(o); e != ".toml" && e != ".yaml" && e != ".yml" && e != ".json" {
+			bad("params.out: %s should be a .toml, .yaml or .json file", o)
+		}
+	}
+	if len(c.Data.Names.Transliterate) > 0 && c.Translation.LLM.Model == "" {
+		bad("data.names.transliterate needs translation.llm.model, names are transliterated by the llm provider")
+	}
+	for _, f := range c.Plugins {
+		if !exists(f) {
+			bad("plugins: %s doesn't exist", f)
+		}
+	}
+	for lang, f := range c.Safety.Blocklists {
+		if !exists(f) {
+			bad("safety.blocklists.%s: %s doesn't exist", lang, f)
+		}
+	}
+	for lang, f := range c.Translation.LLM.StyleGuides {
+		if !exists(f) {
+			bad("translation.llm.style_guides.%s: %s doesn't exist", lang, f)
+		}
+	}
+	if f := c.Cache.Fuzzy; f < 0 || f >= 1 {
+		bad("cache.fuzzy should be between 0 (off) and 1, like 0.9, not %v", f)
+	}
+	if m := c.Alternates.Mode; m != "" && m != "front_matter" && m != "shortcode" {
+		bad("alternates.mode should be front_matter or shortcode, not %q", m)
+	}
+	for _, t := range c.Shortcodes.Themes {
+		if _, ok := shortcodePresets[t]; !ok {
+			bad("shortcodes.themes: no preset for %q", t)
+		}
+	}
+	for _, re := range c.Captions.Patterns {
+		if _, err := regexp.Compile(re); err != nil {
+			bad("captions.patterns: %v", err)
+		}
+	}
+	for _, re := range c.Protect.Patterns {
+		if _, err := regexp.Compile(re); err != nil {
+			bad("protect.patterns: %v", err)
+		}
+	}
+	if t := c.Audit.BigQuery; t != "" && len(strings.Split(t, ".")) != 2 && len(strings.Split(t, ".")) != 3 {
+		bad("audit.bigquery should be project.dataset.table or dataset.table, not %q", t)
+	}
+	for _, k := range c.Literals.Kinds {
+		if _, ok := literalKinds[k]; !ok {
+			bad("literals.kinds: no such kind %q", k)
+		}
+	}
+	for _, re := range c.Literals.Patterns {
+		if _, err := regexp.Compile(re); err != nil {
+			bad("literals.patterns: %v", err)
+		}
+	}
+	for i, f := range c.Filters {
+		if len(f.Command) == 0 {
+			bad("filters[%d] has no command", i)
+		}
+	}
+	for k, v := range c.FrontMatter.Inject {
+		if k == "" || strings.ContainsAny(k, ": ") {
+			bad("front_matter.inject: %q isn't a front matter key", k)
+		}
+		left := strings.NewReplacer("{lang}", "", "{source_path}", "", "{date}", "").Replace(v)
+		if strings.Contains(left, "{") && strings.Contains(left, "}") {
+			bad("front_matter.inject.%s: only {lang}, {source_path} and {date} get filled in", k)
+		}
+	}
+	for k := range c.FrontMatter.Noindex {
+		if k == "" || strings.ContainsAny(k, ": ") {
+			bad("front_matter.noindex: %q isn't a front matter key", k)
+		}
+		if _, ok := c.FrontMatter.Inject[k]; ok {
+			bad("front_matter.%s is in both inject and noindex", k)
+		}
+	}
+	if m := c.Layout.Mode; m != "" && m != "filename" && m != "directory" {
+		bad("layout.mode should be filename or directory, not %q", m)
+	}
+	if c.Workers < 0 {
+		bad("workers can't be negative")
+	}
+	if t := c.Failures.Then; t != "" && t != "stop" && t != "skip" && t != "copy" && t != "notice" {
+		bad("failures.then should be stop, skip, copy or notice, not %q", t)
+	}
+	if c.Failures.Attempts < 0 {
+		bad("failures.attempts can't be negative")
+	}
+	if c.Adaptive.MaxWorkers < 0 {
+		bad("adaptive.max_workers can't be negative")
+	}
+	return problems
+}
+
+// write a config from a few questions, with what the Hugo config says as
+// the answers if there is one
+func configInit(path string, force bool) {
+	if exists(path) && !force {
+		checkError(fmt.Errorf("%s is already there, --force to replace it", path))
+	}
+	source, langs, hugo := hugoLanguages()
+	if hugo != "" {
+		fmt.Printf("Found %s\n", hugo)
+	}
+	if source == "" {
+		source = "en"
+	}
+	var targets []string
+	for _, l := range langs {
+		if l != source {
+			targets = append(targets, l)
+		}
+	}
+	if len(targets) == 0 {
+		targets = []string{"nl", "fr", "de", "es"}
+	}
+	in := bufio.NewReader(os.Stdin)
+	ask := func(question string, answer string) string {
+		fmt.Printf("%s [%s]: ", question, answer)
+		ln, _ := in.ReadString('\n')
+		if ln = strings.TrimSpace(ln); ln != "" {
+			return ln
+		}
+		return answer
+	}
+	c := map[string]interface{}{}
+	c["source"] = ask("Language the site is written in", source)
+	c["languages"] = strings.Split(strings.Replace(ask("Languages to translate into", strings.Join(targets, ",")), " ", "", -1), ",")
+	tr := map[string]interface{}{}
+	switch ask("Translation provider (google, azure or llm)", "google") {
+	case "azure":
+		tr["provider"] = "azure"
+		azure := map[string]interface{}{}
+		if r := ask("Azure region (empty if it's global)", ""); r != "" {
+			azure["region"] = r
+		}
+		fmt.Println("Put the subscription key in $AZURE_TRANSLATOR_KEY")
+		tr["azure"] = azure
+	case "llm":
+		tr["provider"] = "llm"
+		llm := map[string]interface{}{"model": ask("Model", "gpt-4o-mini")}
+		if e := ask("Endpoint", "https://api.openai.com/v1"); e != "https://api.openai.com/v1" {
+			llm["endpoint"] = e
+		}
+		tr["llm"] = llm
+	default:
+		tr["credentials"] = ask("Google credentials file", "google-secret.json")
+		if id := ask("Google project ID", ""); id != "" {
+			tr["project_id"] = id
+		}
+		tr["model"] = ask("Model (nmt or base)", "nmt")
+	}
+	c["translation"] = tr
+	out, err := json.MarshalIndent(c, "", "  ")
+	checkError(err)
+	checkError(os.WriteFile(path, append(out, '\n'), 0644))
+	fmt.Printf("Wrote %s\n", path)
+	for _, p := range validateConfig(path) {
+		fmt.Printf("warning: %s\n", p)
+	}
+}
+
+var hugoConfigs = []string{
+	"hugo.toml", "hugo.yaml", "hugo.yml", "hugo.json",
+	"config.toml", "config.yaml", "config.yml", "config.json",
+	"config/_default/hugo.toml", "config/_default/hugo.yaml", "config/_default/hugo.yml", "config/_default/hugo.json",
+	"config/_default/config.toml", "config/_default/config.yaml", "config/_default/config.yml", "config/_default/config.json",
+}
+
+var (
+	tomlDefaultLang = regexp.MustCompile(`(?m)^\s*defaultContentLanguage\s*[=:]\s*["']?([\w-]+)`)
+	tomlLangTable   = regexp.MustCompile(`(?m)^\s*\[languages\.([\w-]+)\]`)
+	tomlLangFile    = regexp.MustCompile(`(?m)^\s*\[([\w-]+)\]`) // config/_default/languages.toml
+	yamlKey         = regexp.MustCompile(`^(\s*)([\w-]+):`)
+)
+
+// the site's default language and all its languages, from whichever Hugo
+// config is there. It's a rough read, not a TOML or YAML parser, but
+// it's only for suggesting answers.
+func hugoLanguages() (string, []string, string) {
+	for _, path := range hugoConfigs {
+		f, err := files.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if filepath.Ext(path) == ".json" {
+			var hc struct {
+				DefaultContentLanguage string                     `json:"defaultContentLanguage"`
+				Languages              map[string]json.RawMessage `json:"languages"`
+			}
+			json.Unmarshal(f, &hc)
+			var langs []string
+			for l := range hc.Languages {
+				langs = append(langs, l)
+			}
+			return hc.DefaultContentLanguage, langs, path
+		}
+		source := ""
+		if m := tomlDefaultLang.FindSubmatch(f); m != nil {
+			source = string(m[1])
+		}
+		langs := configLanguages(path, f)
+		// languages can have a file of their own
+		if lf := hugoLanguagesFile(path); lf != path {
+			if b, err := files.ReadFile(lf); err == nil {
+				langs = append(langs, configLanguages(lf, b)...)
+			}
+		}
+		return source, langs, path
+	}
+	return "", nil, ""
+}
+
+func configLanguages(path string, f []byte) []string {
+	var langs []string
+	if filepath.Ext(path) == ".toml" {
+		re := tomlLangTable
+		if strings.HasPrefix(filepath.Base(path), "languages.") {
+			re = tomlLangFile
+		}
+		for _, m := range re.FindAllSubmatch(f, -1) {
+			if !isValueInList(string(m[1]), langs) {
+				langs = append(langs, string(m[1]))
+			}
+		}
+		return langs
+	}
+	// YAML: the keys one level in under languages:, or at the top of a
+	// languages.yaml
+	top := strings.HasPrefix(filepath.Base(path), "languages.")
+	in := top
+	indent := -1
+	for _, ln := range strings.Split(string(f), "\n") {
+		m := yamlKey.FindStringSubmatch(ln)
+		if m == nil {
+			continue
+		}
+		depth := len(m[1])
+		if !top && depth == 0 {
+			in = m[2] == "languages"
+			indent = -1
+			continue
+		}
+		if !in {
+			continue
+		}
+		if top && depth != 0 {
+			continue
+		}
+		if indent < 0 {
+			indent = depth
+		}
+		if depth == indent {
+			langs = append(langs, m[2])
+		}
+	}
+	return langs
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// short strings like "Prerequisites" or "Next steps" show up on lots of
+// pages and should come out the same everywhere. The first translation
+// of one in a run wins.
+type reused struct {
+	translated string
+	count      int
+}
+
+var (
+	consistent   = map[string]map[string]*reused{} // language -> source -> translation
+	consistentMu sync.Mutex
+)
+
+func consistencyKey(text string) (string, bool) {
+	key := strings.TrimSpace(text)
+	n := len(strings.Fields(key))
+	return key, n > 0 && n <= conf.Consistency.MaxWords
+}
+
+// have we already translated this short string in this run?
+func reuseTranslation(lang string, text string) (string, bool) {
+	key, ok := consistencyKey(text)
+	if !ok {
+		return "", false
+	}
+	consistentMu.Lock()
+	defer consistentMu.Unlock()
+	r, ok := consistent[lang][key]
+	if !ok {
+		return "", false
+	}
+	r.count++
+	return r.translated, true
+}
+
+func rememberTranslation(lang string, text string, translated string) {
+	key, ok := consistencyKey(text)
+	if !ok {
+		return
+	}
+	consistentMu.Lock()
+	defer consistentMu.Unlock()
+	if consistent[lang] == nil {
+		consistent[lang] = map[string]*reused{}
+	}
+	if _, ok := consistent[lang][key]; !ok {
+		consistent[lang][key] = &reused{translated: translated}
+	}
+}
+
+// tell them which strings were forced to match earlier translations
+func reportConsistency() {
+	var langs []string
+	for lang := range consistent {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	for _, lang := range langs {
+		var keys []string
+		for k, r := range consistent[lang] {
+			if r.count > 0 {
+				keys = append(keys, k)
+			}
+		}
+		if len(keys) == 0 {
+			continue
+		}
+		sort.Strings(keys)
+		fmt.Printf("Reused translations (%s):\n", lang)
+		for _, k := range keys {
+			r := consistent[lang][k]
+			fmt.Printf("\t%q -> %q (%d times)\n", k, r.translated, r.count)
+		}
+	}
+}
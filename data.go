@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// data files, like the authors in data/authors.yaml or everything in
+// data/products/{lang}/, get a copy for each language with the keys in
+// data.files[].translate translated. The keys in names are people's
+// names: machine translation makes nonsense of them, so they're kept, or written in the language's own script for the
+// languages in data.names.transliterate. Everything else is copied.
+// They're read a line at a time, like front matter, so comments and
+// formatting come through as they were.
+
+var (
+	yamlDataLine = regexp.MustCompile(`^(\s*(?:-\s+)?)([\w-]+)(:[ \t]*)(.*?)\s*$`)
+	tomlDataLine = regexp.MustCompile(`^(\s*)([\w-]+)(\s*=\s*)("(?:[^"\\]|\\.)*"|'[^']*')(\s*(?:#.*)?)$`)
+	jsonDataLine = regexp.MustCompile(`^(\s*)"([\w-]+)"(\s*:\s*)("(?:[^"\\]|\\.)*")(,?\s*)$`)
+
+	// a string in a list, for "-"
+	yamlItemLine = regexp.MustCompile(`^(\s*-\s+)([^\s\[{|>&*!#].*?)\s*$`)
+	jsonItemLine = regexp.MustCompile(`^(\s*)("(?:[^"\\]|\\.)*")(,?\s*)$`)
+)
+
+// where a language's copy of f goes: out with {lang} filled in, or the
+// same name in a directory for the language next to it, data/fr/authors.yaml
+func dataTarget(f DataFile, lang string) string {
+	if f.Out != "" {
+		return filepath.Clean(strings.Replace(f.Out, "{lang}", fileCode(lang), -1))
+	}
+	return filepath.Join(filepath.Dir(f.Path), fileCode(lang), filepath.Base(f.Path))
+}
+
+// the files f is, with its path read as a template: {lang} is where the
+// language goes, data/products/{lang}/items.yaml, and * and ? match like
+// they do in a shell. Each one comes back with out set to where its
+// languages go, the same path with theirs in place of code's, unless out
+// already says.
+func dataSources(f DataFile, code string) []DataFile {
+	pattern := filepath.ToSlash(strings.Replace(f.Path, "{lang}", code, -1))
+	matches, err := fs.Glob(files, pattern)
+	checkError(err)
+	var found []DataFile
+	for _, m := range matches {
+		g := f
+		g.Path = filepath.FromSlash(m)
+		if f.Out == "" && strings.Contains(f.Path, "{lang}") {
+			// a * matches within one directory, so the parts line up
+			tmpl := strings.Split(filepath.ToSlash(f.Path), "/")
+			parts := strings.Split(m, "/")
+			for i := range parts {
+				if strings.Contains(tmpl[i], "{lang}") {
+					parts[i] = tmpl[i]
+				}
+			}
+			g.Out = filepath.FromSlash(strings.Join(parts, "/"))
+		}
+		found = append(found, g)
+	}
+	return found
+}
+
+func translateData(from string, lang string) {
+	for _, f := range conf.Data.Files {
+		for _, g := range dataSources(f, fileCode(from)) {
+			translateDataTo(g, from, lang)
+		}
+	}
+}
+
+func translateDataTo(f DataFile, from string, lang string) {
+	toFile := dataTarget(f, lang)
+	if exists(toFile) && !retranslate(f.Path, toFile) {
+		countSkipped(lang)
+		return
+	}
+	fmt.Printf("Translating:\t %s\nto: \t\t%s\n", f.Path, toFile)
+	checkError(files.MkdirAll(filepath.Dir(toFile)))
+	state.mark(f.Path, toFile, lang, "failed")
+	src, err := files.ReadFile(f.Path)
+	checkError(err)
+	out, status := "", "done"
+	if err := tryPage(func() { out = translateDataFile(f, from, lang, string(src)) }); err != nil {
+		if conf.Failures.Then == "skip" {
+			fmt.Printf("Skipping:\t %s (%s)\n", toFile, strings.TrimSpace(err.Error()))
+			countFailedPage(lang, toFile+", skipped")
+			return
+		}
+		// there's nowhere to say it's untranslated, but there's a file
+		fmt.Printf("Untranslated:\t %s (%s, it's the source for now)\n", toFile, strings.TrimSpace(err.Error()))
+		countFailedPage(lang, toFile+", "+conf.Failures.Then)
+		out, status = string(src), "untranslated"
+	}
+	checkError(writePending(toFile, []byte(out)))
+	commitTranslation(toFile)
+	state.mark(f.Path, toFile, lang, status)
+	if status == "done" {
+		countCreated(lang)
+	}
+}
+
+func hasKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+func translateDataFile(f DataFile, from string, lang string, src string) string {
+	ext := strings.ToLower(filepath.Ext(f.Path))
+	lines := strings.Split(src, "\n")
+	var out strings.Builder
+	for i := 0; i < len(lines); i++ {
+		ln := lines[i]
+		where := fmt.Sprintf("%s:%d", f.Path, i+1)
+		re := yamlDataLine
+		switch ext {
+		case ".toml":
+			re = tomlDataLine
+		case ".json":
+			re = jsonDataLine
+		}
+		m := re.FindStringSubmatchIndex(ln)
+		item := listString(ext, ln)
+		if m == nil && item != nil && hasKey(f.Translate, "-") {
+			out.WriteString(ln[:item[4]] + dataValue(ext, ln[item[4]:item[5]], func(text string) string {
+				return checkLength(f, lang, xlAt(from, lang, text, where, false), where)
+			}) + ln[item[5]:])
+		} else if m == nil || m[8] == m[9] || !(hasKey(f.Translate, ln[m[4]:m[5]]) || hasKey(f.Names, ln[m[4]:m[5]])) {
+			out.WriteString(ln)
+		} else {
+			prefix, key, value := ln[m[2]:m[3]], ln[m[4]:m[5]], ln[m[8]:m[9]]
+			conv := func(text string) string {
+				if hasKey(f.Names, key) {
+					return transliterateName(text, lang, where)
+				}
+				return checkLength(f, lang, xlAt(from, lang, text, where, false), where)
+			}
+			if b, ok := openBlock(prefix+key, value, i+1); ok && ext != ".toml" && ext != ".json" {
+				// everything indented further than the key is its value
+				depth := len(prefix)
+				for i+1 < len(lines) && (strings.TrimSpace(lines[i+1]) == "" || len(lines[i+1])-len(strings.TrimLeft(lines[i+1], " \t")) > depth) {
+					i++
+					b.lines = append(b.lines, lines[i])
+				}
+				// blank lines after it are the file's, not the value's
+				keep := 0
+				for len(b.lines) > 0 && strings.TrimSpace(b.lines[len(b.lines)-1]) == "" {
+					b.lines = b.lines[:len(b.lines)-1]
+					keep++
+				}
+				translated, _ := b.translate(conv)
+				out.WriteString(strings.TrimSuffix(translated, "\n"))
+				for ; keep > 0; keep-- {
+					out.WriteString("\n")
+				}
+			} else {
+				out.WriteString(ln[:m[8]] + dataValue(ext, value, conv) + ln[m[9]:])
+			}
+		}
+		if i < len(lines)-1 {
+			out.WriteString("\n")
+		}
+	}
+	return out.String()
+}
+
+// where the string is in a line that's just a string in a list, nil if
+// it isn't one. TOML lists are on one line, so there's no such thing.
+func listString(ext string, ln string) []int {
+	switch ext {
+	case ".json":
+		return jsonItemLine.FindStringSubmatchIndex(ln)
+	case ".toml":
+		return nil
+	}
+	return yamlItemLine.FindStringSubmatchIndex(ln)
+}
+
+// say so if a translation is longer than the file's max_chars
+func checkLength(f DataFile, lang string, translated string, where string) string {
+	if n := utf8.RuneCountInString(translated); f.MaxChars > 0 && n > f.MaxChars {
+		fmt.Printf("warning: %s (%s): the translation is %d characters, more than max_chars (%d): %s\n", where, lang, n, f.MaxChars, translated)
+	}
+	return translated
+}
+
+// conv the text of a value, written back quoted the way it was
+func dataValue(ext string, value string, conv func(string) string) string {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		text, err := strconv.Unquote(value)
+		if err != nil { // YAML and TOML have escapes Go doesn't
+			return value
+		}
+		return strconv.Quote(conv(text))
+	case strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'") && len(value) >= 2:
+		text := value[1 : len(value)-1]
+		if ext != ".toml" {
+			text = strings.ReplaceAll(text, "''", "'")
+		}
+		converted := conv(text)
+		if ext == ".toml" && strings.Contains(converted, "'") {
+			return strconv.Quote(converted)
+		}
+		return "'" + strings.ReplaceAll(converted, "'", "''") + "'"
+	}
+	// plain YAML, which needs quotes if the translation has anything YAML
+	// would read as more than text
+	converted := conv(value)
+	if strings.Contains(converted, ": ") || strings.Contains(converted, " #") {
+		return strconv.Quote(converted)
+	}
+	return strings.TrimSpace(yamlSafe(converted))
+}
+
+var (
+	transliterator     *llmProvider
+	transliteratorOnce sync.Once
+)
+
+// the llm provider, which is the one that knows how names are said
+func getTransliterator() *llmProvider {
+	transliteratorOnce.Do(func() {
+		if p, ok := provider().(*llmProvider); ok {
+			transliterator = p
+		} else {
+			transliterator = newLLMProvider()
+		}
+	})
+	return transliterator
+}
+
+// a name as it's written in lang: from data.names.known, in lang's own
+// script if it's one of data.names.transliterate, otherwise as it is
+func transliterateName(name string, lang string, where string) string {
+	if known, ok := conf.Data.Names.Known[lang][name]; ok {
+		return known
+	}
+	if !isValueInList(lang, conf.Data.Names.Transliterate) || nothingToTranslate(name) {
+		return name
+	}
+	p := getTransliterator()
+	key := "translit:" + p.Name()
+	c := loadCache()
+	if hit, ok := c.get(key, conf.Source, lang, name); ok {
+		countHit(lang, name)
+		return hit
+	}
+	if cacheReadonly {
+		countMissed(lang, name)
+		return name
+	}
+	countSent(lang, name)
+	out, err := retrying(p, []string{name}, func() ([]string, error) {
+		return p.transliterate([]string{name}, lang)
+	})
+	checkError(err)
+	audit("translit:"+p.Name(), conf.Source, lang, lang, []string{name}, where)
+	c.put(key, conf.Source, lang, name, out[0])
+	return out[0]
+}
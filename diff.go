@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+)
+
+// the first bit of a block, enough to recognise it
+func preview(b *block) string {
+	if b == nil {
+		return ""
+	}
+	text := "(" + b.kind + ")"
+	if len(b.segs) > 0 {
+		text = strings.Join(b.segs, " ")
+	}
+	if utf8.RuneCountInString(text) > 40 {
+		text = string([]rune(text)[:39]) + "…"
+	}
+	return text
+}
+
+func lineOf(b *block) string {
+	if b == nil {
+		return ""
+	}
+	return fmt.Sprint(b.line)
+}
+
+// translator diff index.en.md index.fr.md, exits 1 if they've drifted
+func diffCmd(args []string) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	all := flags.Bool("all", false, "show the paragraphs that line up too")
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		fmt.Println("usage: translate diff [--all] <source.md> <translation.md>")
+		os.Exit(1)
+	}
+	src, err := os.ReadFile(flags.Arg(0))
+	checkError(err)
+	dst, err := os.ReadFile(flags.Arg(1))
+	checkError(err)
+	_, srcBlocks := pageBlocks(src)
+	_, dstBlocks := pageBlocks(dst)
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "\tLINE\t%s\tLINE\t%s\n", flags.Arg(0), flags.Arg(1))
+	drift := 0
+	for _, p := range alignBlocks(srcBlocks, dstBlocks) {
+		// = lines up, ~ lines up but has a different number of lines,
+		// - only in the source, + only in the translation
+		mark := "="
+		switch {
+		case p.dst == nil:
+			mark = "-"
+		case p.src == nil:
+			mark = "+"
+		case len(p.src.segs) != len(p.dst.segs):
+			mark = "~"
+		}
+		if mark != "=" {
+			drift++
+		} else if !*all {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", mark, lineOf(p.src), preview(p.src), lineOf(p.dst), preview(p.dst))
+	}
+	w.Flush()
+	if drift > 0 {
+		fmt.Printf("%d paragraph(s) don't line up\n", drift)
+		os.Exit(1)
+	}
+	fmt.Println("Everything lines up")
+}
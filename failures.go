@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// failures: a page the provider keeps failing on doesn't have to end the
+// run. It's tried failures.attempts times, and then it's skipped, or the
+// source is put where the translation goes, marked untranslated: true
+// (and with a note saying so for "notice"), so the site still has a page
+// at every URL. Either way it's tried again on the next run.
+
+// what xlAt panics with, for tryPage to catch
+type pageFailure struct {
+	err error
+}
+
+// a provider error: the end of the run, unless failures.then says what
+// to do with the page instead
+func failPage(err error) {
+	if err == nil {
+		return
+	}
+	if conf.Failures.Then == "" || conf.Failures.Then == "stop" {
+		checkError(err)
+	}
+	panic(pageFailure{err})
+}
+
+// run f, with a provider failure handed back instead of ending the run
+func tryPage(f func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			pf, ok := r.(pageFailure)
+			if !ok {
+				panic(r)
+			}
+			err = pf.err
+		}
+	}()
+	f()
+	return nil
+}
+
+// doXlate, tried again if it fails, and then failures.then. Returns the
+// status to mark the page with, "" if there's nothing to put in place.
+func translatePage(from string, lang string, readFile string, writeFile string) string {
+	attempts := conf.Failures.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	var err error
+	for try := 1; try <= attempts; try++ {
+		if err = tryPage(func() { doXlate(from, lang, readFile, writeFile) }); err == nil {
+			return "done"
+		}
+		if try < attempts {
+			fmt.Printf("Retrying:\t %s (%d of %d), %s\n", writeFile, try+1, attempts, strings.TrimSpace(err.Error()))
+		}
+	}
+	dropPending(writeFile)
+	if conf.Failures.Then == "skip" {
+		fmt.Printf("Skipping:\t %s (%s)\n", writeFile, strings.TrimSpace(err.Error()))
+		countFailedPage(lang, writeFile+", skipped")
+		return ""
+	}
+	src, rerr := files.ReadFile(readFile)
+	checkError(rerr)
+	checkError(writePending(writeFile, untranslatedCopy(lang, src)))
+	fmt.Printf("Untranslated:\t %s (%s, it's the source for now)\n", writeFile, strings.TrimSpace(err.Error()))
+	countFailedPage(lang, writeFile+", "+conf.Failures.Then)
+	return "untranslated"
+}
+
+// the source marked untranslated: true, with failures.notice at the top
+// for "notice"
+func untranslatedCopy(lang string, src []byte) []byte {
+	src = bytes.TrimPrefix(src, bom)
+	var fm, body []byte
+	if end := frontMatterEnd(src); end >= 0 {
+		start := bytes.IndexByte(src, '\n') + 1
+		fm = src[start:end]
+		body = src[end:]
+		body = body[bytes.IndexByte(body, '\n')+1:]
+	} else {
+		body = src
+	}
+	var out bytes.Buffer
+	out.WriteString("---\n")
+	out.Write(fm)
+	out.WriteString("untranslated: true\n---\n")
+	if conf.Failures.Then == "notice" {
+		out.WriteString("\n*" + untranslatedNotice(lang) + "*\n\n")
+		body = bytes.TrimLeft(body, "\r\n")
+	}
+	out.Write(body)
+	return out.Bytes()
+}
+
+// failures.notice, or the English one translated if the provider will
+// do that much, or the English one
+func untranslatedNotice(lang string) string {
+	if n, ok := conf.Failures.Notice[lang]; ok {
+		return n
+	}
+	notice := "This page hasn't been translated yet, so it's in the original language for now."
+	if lang == "en" {
+		return notice
+	}
+	translated := notice
+	// it's English, whatever the site's written in
+	if err := tryPage(func() { translated = xl("en", lang, notice) }); err != nil {
+		return notice
+	}
+	return strings.TrimSpace(translated)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUntranslatedNotice(t *testing.T) {
+	memSite(t, nil)
+	conf.Source = "de"
+	current = fromProvider{}
+	if got := untranslatedNotice("fr"); !strings.HasPrefix(got, "en: ") {
+		t.Errorf("untranslatedNotice = %q, not translated from English", got)
+	}
+	if got := untranslatedNotice("en"); strings.HasPrefix(got, "en: ") {
+		t.Errorf("the English notice was translated into English: %q", got)
+	}
+	conf.Failures.Notice = map[string]string{"fr": "Pas encore traduit."}
+	if got := untranslatedNotice("fr"); got != "Pas encore traduit." {
+		t.Errorf("untranslatedNotice = %q", got)
+	}
+}
+
+func TestUntranslatedCopy(t *testing.T) {
+	memSite(t, nil)
+	conf.Failures.Notice = map[string]string{"fr": "Pas encore traduit."}
+	for _, c := range []struct {
+		then, src, want string
+	}{
+		{"copy", "---\ntitle: Hi\n---\n\nBody.\n", "---\ntitle: Hi\nuntranslated: true\n---\n\nBody.\n"},
+		{"copy", "Body.\n", "---\nuntranslated: true\n---\nBody.\n"},
+		{"notice", "---\ntitle: Hi\n---\n\nBody.\n", "---\ntitle: Hi\nuntranslated: true\n---\n\n*Pas encore traduit.*\n\nBody.\n"},
+	} {
+		conf.Failures.Then = c.then
+		if got := string(untranslatedCopy("fr", []byte(c.src))); got != c.want {
+			t.Errorf("%s %q:\n%q\nwant\n%q", c.then, c.src, got, c.want)
+		}
+	}
+}
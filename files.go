@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// the site's pages, data and config, as the walkers and translators see
+// them. It's the disk, with the mounts laid under it when there are any
+// (see overlayFiles), but it doesn't have to be: the tests keep a site in
+// memory. What the translator keeps for itself in .translator (the
+// state, cache and history) is always on disk.
+type siteFiles interface {
+	fs.StatFS
+	fs.ReadFileFS
+	WriteFile(name string, data []byte) error
+	MkdirAll(dir string) error
+	Rename(from string, to string) error
+	Remove(name string) error
+}
+
+var files siteFiles = osFiles{}
+
+// walk a directory of files, like filepath.WalkDir
+func walkFiles(root string, fn fs.WalkDirFunc) error {
+	return fs.WalkDir(files, root, func(p string, d fs.DirEntry, err error) error {
+		return fn(filepath.FromSlash(p), d, err)
+	})
+}
+
+// the disk, with paths the way the os package takes them
+type osFiles struct{}
+
+func (osFiles) Open(name string) (fs.File, error) {
+	return os.Open(name)
+}
+
+func (osFiles) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (osFiles) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+func (osFiles) WriteFile(name string, data []byte) error {
+	return os.WriteFile(name, data, 0644)
+}
+
+func (osFiles) MkdirAll(dir string) error {
+	return os.MkdirAll(dir, 0755)
+}
+
+func (osFiles) Rename(from string, to string) error {
+	return os.Rename(from, to)
+}
+
+func (osFiles) Remove(name string) error {
+	return os.Remove(name)
+}
+
+// the project, and under it the content of its Hugo modules where
+// they're mounted, the way Hugo sees them: a page that's only in a
+// module reads as if it were at content/docs/..., so links to it check
+// out. Anything written goes to the project, never the module. Listing a
+// directory only lists the project's, the mounts are walked on their own
+// (see translateMounts).
+type overlayFiles struct {
+	siteFiles
+	mounts []Mount
+}
+
+// the files, with conf.Mounts under them if it has any
+func siteFilesFor(base siteFiles) siteFiles {
+	if len(conf.Mounts) == 0 {
+		return base
+	}
+	return overlayFiles{base, conf.Mounts}
+}
+
+// the project on its own, without what the mounts add
+func projectFiles() siteFiles {
+	if o, ok := files.(overlayFiles); ok {
+		return o.siteFiles
+	}
+	return files
+}
+
+// where name is in a module, if it's under one's target
+func (o overlayFiles) mounted(name string) (string, bool) {
+	name = filepath.Clean(name)
+	for _, m := range o.mounts {
+		rel, err := filepath.Rel(filepath.Clean(m.Target), name)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return filepath.Join(m.Source, rel), true
+		}
+	}
+	return "", false
+}
+
+func (o overlayFiles) Open(name string) (fs.File, error) {
+	f, err := o.siteFiles.Open(name)
+	if src, ok := o.mounted(name); ok && errors.Is(err, fs.ErrNotExist) {
+		return o.siteFiles.Open(src)
+	}
+	return f, err
+}
+
+func (o overlayFiles) Stat(name string) (fs.FileInfo, error) {
+	fi, err := o.siteFiles.Stat(name)
+	if src, ok := o.mounted(name); ok && errors.Is(err, fs.ErrNotExist) {
+		return o.siteFiles.Stat(src)
+	}
+	return fi, err
+}
+
+func (o overlayFiles) ReadFile(name string) ([]byte, error) {
+	data, err := o.siteFiles.ReadFile(name)
+	if src, ok := o.mounted(name); ok && errors.Is(err, fs.ErrNotExist) {
+		return o.siteFiles.ReadFile(src)
+	}
+	return data, err
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// a site in memory, content/posts/a/index.en.md -> what's in it.
+// Directories are whatever the files are in.
+type memFiles struct {
+	mu    sync.Mutex
+	files fstest.MapFS
+}
+
+func newMemFiles(site map[string]string) *memFiles {
+	m := &memFiles{files: fstest.MapFS{}}
+	for name, text := range site {
+		m.WriteFile(name, []byte(text))
+	}
+	return m
+}
+
+func memName(name string) string {
+	return strings.TrimPrefix(path.Clean(filepath.ToSlash(name)), "/")
+}
+
+// a copy to read from, so a walk doesn't see the map change under it
+func (m *memFiles) snapshot() fstest.MapFS {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	snap := fstest.MapFS{}
+	for name, f := range m.files {
+		snap[name] = f
+	}
+	return snap
+}
+
+func (m *memFiles) Open(name string) (fs.File, error) {
+	return m.snapshot().Open(memName(name))
+}
+
+func (m *memFiles) Stat(name string) (fs.FileInfo, error) {
+	return m.snapshot().Stat(memName(name))
+}
+
+func (m *memFiles) ReadFile(name string) ([]byte, error) {
+	return m.snapshot().ReadFile(memName(name))
+}
+
+func (m *memFiles) WriteFile(name string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[memName(name)] = &fstest.MapFile{Data: append([]byte(nil), data...), Mode: 0644, ModTime: time.Now()}
+	return nil
+}
+
+func (m *memFiles) MkdirAll(dir string) error {
+	return nil // there are no empty directories
+}
+
+func (m *memFiles) Rename(from string, to string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[memName(from)]
+	if !ok {
+		return &fs.PathError{Op: "rename", Path: from, Err: fs.ErrNotExist}
+	}
+	delete(m.files, memName(from))
+	m.files[memName(to)] = f
+	return nil
+}
+
+func (m *memFiles) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.files[memName(name)]; !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	delete(m.files, memName(name))
+	return nil
+}
+
+// everything in it, for comparing with what a run should leave
+func (m *memFiles) dump() map[string]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := map[string]string{}
+	for name, f := range m.files {
+		out[name] = string(f.Data)
+	}
+	return out
+}
+
+// run the test on site, in memory, with the pseudo provider and the
+// translator's own files in a directory of its own
+func memSite(t *testing.T, site map[string]string) *memFiles {
+	t.Helper()
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	saved := files
+	m := newMemFiles(site)
+	files = m
+	conf = defaultConfig()
+	conf.Languages = []string{"fr"}
+	conf.Translation.Provider = "pseudo"
+	current, tm = nil, nil
+	consistent = map[string]map[string]*reused{} // what another test translated
+	t.Cleanup(func() {
+		files = saved
+		current, tm = nil, nil
+		consistent = map[string]map[string]*reused{}
+		os.Chdir(dir)
+	})
+	return m
+}
+
+func TestDoXlateInMemory(t *testing.T) {
+	m := memSite(t, map[string]string{
+		"content/a/index.en.md": "---\ntitle: \"Hello\"\n---\n\nSome text.\n\n```\ncode\n```\n",
+	})
+	doXlate("en", "fr", "content/a/index.en.md", "content/a/index.fr.md")
+	out, ok := m.dump()["content/a/index.fr.md"+pendingSuffix]
+	if !ok {
+		t.Fatalf("no translation in %v", m.dump())
+	}
+	if strings.Contains(out, "Some text.") || !strings.Contains(out, "```\ncode\n```") || !strings.HasPrefix(out, "---\ntitle: ") {
+		t.Errorf("translation:\n%s", out)
+	}
+	if _, err := os.Stat("content"); err == nil {
+		t.Errorf("the site was written to disk")
+	}
+}
+
+func TestOverlayFiles(t *testing.T) {
+	m := memSite(t, map[string]string{
+		"themes/mod/content/guide/index.en.md": "module",
+		"content/docs/own/index.en.md":         "project",
+	})
+	conf.Mounts = []Mount{{Source: "themes/mod/content", Target: "content/docs"}}
+	o := siteFilesFor(m)
+	for name, want := range map[string]string{
+		"content/docs/guide/index.en.md": "module",
+		"content/docs/own/index.en.md":   "project",
+	} {
+		if got, err := o.ReadFile(name); err != nil || string(got) != want {
+			t.Errorf("ReadFile(%s) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if _, err := o.Stat("content/docs/guide"); err != nil {
+		t.Errorf("the module's directory isn't there: %v", err)
+	}
+	if _, err := o.Stat("content/other/index.en.md"); err == nil {
+		t.Errorf("a file that's nowhere is there")
+	}
+	if err := o.WriteFile("content/docs/guide/index.fr.md", []byte("fr")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.dump()["content/docs/guide/index.fr.md"]; !ok {
+		t.Errorf("the translation wasn't written to the project")
+	}
+	files = o
+	if projectFiles() != siteFiles(m) {
+		t.Errorf("projectFiles() isn't the project")
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+)
+
+// an external program that fixes up segments before they're translated,
+// after, or both. It's started once per run and talks JSON, one object
+// per line: we write {"stage": "pre", "from": "en", "to": "fr", "text":
+// "..."} to its stdin and it answers {"text": "..."} (or {"error": "..."})
+// on its stdout.
+type filterProc struct {
+	name   string
+	stages []string
+	in     io.WriteCloser
+	out    *bufio.Reader
+	cmd    *exec.Cmd
+	mu     sync.Mutex // one segment at a time
+}
+
+type filterRequest struct {
+	Stage string `json:"stage"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Text  string `json:"text"`
+}
+
+type filterResponse struct {
+	Text  string `json:"text"`
+	Error string `json:"error"`
+}
+
+var filterProcs []*filterProc
+
+func loadFilters() []*filterProc {
+	if filterProcs != nil {
+		return filterProcs
+	}
+	filterProcs = []*filterProc{}
+	for _, f := range conf.Filters {
+		if len(f.Command) == 0 {
+			checkError(fmt.Errorf("a filter needs a command"))
+		}
+		p := &filterProc{name: f.Command[0], stages: f.Stages}
+		if len(p.stages) == 0 {
+			p.stages = []string{"pre", "post"}
+		}
+		p.cmd = exec.Command(f.Command[0], f.Command[1:]...)
+		p.cmd.Stderr = os.Stderr
+		var err error
+		p.in, err = p.cmd.StdinPipe()
+		checkError(err)
+		out, err := p.cmd.StdoutPipe()
+		checkError(err)
+		p.out = bufio.NewReader(out)
+		checkError(p.cmd.Start())
+		filterProcs = append(filterProcs, p)
+	}
+	return filterProcs
+}
+
+func (p *filterProc) run(stage string, from string, to string, text string) (string, error) {
+	req, err := json.Marshal(filterRequest{stage, from, to, text})
+	if err != nil {
+		return "", err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, err := p.in.Write(append(req, '\n')); err != nil {
+		return "", fmt.Errorf("filter %s: %v", p.name, err)
+	}
+	line, err := p.out.ReadBytes('\n')
+	if err != nil {
+		return "", fmt.Errorf("filter %s: %v", p.name, err)
+	}
+	var resp filterResponse
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return "", fmt.Errorf("filter %s: %v", p.name, err)
+	}
+	if resp.Error != "" {
+		return "", fmt.Errorf("filter %s: %s", p.name, resp.Error)
+	}
+	return resp.Text, nil
+}
+
+// pass text through every filter that wants this stage, in config order
+func runFilters(stage string, from string, to string, text string) string {
+	for _, p := range loadFilters() {
+		if !isValueInList(stage, p.stages) {
+			continue
+		}
+		out, err := p.run(stage, from, to, text)
+		checkError(err)
+		text = out
+	}
+	return text
+}
+
+// let the filters know we're done and wait for them to go away
+func closeFilters() {
+	for _, p := range filterProcs {
+		p.in.Close()
+		p.cmd.Wait()
+	}
+}
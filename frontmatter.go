@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// the lines between the --- delimiters, nil if there's no front matter
+func frontMatterLines(src []byte) []string {
+	src = bytes.TrimPrefix(src, bom)
+	end := frontMatterEnd(src)
+	if end < 0 {
+		return nil
+	}
+	start := bytes.IndexByte(src, '\n') + 1
+	return strings.Split(strings.TrimRight(string(src[start:end]), "\r\n"), "\n")
+}
+
+func unquote(v string) string {
+	v = strings.TrimSpace(v)
+	if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+		return v[1 : len(v)-1]
+	}
+	return v
+}
+
+// a translated front matter value, quoted if it starts with something
+// YAML would read as more than a string, like [ or {
+func yamlSafe(v string) string {
+	t := strings.TrimSpace(v)
+	if t == "" || !strings.ContainsRune("[{&*!|>%@`", rune(t[0])) {
+		return v
+	}
+	return " \"" + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(t) + "\""
+}
+
+// a top level "key: value" from the front matter. Keys are matched the
+// way Hugo does, without caring about case.
+func fmValue(lines []string, key string) (string, bool) {
+	for _, ln := range lines {
+		kv := strings.SplitN(strings.TrimRight(ln, "\r"), ":", 2)
+		if len(kv) == 2 && !strings.HasPrefix(kv[0], " ") && strings.EqualFold(kv[0], key) {
+			return unquote(kv[1]), true
+		}
+	}
+	return "", false
+}
+
+// a value one level down, either as an indented block
+//
+//	_build:
+//	  render: never
+//
+// or inline as _build: {render: never}
+func fmNested(lines []string, parent string, key string) (string, bool) {
+	for i, ln := range lines {
+		kv := strings.SplitN(strings.TrimRight(ln, "\r"), ":", 2)
+		if len(kv) != 2 || strings.HasPrefix(kv[0], " ") || !strings.EqualFold(kv[0], parent) {
+			continue
+		}
+		if v := strings.TrimSpace(kv[1]); strings.HasPrefix(v, "{") {
+			for _, f := range strings.Split(strings.Trim(v, "{}"), ",") {
+				fkv := strings.SplitN(f, ":", 2)
+				if len(fkv) == 2 && strings.EqualFold(strings.TrimSpace(fkv[0]), key) {
+					return unquote(fkv[1]), true
+				}
+			}
+			return "", false
+		}
+		for _, sub := range lines[i+1:] {
+			if !strings.HasPrefix(sub, " ") && !strings.HasPrefix(sub, "\t") {
+				break
+			}
+			skv := strings.SplitN(strings.TrimRight(sub, "\r"), ":", 2)
+			if len(skv) == 2 && strings.EqualFold(strings.TrimSpace(skv[0]), key) {
+				return unquote(skv[1]), true
+			}
+		}
+	}
+	return "", false
+}
+
+// a multi line value, description: > or |, and the lines under it
+type blockScalar struct {
+	key    string
+	header string // the indicator, with any chomping or indent after it: |, >-, |+
+	line   int    // where the value starts, for the transcript
+	lines  []string
+}
+
+var blockIndicator = regexp.MustCompile(`^[|>][-+0-9]*$`)
+
+// a key whose value is a block scalar
+func openBlock(key string, value string, line int) (*blockScalar, bool) {
+	v := strings.TrimSpace(value)
+	if !blockIndicator.MatchString(v) {
+		return nil, false
+	}
+	return &blockScalar{key: key, header: v, line: line}, true
+}
+
+// still in the block: it's everything indented under the key, and the
+// blank lines between
+func (b *blockScalar) takes(ln string) bool {
+	return strings.TrimSpace(ln) == "" || strings.HasPrefix(ln, " ") || strings.HasPrefix(ln, "\t")
+}
+
+// translate the value a paragraph at a time, rather than a line at a
+// time, and write it out the same way: same indicator, same indentation,
+// wrapped about as wide as it was. Returns the lines and the first
+// paragraph translated (a title's, for the slug).
+func (b *blockScalar) translate(tr func(string) string) (string, string) {
+	indent := ""
+	width := 0
+	wrapped := false
+	var paras [][]string
+	var cur []string
+	trailing := 0 // blank lines at the end, which |+ keeps
+	for _, ln := range b.lines {
+		ln = strings.TrimRight(ln, " \r")
+		if ln == "" {
+			if cur != nil {
+				paras = append(paras, cur)
+				cur = nil
+			}
+			trailing++
+			continue
+		}
+		trailing = 0
+		if indent == "" {
+			indent = ln[:len(ln)-len(strings.TrimLeft(ln, " \t"))]
+		}
+		if n := utf8.RuneCountInString(ln); n > width {
+			width = n
+		}
+		cur = append(cur, strings.TrimSpace(ln))
+		if len(cur) > 1 {
+			wrapped = true
+		}
+	}
+	if cur != nil {
+		paras = append(paras, cur)
+	}
+	var out strings.Builder
+	out.WriteString(b.key + ": " + b.header + "\n")
+	first := ""
+	for i, p := range paras {
+		if i > 0 {
+			out.WriteString("\n")
+		}
+		translated := tr(strings.Join(p, " "))
+		if i == 0 {
+			first = translated
+		}
+		if !wrapped { // one line a paragraph, and so it stays
+			out.WriteString(indent + translated + "\n")
+			continue
+		}
+		for _, l := range wrapWords(translated, width-len(indent)) {
+			out.WriteString(indent + l + "\n")
+		}
+	}
+	out.WriteString(strings.Repeat("\n", trailing))
+	return out.String(), first
+}
+
+// text in lines no longer than width, except for words that are longer
+// on their own
+func wrapWords(text string, width int) []string {
+	var lines []string
+	line := ""
+	for _, w := range strings.Fields(text) {
+		if line != "" && utf8.RuneCountInString(line)+1+utf8.RuneCountInString(w) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += w
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// the date formats Hugo sites actually use
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// the first of keys that's there and is a date. Hugo has a few names for
+// most dates (publishDate, pubdate, published).
+func fmDate(lines []string, keys ...string) (time.Time, bool) {
+	for _, k := range keys {
+		v, ok := fmValue(lines, k)
+		if !ok {
+			continue
+		}
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// a front matter line with a plain number for a value: weight, order,
+// image width and height and so on
+var numericField = regexp.MustCompile(`^\s*(- )?[\w.-]+:\s*-?[0-9]+(\.[0-9]+)?\s*$`)
+
+// every numeric field in the source has to come out of translation
+// exactly as it went in. A weight that got quoted or changed reshuffles
+// the site's menus and nobody notices until someone goes looking.
+func checkNumericFields(name string, src []byte, translated []byte) error {
+	have := map[string]int{}
+	for _, ln := range frontMatterLines(translated) {
+		have[strings.TrimRight(ln, " \r")]++
+	}
+	for _, ln := range frontMatterLines(src) {
+		ln = strings.TrimRight(ln, " \r")
+		if !numericField.MatchString(ln) {
+			continue
+		}
+		if have[ln] == 0 {
+			return fmt.Errorf("%s: front matter %q didn't survive translation", name, strings.TrimSpace(ln))
+		}
+		have[ln]--
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// cache.fuzzy: text that's nearly the same as something already
+// translated (a typo fixed, a word changed in a long paragraph) gets the
+// translation we already have instead of a new one. It's off unless it's
+// set, because the reused translation still says what the old text said.
+
+var (
+	fuzzyIndex   = map[string][]*cacheEntry{} // provider, from and to -> what was cached before this run
+	fuzzyIndexMu sync.Mutex
+)
+
+func fuzzyCandidates(provider string, from string, to string) []*cacheEntry {
+	fuzzyIndexMu.Lock()
+	defer fuzzyIndexMu.Unlock()
+	k := provider + "\x00" + from + "\x00" + to
+	if entries, ok := fuzzyIndex[k]; ok {
+		return entries
+	}
+	c := loadCache()
+	now := time.Now()
+	var entries []*cacheEntry
+	c.mu.Lock()
+	for _, e := range c.Entries {
+		if e.Provider == provider && e.From == from && e.Lang == to && e.Source != "" && !e.expired(now) {
+			entries = append(entries, e)
+		}
+	}
+	c.mu.Unlock()
+	fuzzyIndex[k] = entries
+	return entries
+}
+
+// how alike two segments are, 1 for the same
+func similarity(a string, b string) float64 {
+	longest := len(a)
+	if len(b) > longest {
+		longest = len(b)
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(editDistance(a, b))/float64(longest)
+}
+
+// the cached translation of the segment most like text, if one is at
+// least cache.fuzzy alike. text is what would be sent (masked), so it's
+// only compared with segments that have the same placeholders in the same
+// order, which then stand for this segment's bits when they're put back.
+func fuzzyTranslation(from string, to string, text string) (string, bool) {
+	min := conf.Cache.Fuzzy
+	if min <= 0 || pivotFor(from, to) != "" {
+		return "", false
+	}
+	key := providerKey(provider(), to)
+	c := loadCache()
+	if _, ok := c.get(key, from, to, text); ok { // the real thing is better
+		return "", false
+	}
+	holes := strings.Join(placeholderRe.FindAllString(text, -1), " ")
+	var best *cacheEntry
+	bestScore := 0.0
+	for _, e := range fuzzyCandidates(key, from, to) {
+		// too different in length to be close enough, and editDistance isn't cheap
+		short, long := len(e.Source), len(text)
+		if short > long {
+			short, long = long, short
+		}
+		if float64(short) < float64(long)*min {
+			continue
+		}
+		if strings.Join(placeholderRe.FindAllString(e.Source, -1), " ") != holes {
+			continue
+		}
+		score := similarity(e.Source, text)
+		if score >= min && (score > bestScore || (score == bestScore && e.Created.After(best.Created))) {
+			best, bestScore = e, score
+		}
+	}
+	if best == nil {
+		return "", false
+	}
+	return best.Text, true
+}
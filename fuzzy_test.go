@@ -0,0 +1,53 @@
+package main
+
+import "testing"
+
+func TestSimilarity(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		want float64
+	}{
+		{"", "", 1},
+		{"abcd", "abcd", 1},
+		{"abcd", "abce", 0.75},
+		{"abcd", "", 0},
+		{"abcdefghij", "abcdefghi", 0.9},
+	} {
+		if got := similarity(c.a, c.b); got != c.want {
+			t.Errorf("similarity(%q, %q) = %v, want %v", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestFuzzyTranslation(t *testing.T) {
+	memSite(t, nil)
+	current = markProvider{}
+	fuzzyIndex = map[string][]*cacheEntry{}
+	defer func() { fuzzyIndex = map[string][]*cacheEntry{} }()
+	c := loadCache()
+	c.put("mark", "en", "fr", "The quick brown fox jumps over the lazy dog.", "Le renard.")
+	c.put("mark", "en", "fr", "Click ⟦T0000⟧ to save the page you are on.", "Cliquez ⟦T0000⟧.")
+	c.put("mark", "en", "de", "The quick brown fox jumps over the lazy cat.", "Der Fuchs.")
+	for _, c := range []struct {
+		fuzzy      float64
+		to, text   string
+		want       string
+		wantReused bool
+	}{
+		{0.9, "fr", "The quick brown fox jumps over the lazy cat.", "Le renard.", true},
+		{0, "fr", "The quick brown fox jumps over the lazy cat.", "", false},    // off
+		{0.99, "fr", "The quick brown fox jumps over the lazy cat.", "", false}, // not close enough
+		{0.9, "fr", "The quick brown fox jumps over the lazy dog.", "", false},  // cached as it is
+		{0.9, "fr", "Short.", "", false},                                        // too different in length
+		{0.9, "fr", "Click ⟦T0000⟧ to save the page you are in.", "Cliquez ⟦T0000⟧.", true},
+		{0.9, "fr", "Click ⟦T0001⟧ to save the page you are on.", "", false},            // another placeholder
+		{0.9, "de", "The quick brown fox jumps over the lazy dog.", "Der Fuchs.", true}, // only German
+		{0.9, "es", "The quick brown fox jumps over the lazy dog.", "", false},
+	} {
+		conf.Cache.Fuzzy = c.fuzzy
+		got, ok := fuzzyTranslation("en", c.to, c.text)
+		if got != c.want || ok != c.wantReused {
+			t.Errorf("fuzzy %v, %s, %q: %q, %v, want %q, %v", c.fuzzy, c.to, c.text, got, ok, c.want, c.wantReused)
+		}
+	}
+}
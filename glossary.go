@@ -0,0 +1,40 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// Google glossaries (v3 only) keep product names and technical terms the
+// same everywhere. translation.glossaries has "en/fr" (or "*/fr") -> the
+// glossary, either its ID or its whole projects/.../glossaries/... name.
+// A glossary lives in a region, so translation.location has to be that
+// region too.
+func glossaryFor(from string, to string) string {
+	for _, k := range []string{from + "/" + to, "*/" + to} {
+		if g, ok := conf.Translation.Glossaries[k]; ok {
+			return g
+		}
+	}
+	return ""
+}
+
+func glossaryName(parent string, g string) string {
+	if strings.Contains(g, "/") {
+		return g
+	}
+	return parent + "/glossaries/" + g
+}
+
+// the glossaries go in the cache key, so adding or changing one means
+// the language gets translated again with it
+func (googleProvider) variant(lang string) string {
+	var gs []string
+	for k, g := range conf.Translation.Glossaries {
+		if strings.HasSuffix(k, "/"+lang) {
+			gs = append(gs, k+"="+g)
+		}
+	}
+	sort.Strings(gs)
+	return strings.Join(gs, ",")
+}
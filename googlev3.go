@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"google.golang.org/api/option"
+	translatev3 "google.golang.org/api/translate/v3"
+)
+
+// Google's Cloud Translation v3 (Advanced) API, which is what the google
+// provider uses unless translation.api says "v2". It wants a project, and
+// it's what glossaries, batch jobs and per project quotas need.
+
+var (
+	v3Service *translatev3.Service
+	v3Err     error
+	v3Once    sync.Once
+)
+
+func googleV3() (*translatev3.Service, error) {
+	v3Once.Do(func() {
+		v3Service, v3Err = translatev3.NewService(context.Background(), option.WithCredentialsFile(conf.Translation.Credentials))
+	})
+	return v3Service, v3Err
+}
+
+func useV2() bool {
+	return conf.Translation.API == "v2"
+}
+
+// translation.project_id, or the project the service account key is for
+func googleProject() string {
+	if conf.Translation.ProjectID != "" {
+		return conf.Translation.ProjectID
+	}
+	f, err := os.ReadFile(conf.Translation.Credentials)
+	if err != nil {
+		return ""
+	}
+	var key struct {
+		ProjectID string `json:"project_id"`
+	}
+	json.Unmarshal(f, &key)
+	return key.ProjectID
+}
+
+// projects/<project>/locations/<location>, global unless it's set
+func googleParent(location string) (string, error) {
+	project := googleProject()
+	if project == "" {
+		return "", fmt.Errorf("the v3 translation API needs translation.project_id (or translation.api set to v2)")
+	}
+	if location == "" {
+		location = "global"
+	}
+	return fmt.Sprintf("projects/%s/locations/%s", project, location), nil
+}
+
+// all of texts in one request
+func translateV3(texts []string, from string, to string) ([]string, error) {
+	svc, err := googleV3()
+	if err != nil {
+		return nil, err
+	}
+	parent, err := googleParent(conf.Translation.Location)
+	if err != nil {
+		return nil, err
+	}
+	req := &translatev3.TranslateTextRequest{
+		Contents:           texts,
+		SourceLanguageCode: apiCode(from),
+		TargetLanguageCode: apiCode(to),
+		MimeType:           "text/html", // what v2 assumed, and what the fixes afterwards expect
+	}
+	if m := conf.Translation.Model; m != "" && m != "nmt" {
+		req.Model = parent + "/models/general/" + m
+	}
+	if g := glossaryFor(from, to); g != "" {
+		req.GlossaryConfig = &translatev3.TranslateTextGlossaryConfig{Glossary: glossaryName(parent, g)}
+	}
+	resp, err := svc.Projects.Locations.TranslateText(parent, req).Context(context.Background()).Do()
+	if err != nil {
+		return nil, fmt.Errorf("TranslateText: %v", err)
+	}
+	translations := resp.Translations
+	if req.GlossaryConfig != nil { // there's one without the glossary too
+		translations = resp.GlossaryTranslations
+	}
+	if len(translations) != len(texts) {
+		return nil, fmt.Errorf("TranslateText: sent %d segments and got %d back", len(texts), len(translations))
+	}
+	var out []string
+	for _, t := range translations {
+		out = append(out, t.TranslatedText)
+	}
+	return out, nil
+}
+
+func languagesV3() ([]string, error) {
+	svc, err := googleV3()
+	if err != nil {
+		return nil, err
+	}
+	parent, err := googleParent(conf.Translation.Location)
+	if err != nil {
+		return nil, err
+	}
+	langs, err := svc.Projects.Locations.GetSupportedLanguages(parent).Context(context.Background()).Do()
+	if err != nil {
+		return nil, err
+	}
+	var codes []string
+	for _, l := range langs.Languages {
+		codes = append(codes, l.LanguageCode)
+	}
+	return codes, nil
+}
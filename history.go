@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+var historyPath = filepath.Join(stateDir, "history.jsonl")
+
+// one line of history.jsonl
+type runRecord struct {
+	ID        string       `json:"id"`
+	Started   time.Time    `json:"started"`
+	Finished  time.Time    `json:"finished"`
+	Args      []string     `json:"args"`
+	Profile   string       `json:"profile,omitempty"`
+	Config    *Config      `json:"config"`
+	Languages []*langStats `json:"languages"`
+	Total     *langStats   `json:"total"`
+	Failed    []string     `json:"failed,omitempty"`  // translations that didn't get finished
+	Backups   int          `json:"backups"`           // files rollback would put back or remove
+	Error     string       `json:"error,omitempty"`   // what stopped the run
+	Resumed   bool         `json:"resumed,omitempty"` // carrying on from an interrupted run with the same id
+}
+
+var (
+	running    *runRecord
+	unfinished = map[string]bool{} // marked failed and not done yet
+	runMu      sync.Mutex
+)
+
+// the config as the history keeps it, without anything secret: the Azure
+// key, a password in the remote cache's URL, and the profiles, which
+// could have either (the one in use is already in the rest of it)
+func redactedConfig(c *Config) *Config {
+	r := *c
+	if r.Translation.Azure.Key != "" {
+		r.Translation.Azure.Key = "redacted"
+	}
+	if u, err := url.Parse(r.Cache.Remote.URL); err == nil && u.User != nil {
+		u.User = url.User("redacted")
+		r.Cache.Remote.URL = u.String()
+	}
+	r.Profiles = nil
+	return &r
+}
+
+func startRun() {
+	running = &runRecord{ID: runID, Started: time.Now(), Args: os.Args[1:], Profile: profile, Config: redactedConfig(conf), Resumed: resume}
+	startCheckpoint()
+}
+
+// keep track of which pages this run didn't get to the end of
+func notePage(target string, status string) {
+	runMu.Lock()
+	var err error
+	if status == "failed" {
+		unfinished[target] = true
+	} else {
+		delete(unfinished, target)
+		err = checkpointPage(target)
+	}
+	runMu.Unlock()
+	checkError(err) // not with runMu held, finishRun takes it
+}
+
+// append the run to the history, err is what killed it if it didn't
+// finish
+func finishRun(err error) {
+	closeAudit()
+	runMu.Lock()
+	r := running
+	running = nil // once, even if writing it goes wrong
+	var failed []string
+	for target := range unfinished {
+		failed = append(failed, target)
+	}
+	runMu.Unlock()
+	if r == nil {
+		return
+	}
+	if tm != nil { // what it's paid for so far, even if it died halfway through a page
+		if serr := tm.compact(); serr != nil {
+			fmt.Printf("warning: the cache wasn't saved: %v\n", serr)
+		}
+	}
+	if err == nil {
+		os.Remove(checkpointPath)
+	} else {
+		fmt.Printf("Run %s didn't finish, translate --resume carries on from where it stopped\n", r.ID)
+	}
+	sort.Strings(failed)
+	r.Failed = failed
+	r.Finished = time.Now()
+	r.Languages, r.Total = summarize()
+	r.Backups = len(backups) // not locked, this can be called with it held
+	if err != nil {
+		r.Error = err.Error()
+	}
+	out, err := json.Marshal(r)
+	checkError(err)
+	checkError(os.MkdirAll(stateDir, 0755))
+	f, err := os.OpenFile(historyPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	checkError(err)
+	defer f.Close()
+	_, err = f.Write(append(out, '\n'))
+	checkError(err)
+}
+
+func loadHistory() []*runRecord {
+	f, err := os.Open(historyPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	checkError(err)
+	defer f.Close()
+	var runs []*runRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024) // the config makes for long lines
+	for scanner.Scan() {
+		r := &runRecord{}
+		checkError(json.Unmarshal(scanner.Bytes(), r))
+		runs = append(runs, r)
+	}
+	checkError(scanner.Err())
+	return runs
+}
+
+// translate history [run-id]
+func historyCmd(args []string) {
+	runs := loadHistory()
+	if len(args) == 1 { // everything about one run
+		for _, r := range runs {
+			if r.ID == args[0] {
+				out, err := json.MarshalIndent(r, "", "  ")
+				checkError(err)
+				fmt.Println(string(out))
+				return
+			}
+		}
+		checkError(fmt.Errorf("no run %s in %s", args[0], historyPath))
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "RUN\tTIME\tCREATED\tSKIPPED\tCHARS SENT\tCOST\tFAILED\tROLLBACK")
+	for _, r := range runs {
+		status := fmt.Sprint(len(r.Failed))
+		if r.Error != "" {
+			status += " (died)"
+		}
+		rollback := "-"
+		if r.Backups > 0 {
+			rollback = fmt.Sprintf("%d files", r.Backups)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t$%.2f\t%s\t%s\n", r.ID, r.Finished.Sub(r.Started).Round(time.Millisecond),
+			r.Total.Created, r.Total.Skipped, r.Total.Chars, r.Total.Cost, status, rollback)
+	}
+	w.Flush()
+}
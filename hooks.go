@@ -0,0 +1,75 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// links.output "render-hooks": a link or image in a translation is
+// written the one way a site's render-link and render-image hooks are
+// written for, whatever the provider did to it. No space between ! and
+// [ or ] and (, none around the destination, <> around a destination
+// with a space in it (or it's not a link at all), and the title in
+// double quotes.
+
+var (
+	looseLink = regexp.MustCompile(`(^|\s)(!) \[|\]\s+\(`)
+	hookLink  = regexp.MustCompile(`(!?\[[^\]]*\])\(\s*(<[^>]*>|[^)"'\s][^)"']*?|)\s*("[^"]*"|'[^']*'|\([^)]*\))?\s*\)`)
+)
+
+// a destination and title the way the hooks expect them
+func hookDestination(dest string, title string) string {
+	dest = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(dest, "<"), ">"))
+	if strings.ContainsAny(dest, " \t") {
+		dest = "<" + dest + ">"
+	}
+	if title == "" {
+		return dest
+	}
+	if inner := title[1 : len(title)-1]; !strings.Contains(inner, `"`) {
+		title = `"` + inner + `"`
+	}
+	return dest + " " + title
+}
+
+// a link the way the hooks expect it
+func hookForm(link string) string {
+	m := hookLink.FindStringSubmatch(link)
+	return m[1] + "(" + hookDestination(m[2], m[3]) + ")"
+}
+
+// every link and image in text, outside code, as the render hooks
+// expect them. ] ( only becomes ]( if source had more links than text
+// does, "[sic] (sometimes)" is just prose, and ! [ only becomes ![ if it
+// had more images, "Super ! [lien](x)" is French.
+func hookLinks(source string, text string) string {
+	if conf.Links.Output != "render-hooks" {
+		return text
+	}
+	var out strings.Builder
+	last := 0
+	looseLinks := len(linkDest.FindAllString(source, -1)) > len(linkDest.FindAllString(text, -1))
+	looseImages := strings.Count(source, "![") > strings.Count(text, "![")
+	fix := func(s string) string {
+		s = looseLink.ReplaceAllStringFunc(s, func(m string) string {
+			if strings.HasSuffix(m, "(") {
+				if looseLinks {
+					return "]("
+				}
+				return m
+			}
+			if looseImages {
+				return strings.TrimSuffix(m, " [") + "["
+			}
+			return m
+		})
+		return hookLink.ReplaceAllStringFunc(s, hookForm)
+	}
+	for _, c := range inlineCode.FindAllStringIndex(text, -1) {
+		out.WriteString(fix(text[last:c[0]]))
+		out.WriteString(text[c[0]:c[1]])
+		last = c[1]
+	}
+	out.WriteString(fix(text[last:]))
+	return out.String()
+}
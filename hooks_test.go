@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestHookLinks(t *testing.T) {
+	conf = defaultConfig()
+	conf.Links.Output = "render-hooks"
+	for _, c := range []struct {
+		source, text, want string
+	}{
+		{"See [the guide](/docs/).", "Voir [le guide]( /docs/ ).", "Voir [le guide](/docs/)."},
+		{"See [the guide](/docs/).", "Voir [le guide] (/docs/).", "Voir [le guide](/docs/)."},
+		{"An ![image](cat.png).", "Une ! [image](cat.png).", "Une ![image](cat.png)."},
+		{"See [it](/a b/).", "Voir [ça](/a b/).", "Voir [ça](</a b/>)."},
+		{"See [it](/a/ 'Title').", "Voir [ça](/a/ 'Titre').", `Voir [ça](/a/ "Titre").`},
+		{"Say [sic] (sometimes).", "Dire [sic] (parfois).", "Dire [sic] (parfois)."},
+		{"Great! [More](/more/).", "Super ! [Plus](/more/).", "Super ! [Plus](/more/)."},
+		{"Run `[x] (y)` and [it](/a/).", "Lancer `[x] (y)` et [ça] (/a/).", "Lancer `[x] (y)` et [ça](/a/)."},
+	} {
+		if got := hookLinks(c.source, c.text); got != c.want {
+			t.Errorf("hookLinks(%q) = %q, want %q", c.text, got, c.want)
+		}
+	}
+	conf.Links.Output = ""
+	if got := hookLinks("See [it](/a/).", "Voir [ça] (/a/)."); got != "Voir [ça] (/a/)." {
+		t.Errorf("changed without render-hooks: %q", got)
+	}
+}
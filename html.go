@@ -0,0 +1,36 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// raw HTML in the Markdown. The API mangles hrefs, srcs, data-*
+// attributes and everything else inside a tag, so tags are hidden from
+// it whole and only the text between them is translated. The exception
+// is alt and title, which are for people.
+var (
+	htmlTag  = regexp.MustCompile(`</?[A-Za-z][A-Za-z0-9-]*(\s[^<>]*)?/?>`)
+	textAttr = regexp.MustCompile(`(?i)(\s(?:alt|title)\s*=\s*)("[^"]*"|'[^']*')`)
+)
+
+// the tag with its alt and title translated and nothing else touched
+func translateTagAttrs(tag string, tr func(string) string) string {
+	return textAttr.ReplaceAllStringFunc(tag, func(a string) string {
+		m := textAttr.FindStringSubmatch(a)
+		quote, value := m[2][:1], m[2][1:len(m[2])-1]
+		if strings.TrimSpace(value) == "" {
+			return a
+		}
+		translated := strings.Replace(tr(value), quote, "", -1) // it can't end the attribute early
+		return m[1] + quote + translated + quote
+	})
+}
+
+// hide HTML tags from the API, translating their alt text on the way
+func (m *masker) maskTags(text string, from string, to string) string {
+	return htmlTag.ReplaceAllStringFunc(text, func(tag string) string {
+		m.saved = append(m.saved, translateTagAttrs(tag, func(s string) string { return xl(from, to, s) }))
+		return placeholder(len(m.saved) - 1)
+	})
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTranslateTagAttrs(t *testing.T) {
+	tr := func(s string) string { return "<" + s + ">" }
+	for tag, want := range map[string]string{
+		`<img src="cat.png" alt="A cat">`:          `<img src="cat.png" alt="<A cat>">`,
+		`<img src='cat.png' alt='A cat' />`:        `<img src='cat.png' alt='<A cat>' />`,
+		`<abbr title="HyperText Markup Language">`: `<abbr title="<HyperText Markup Language>">`,
+		`<img src="cat.png" ALT="A cat">`:          `<img src="cat.png" ALT="<A cat>">`,
+		`<img src="cat.png" alt="">`:               `<img src="cat.png" alt="">`,
+		`<a href="/docs/" data-title="Docs">`:      `<a href="/docs/" data-title="Docs">`,
+	} {
+		if got := translateTagAttrs(tag, tr); got != want {
+			t.Errorf("translateTagAttrs(%q) = %q, want %q", tag, got, want)
+		}
+	}
+	// a quote in the translation can't end the attribute
+	if got := translateTagAttrs(`<img alt="A cat">`, func(string) string { return `Un "chat"` }); got != `<img alt="Un chat">` {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestMaskTags(t *testing.T) {
+	memSite(t, nil)
+	current = markProvider{}
+	for text, want := range map[string]string{
+		`See <a href="/docs/start/">the guide</a>.`:           `«See <a href="/docs/start/">the guide</a>.»`,
+		`<span class="note" data-id="x">Careful</span> here.`: `«<span class="note" data-id="x">Careful</span> here.»`,
+		`A <img src="cat.png" alt="cat"> here.`:               `«A <img src="cat.png" alt="«cat»"> here.»`,
+		`Two < three and four > one.`:                         `«Two < three and four > one.»`,
+	} {
+		send, _ := maskSegment("en", "fr", text)
+		if strings.Contains(send, "href") || strings.Contains(send, "class=") || strings.Contains(send, "src=") {
+			t.Errorf("%q: sent %q", text, send)
+		}
+		if got := xl("en", "fr", text); got != want {
+			t.Errorf("%q: got %q, want %q", text, got, want)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+// a language added to translator.json doesn't get built until the Hugo
+// config has it too. translate config hugo says what's missing from its
+// [languages], as a patch, and --write adds it.
+
+// where a Hugo config's languages are: a languages.toml (or .yaml) next
+// to it if there's one, otherwise the config itself
+func hugoLanguagesFile(path string) string {
+	for _, ext := range []string{".toml", ".yaml", ".yml"} {
+		lf := filepath.Join(filepath.Dir(path), "languages"+ext)
+		if exists(lf) {
+			return lf
+		}
+	}
+	return path
+}
+
+// the source and the languages we translate into that the Hugo config
+// doesn't have, and the config. Nothing if there's no Hugo config.
+func missingHugoLanguages() ([]string, []string, string) {
+	_, have, path := hugoLanguages()
+	if path == "" {
+		return nil, nil, ""
+	}
+	var missing []string
+	for _, l := range append([]string{conf.Source}, conf.Languages...) {
+		if !isValueInList(l, have) {
+			missing = append(missing, l)
+		}
+	}
+	return missing, have, path
+}
+
+// what the language calls itself, Français for fr
+func languageName(lang string) string {
+	name := display.Self.Name(language.Make(apiCode(lang)))
+	if name == "" {
+		return lang
+	}
+	r, n := utf8.DecodeRuneInString(name)
+	return string(unicode.ToUpper(r)) + name[n:]
+}
+
+type hugoLanguage struct {
+	key    string
+	fields [][2]string // in order, the values already quoted
+}
+
+// the settings a new language gets: its name, after the ones that are
+// there already, and its content directory in the directory layout
+func newHugoLanguages(missing []string, have []string) []hugoLanguage {
+	var out []hugoLanguage
+	for i, l := range missing {
+		q, _ := json.Marshal(languageName(l))
+		hl := hugoLanguage{key: l, fields: [][2]string{
+			{"languageName", string(q)},
+			{"weight", fmt.Sprint(len(have) + i + 1)},
+		}}
+		if apiCode(l) != l {
+			q, _ := json.Marshal(apiCode(l))
+			hl.fields = append(hl.fields, [2]string{"languageCode", string(q)})
+		}
+		if dirLayout() {
+			q, _ := json.Marshal(filepath.ToSlash(contentDir(l)))
+			hl.fields = append(hl.fields, [2]string{"contentDir", string(q)})
+		}
+		out = append(out, hl)
+	}
+	return out
+}
+
+// the lines to add to file, and the line they go after
+func hugoLanguageLines(file string, lines []string, langs []hugoLanguage) ([]string, int) {
+	top := strings.HasPrefix(filepath.Base(file), "languages.")
+	var add []string
+	if filepath.Ext(file) == ".toml" {
+		for _, hl := range langs {
+			header := "[languages." + hl.key + "]"
+			if top {
+				header = "[" + hl.key + "]"
+			}
+			add = append(add, "", header)
+			for _, f := range hl.fields {
+				add = append(add, "  "+f[0]+" = "+f[1])
+			}
+		}
+		return add, lastLine(lines, len(lines))
+	}
+	// YAML: at the end of the languages: block, or of a languages.yaml
+	at, indent := lastLine(lines, len(lines)), ""
+	if !top {
+		start := -1
+		for i, ln := range lines {
+			if m := yamlKey.FindStringSubmatch(ln); m != nil && m[1] == "" && m[2] == "languages" {
+				start = i
+				break
+			}
+		}
+		indent = "  "
+		found := false
+		if start < 0 {
+			add = append(add, "languages:")
+		} else {
+			end := len(lines)
+			for i := start + 1; i < len(lines); i++ {
+				if t := strings.TrimSpace(lines[i]); t != "" && !strings.HasPrefix(t, "#") && !strings.HasPrefix(lines[i], " ") && !strings.HasPrefix(lines[i], "\t") {
+					end = i
+					break
+				}
+				if m := yamlKey.FindStringSubmatch(lines[i]); m != nil && !found && m[1] != "" {
+					indent, found = m[1], true // the first language's
+				}
+			}
+			at = lastLine(lines, end)
+		}
+	}
+	step := indent // however far the file goes in each time
+	if step == "" {
+		step = "  "
+	}
+	for _, hl := range langs {
+		add = append(add, indent+hl.key+":")
+		for _, f := range hl.fields {
+			add = append(add, indent+step+f[0]+": "+f[1])
+		}
+	}
+	return add, at
+}
+
+// the last line before end that isn't blank, counting from 1, 0 if
+// there isn't one
+func lastLine(lines []string, end int) int {
+	for i := end - 1; i >= 0; i-- {
+		if strings.TrimSpace(lines[i]) != "" {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// add after line at as a unified diff, with the usual three lines
+// around it
+func insertionPatch(file string, lines []string, at int, add []string) string {
+	before := at - 3
+	if before < 0 {
+		before = 0
+	}
+	after := at + 3
+	if after > len(lines) {
+		after = len(lines)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- a/%s\n+++ b/%s\n", filepath.ToSlash(file), filepath.ToSlash(file))
+	start := before + 1
+	if after == before { // an empty file
+		start = 0
+	}
+	fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", start, after-before, before+1, after-before+len(add))
+	for _, ln := range lines[before:at] {
+		b.WriteString(" " + ln + "\n")
+	}
+	for _, ln := range add {
+		b.WriteString("+" + ln + "\n")
+	}
+	for _, ln := range lines[at:after] {
+		b.WriteString(" " + ln + "\n")
+	}
+	return b.String()
+}
+
+// translate config hugo [--write]
+func configHugo(write bool) {
+	missing, have, path := missingHugoLanguages()
+	if path == "" {
+		checkError(fmt.Errorf("no Hugo config here, looked for %s", strings.Join(hugoConfigs, ", ")))
+	}
+	if len(missing) == 0 {
+		fmt.Printf("%s has all the languages already\n", path)
+		return
+	}
+	langs := newHugoLanguages(missing, have)
+	file := hugoLanguagesFile(path)
+	if filepath.Ext(file) == ".json" {
+		// rewriting it would lose its order and formatting
+		section := map[string]map[string]json.RawMessage{}
+		for _, hl := range langs {
+			section[hl.key] = map[string]json.RawMessage{}
+			for _, f := range hl.fields {
+				section[hl.key][f[0]] = json.RawMessage(f[1])
+			}
+		}
+		out, err := json.MarshalIndent(section, "", "  ")
+		checkError(err)
+		fmt.Printf("Add these to \"languages\" in %s:\n%s\n", file, out)
+		if write {
+			checkError(fmt.Errorf("--write doesn't change JSON configs, %s needs doing by hand", file))
+		}
+		return
+	}
+	f, err := files.ReadFile(file)
+	checkError(err)
+	lines := strings.Split(strings.TrimSuffix(string(f), "\n"), "\n")
+	if len(f) == 0 {
+		lines = nil
+	}
+	add, at := hugoLanguageLines(file, lines, langs)
+	if !write {
+		fmt.Print(insertionPatch(file, lines, at, add))
+		return
+	}
+	out := append(append(append([]string{}, lines[:at]...), add...), lines[at:]...)
+	checkError(files.WriteFile(file, []byte(strings.Join(out, "\n")+"\n")))
+	fmt.Printf("Added %s to %s\n", strings.Join(missing, ", "), file)
+}
+
+// at the start of a run, so a new language isn't translated and then
+// quietly never built
+func warnHugoLanguages() {
+	missing, _, path := missingHugoLanguages()
+	if len(missing) > 0 {
+		fmt.Printf("warning: %s doesn't have %s, so Hugo won't build them; translate config hugo --write adds them\n", path, strings.Join(missing, ", "))
+	}
+}
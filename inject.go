@@ -0,0 +1,27 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// the front_matter.inject fields for a translation of source, with
+// {lang}, {source_path} and {date} filled in. A field the source already
+// has is left the way the source has it.
+func injectedFrontMatter(lang string, source string, src []byte) string {
+	lines := frontMatterLines(src)
+	var keys []string
+	for k := range conf.FrontMatter.Inject {
+		if _, ok := fmValue(lines, k); !ok && !noindexKey(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys) // the same every run, or every page shows up in the diff
+	r := strings.NewReplacer("{lang}", lang, "{source_path}", filepath.ToSlash(source), "{date}", today())
+	var out strings.Builder
+	for _, k := range keys {
+		out.WriteString(k + ": " + r.Replace(conf.FrontMatter.Inject[k]) + "\n")
+	}
+	return out.String()
+}
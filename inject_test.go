@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInjectedFrontMatter(t *testing.T) {
+	conf = defaultConfig()
+	conf.FrontMatter.Noindex = map[string]string{"robots": "noindex"}
+	today := time.Now().Format("2006-01-02")
+	for _, c := range []struct {
+		inject map[string]string
+		src    string
+		want   string
+	}{
+		{nil, "---\ntitle: Hi\n---\n", ""},
+		{map[string]string{"translated": "true", "lang_of": "{lang}"}, "---\ntitle: Hi\n---\n", "lang_of: fr\ntranslated: true\n"},
+		{map[string]string{"source": "{source_path}", "on": "{date}"}, "---\n---\n", "on: " + today + "\nsource: content/a/index.en.md\n"},
+		{map[string]string{"title": "Injected", "draft": "false"}, "---\ntitle: Hi\n---\n", "draft: false\n"}, // the source has a title
+		{map[string]string{"robots": "index"}, "---\n---\n", ""},                                              // noindex has its own
+	} {
+		conf.FrontMatter.Inject = c.inject
+		if got := injectedFrontMatter("fr", "content/a/index.en.md", []byte(c.src)); got != c.want {
+			t.Errorf("%v, %q: %q, want %q", c.inject, c.src, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// <kbd>Ctrl</kbd>+<kbd>C</kbd>, and Ctrl+Shift+P without the tags
+	kbdRe      = regexp.MustCompile(`<kbd>[^<]*</kbd>(?:\s*\+\s*<kbd>[^<]*</kbd>)*`)
+	shortcutRe = regexp.MustCompile(`\b(?:Ctrl|Control|Cmd|Command|Alt|Option|Opt|Shift|Meta|Super|Win|Fn)(?:\s*\+\s*(?:[A-Za-z0-9]+|F[0-9]{1,2}))+\b`)
+	kbdKey     = regexp.MustCompile(`(<kbd>)([^<]*)(</kbd>)`)
+)
+
+// Strg instead of Ctrl and so on, from keys.names in the config. Any
+// spacing around the key stays.
+func localizeKey(lang string, key string) string {
+	k := strings.TrimSpace(key)
+	if l, ok := conf.Keys.Names[lang][k]; ok {
+		return strings.Replace(key, k, l, 1)
+	}
+	return key
+}
+
+func localizeShortcut(lang string, s string) string {
+	if len(conf.Keys.Names[lang]) == 0 {
+		return s
+	}
+	if strings.Contains(s, "<kbd>") {
+		return kbdKey.ReplaceAllStringFunc(s, func(k string) string {
+			m := kbdKey.FindStringSubmatch(k)
+			return m[1] + localizeKey(lang, m[2]) + m[3]
+		})
+	}
+	keys := strings.Split(s, "+")
+	for i, k := range keys {
+		keys[i] = localizeKey(lang, k)
+	}
+	return strings.Join(keys, "+")
+}
+
+// keep shortcuts away from the API (which spaces them out and translates
+// the key names) and put them back with the names for lang
+func (m *masker) maskShortcuts(text string, lang string) string {
+	for _, re := range []*regexp.Regexp{kbdRe, shortcutRe} {
+		text = re.ReplaceAllStringFunc(text, func(s string) string {
+			m.saved = append(m.saved, localizeShortcut(lang, s))
+			return placeholder(len(m.saved) - 1)
+		})
+	}
+	return text
+}
@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestLocalizeShortcut(t *testing.T) {
+	conf = defaultConfig()
+	conf.Keys.Names = map[string]map[string]string{"de": {"Ctrl": "Strg", "Shift": "Umschalt"}}
+	for _, c := range []struct {
+		lang, s, want string
+	}{
+		{"de", "Ctrl+C", "Strg+C"},
+		{"de", "Ctrl + Shift + P", "Strg + Umschalt + P"},
+		{"de", "<kbd>Ctrl</kbd>+<kbd>C</kbd>", "<kbd>Strg</kbd>+<kbd>C</kbd>"},
+		{"de", "<kbd> Shift </kbd>", "<kbd> Umschalt </kbd>"},
+		{"de", "Alt+F4", "Alt+F4"},
+		{"fr", "Ctrl+C", "Ctrl+C"},
+	} {
+		if got := localizeShortcut(c.lang, c.s); got != c.want {
+			t.Errorf("localizeShortcut(%s, %q) = %q, want %q", c.lang, c.s, got, c.want)
+		}
+	}
+}
+
+func TestShortcutsFound(t *testing.T) {
+	conf = defaultConfig()
+	for text, want := range map[string]string{
+		"Press Ctrl+C to copy.":           "Press ⟦T0000⟧ to copy.",
+		"Cmd+Shift+P opens it.":           "⟦T0000⟧ opens it.",
+		"Press Alt + F4 to quit.":         "Press ⟦T0000⟧ to quit.",
+		"Press <kbd>Esc</kbd>.":           "Press ⟦T0000⟧.",
+		"Control is what you need.":       "Control is what you need.",
+		"Shift the blame onto the plus+.": "Shift the blame onto the plus+.",
+	} {
+		var m masker
+		if got := m.maskShortcuts(text, "fr"); got != want {
+			t.Errorf("maskShortcuts(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
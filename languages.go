@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/language"
+)
+
+// providers that can say which languages they do. LLMs will have a go at
+// anything, so they don't.
+type languageLister interface {
+	Languages() ([]string, error)
+}
+
+func (googleProvider) Languages() ([]string, error) {
+	if !useV2() {
+		return languagesV3()
+	}
+	client, ctx, err := AuthTranslate(conf.Translation.Credentials, conf.Translation.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	langs, err := client.SupportedLanguages(ctx, language.English)
+	if err != nil {
+		return nil, err
+	}
+	var codes []string
+	for _, l := range langs {
+		codes = append(codes, l.Tag.String())
+	}
+	return codes, nil
+}
+
+var (
+	supported   = map[string][]string{} // provider name -> languages, nil if it can't say
+	supportedMu sync.Mutex
+)
+
+// what a provider says it does, asked once a run
+func supportedLanguages(p Provider) []string {
+	supportedMu.Lock()
+	defer supportedMu.Unlock()
+	if langs, ok := supported[p.Name()]; ok {
+		return langs
+	}
+	var langs []string
+	if lister, ok := p.(languageLister); ok {
+		var err error
+		if langs, err = lister.Languages(); err != nil {
+			fmt.Printf("warning: couldn't get the languages %s supports: %v\n", p.Name(), err)
+		}
+	}
+	supported[p.Name()] = langs
+	return langs
+}
+
+// as far as we know. One that doesn't say does everything.
+func supportsLanguage(p Provider, lang string) bool {
+	langs := supportedLanguages(p)
+	if langs == nil {
+		return true
+	}
+	for _, l := range langs {
+		if strings.EqualFold(l, apiCode(lang)) {
+			return true
+		}
+	}
+	return false
+}
+
+// what a provider is asked for, pt-BR for the site's pt with
+// "codes": {"pt": {"api": "pt-BR"}}
+func apiCode(lang string) string {
+	if c := conf.Codes[lang].API; c != "" {
+		return c
+	}
+	return lang
+}
+
+// what's in a page's file name (or its content directory)
+func fileCode(lang string) string {
+	if c := conf.Codes[lang].File; c != "" {
+		return c
+	}
+	return lang
+}
+
+// which of ours an API code is, for what comes back with one on it
+func siteLanguage(code string) string {
+	for l, c := range conf.Codes {
+		if strings.EqualFold(c.API, code) {
+			return l
+		}
+	}
+	return code
+}
+
+// make sure something will do every language we're about to ask for,
+// before we've spent anything, not when the first page for it comes up
+func checkLanguages() {
+	wanted := append([]string{conf.Source}, conf.Languages...)
+	for _, pivot := range conf.Translation.Pivots {
+		wanted = append(wanted, pivot)
+	}
+	var problems []string
+	for _, l := range wanted {
+		ok := false
+		for _, p := range providerChain() {
+			ok = ok || supportsLanguage(p, l)
+		}
+		if ok {
+			continue
+		}
+		msg := fmt.Sprintf("%s doesn't support %q", provider().Name(), apiCode(l))
+		if s := suggestLanguages(apiCode(l), supportedLanguages(provider())); len(s) > 0 {
+			msg += fmt.Sprintf(", did you mean %s?", strings.Join(s, " or "))
+			if conf.Codes[l].API == "" {
+				msg += fmt.Sprintf(` ("codes": {%q: {"api": %q}} asks for that and keeps %s in the file names)`, l, s[0], l)
+			}
+		}
+		if !isValueInList(msg, problems) {
+			problems = append(problems, msg)
+		}
+	}
+	if len(problems) > 0 {
+		checkError(fmt.Errorf("%s", strings.Join(problems, "\n")))
+	}
+}
+
+// codes that are probably what was meant: the same language with or
+// without a region (pt-br and pt, zh and zh-CN), or one letter off
+func suggestLanguages(code string, supported []string) []string {
+	code = strings.ToLower(code)
+	base := strings.Split(code, "-")[0]
+	var out []string
+	for _, s := range supported {
+		l := strings.ToLower(s)
+		if strings.Split(l, "-")[0] == base || editDistance(l, code) == 1 {
+			out = append(out, s)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func editDistance(a string, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func min3(a int, b int, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLanguageCodes(t *testing.T) {
+	conf = defaultConfig()
+	conf.Codes = map[string]LanguageCodes{
+		"pt":      {API: "pt-BR", File: "pt-br"},
+		"zh-hans": {API: "zh-CN"},
+		"nb":      {File: "no"},
+	}
+	for _, c := range []struct {
+		lang, api, file string
+	}{
+		{"pt", "pt-BR", "pt-br"},
+		{"zh-hans", "zh-CN", "zh-hans"},
+		{"nb", "nb", "no"},
+		{"fr", "fr", "fr"},
+	} {
+		if got := apiCode(c.lang); got != c.api {
+			t.Errorf("apiCode(%s) = %s, want %s", c.lang, got, c.api)
+		}
+		if got := fileCode(c.lang); got != c.file {
+			t.Errorf("fileCode(%s) = %s, want %s", c.lang, got, c.file)
+		}
+	}
+	for code, want := range map[string]string{"pt-BR": "pt", "pt-br": "pt", "zh-CN": "zh-hans", "fr": "fr"} {
+		if got := siteLanguage(code); got != want {
+			t.Errorf("siteLanguage(%s) = %s, want %s", code, got, want)
+		}
+	}
+}
+
+func TestTargetFor(t *testing.T) {
+	conf = defaultConfig()
+	conf.Codes = map[string]LanguageCodes{"pt": {API: "pt-BR", File: "pt-br"}}
+	for _, c := range []struct {
+		layout, source, lang, want string
+	}{
+		{"", "content/a/index.en.md", "pt", "content/a/index.pt-br.md"},
+		{"", "content/a/_index.en.md", "fr", "content/a/_index.fr.md"},
+		{"directory", "content/en/a/index.md", "pt", "content/pt-br/a/index.md"},
+	} {
+		conf.Layout.Mode = c.layout
+		if got := targetFor(filepath.FromSlash(c.source), "en", c.lang); got != filepath.FromSlash(c.want) {
+			t.Errorf("targetFor(%s, %s) = %s, want %s", c.source, c.lang, got, c.want)
+		}
+	}
+}
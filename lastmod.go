@@ -0,0 +1,19 @@
+package main
+
+import (
+	"time"
+)
+
+// lastmod for a translation that's being redone, today in whatever
+// format the site uses. date stays what it was, the page isn't new.
+func lastmodLine() string {
+	return "lastmod: " + today() + "\n"
+}
+
+func today() string {
+	layout := conf.Lastmod.Format
+	if layout == "" {
+		layout = "2006-01-02"
+	}
+	return time.Now().Format(layout)
+}
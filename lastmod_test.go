@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLastmodBump(t *testing.T) {
+	conf = defaultConfig()
+	for _, c := range []struct {
+		format string
+		bump   bool
+		src    string
+		want   string
+	}{
+		{"", true, "---\ntitle: Hi\nlastmod: 2020-01-01\n---\n", "---\ntitle: < Hi>\nlastmod: {today}\n---\n"},
+		{"", true, "---\ntitle: Hi\n---\n", "---\ntitle: < Hi>\nlastmod: {today}\n---\n"},
+		{"", false, "---\ntitle: Hi\nlastmod: 2020-01-01\n---\n", "---\ntitle: < Hi>\nlastmod: 2020-01-01\n---\n"},
+		{"", false, "---\ntitle: Hi\n---\n", "---\ntitle: < Hi>\n---\n"},
+		{"", true, "---\ndate: 2020-01-01\n---\n", "---\ndate: 2020-01-01\nlastmod: {today}\n---\n"}, // date stays
+		{"January 2, 2006", true, "---\nlastmod: 2020-01-01\n---\n", "---\nlastmod: {today}\n---\n"},
+	} {
+		conf.Lastmod.Format = c.format
+		layout := c.format
+		if layout == "" {
+			layout = "2006-01-02"
+		}
+		want := strings.Replace(c.want, "{today}", time.Now().Format(layout), 1)
+		var out strings.Builder
+		xlateLines(&out, "en", "fr", "content/a/index.en.md", "content/a/index.fr.md", []byte(c.src), c.bump, func(text string, where string) string {
+			return "<" + text + ">"
+		})
+		if got := out.String(); got != want {
+			t.Errorf("bump %v, %q:\n%s\nwant\n%s", c.bump, c.src, got, want)
+		}
+	}
+}
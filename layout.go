@@ -0,0 +1,121 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+func dirLayout() bool {
+	return conf.Layout.Mode == "directory"
+}
+
+// where a language's pages are in the directory layout
+func contentDir(lang string) string {
+	if d, ok := conf.Layout.Dirs[lang]; ok {
+		return filepath.Clean(d)
+	}
+	return filepath.Join("content", fileCode(lang))
+}
+
+func absPath(path string) string {
+	abs, err := filepath.Abs(path)
+	checkError(err)
+	return abs
+}
+
+// path relative to dir, if it's dir or somewhere under it, whether
+// either of them is relative or absolute
+func relWithin(path string, dir string) (string, bool) {
+	rel, err := filepath.Rel(absPath(dir), absPath(path))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
+func within(path string, dir string) bool {
+	_, ok := relWithin(path, dir)
+	return ok
+}
+
+// the language whose tree path is, if it's one other than from's
+func otherLanguageDir(path string, from string) string {
+	langs := append([]string{}, conf.Languages...)
+	for l := range conf.Layout.Dirs {
+		langs = append(langs, l)
+	}
+	for _, l := range langs {
+		if l != from && absPath(path) == absPath(contentDir(l)) {
+			return l
+		}
+	}
+	return ""
+}
+
+// Hugo pairs up the languages of a page by its path, or by translationKey
+// when the paths don't match, which in the directory layout they soon
+// don't (a translated slug, a renamed bundle). So every source page gets
+// a key made from where it is and its translations get the same one.
+func translationKey(source string) string {
+	rel, ok := relWithin(source, contentDir(conf.Source))
+	if !ok {
+		rel = source
+	}
+	sum := sha256.Sum256([]byte(filepath.ToSlash(rel)))
+	return hex.EncodeToString(sum[:8])
+}
+
+var translationKeyLine = regexp.MustCompile(`(?mi)^translationKey:.*$`)
+
+// set translationKey in a page's front matter, if it isn't set to that
+// already. Says whether it changed anything.
+func setTranslationKey(file string, key string) bool {
+	src, err := files.ReadFile(file)
+	checkError(err)
+	end := frontMatterEnd(src)
+	if end < 0 { // no front matter to put it in
+		return false
+	}
+	line := "translationKey: " + key
+	var out []byte
+	if have, ok := fmValue(frontMatterLines(src), "translationKey"); ok {
+		if have == key {
+			return false
+		}
+		out = append(translationKeyLine.ReplaceAll(src[:end], []byte(line)), src[end:]...)
+	} else {
+		out = append(append(src[:end:end], line+"\n"...), src[end:]...)
+	}
+	checkError(files.WriteFile(file, out))
+	return true
+}
+
+// in the directory layout, make sure a source page has a translationKey
+// and that every translation it already has shares it. A key that's
+// already in the source is the one that counts.
+func syncTranslationKey(from string, source string) {
+	if !dirLayout() {
+		return
+	}
+	src, err := files.ReadFile(source)
+	checkError(err)
+	key, ok := fmValue(frontMatterLines(src), "translationKey")
+	if !ok || key == "" {
+		oldHash := hashFile(source)
+		key = translationKey(source)
+		if setTranslationKey(source, key) {
+			state.sourceChanged(source, oldHash)
+		}
+	}
+	for _, lang := range conf.Languages {
+		target := targetFor(source, from, lang)
+		if exists(target) && setTranslationKey(target, key) {
+			fmt.Printf("Updating:\t %s (translationKey %s)\n", target, key)
+			state.touch(target)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestSetTranslationKey(t *testing.T) {
+	for _, c := range []struct {
+		page, want string
+		changed    bool
+	}{
+		{"---\ntitle: Hi\n---\nText.\n", "---\ntitle: Hi\ntranslationKey: abc\n---\nText.\n", true},
+		{"---\ntitle: Hi\ntranslationKey: abc\n---\n", "---\ntitle: Hi\ntranslationKey: abc\n---\n", false},
+		{"---\ntranslationKey: old\ntitle: Hi\n---\ntranslationKey: old\n", "---\ntranslationKey: abc\ntitle: Hi\n---\ntranslationKey: old\n", true},
+		{"Text.\n", "Text.\n", false}, // nowhere to put it
+	} {
+		m := memSite(t, map[string]string{"content/a/index.md": c.page})
+		if got := setTranslationKey("content/a/index.md", "abc"); got != c.changed {
+			t.Errorf("%q: changed %v, want %v", c.page, got, c.changed)
+		}
+		if got := m.dump()["content/a/index.md"]; got != c.want {
+			t.Errorf("%q:\n%s\nwant\n%s", c.page, got, c.want)
+		}
+	}
+}
+
+func TestTranslationKey(t *testing.T) {
+	conf = defaultConfig()
+	a := translationKey("content/a/index.md")
+	if len(a) != 16 {
+		t.Errorf("translationKey = %q, want 16 hex digits", a)
+	}
+	if b := translationKey("content/a/index.md"); b != a {
+		t.Errorf("the same page got %s and %s", a, b)
+	}
+	if b := translationKey("content/b/index.md"); b == a {
+		t.Errorf("two pages got %s", a)
+	}
+}
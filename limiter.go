@@ -0,0 +1,256 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// how many requests to have going at once, worked out as we go rather
+// than set by hand: add one after every limit requests that come back
+// fine, halve it when the API says slow down, and drop one when it's
+// taking a lot longer to answer than it did at its best
+type adaptiveLimiter struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	limit    int
+	max      int
+	inFlight int
+	ok       int           // good answers since the limit last changed
+	latency  time.Duration // moving average
+	best     time.Duration // the lowest that's been
+}
+
+var (
+	limiter     *adaptiveLimiter
+	limiterOnce sync.Once
+)
+
+// nil if the worker count is fixed
+func getLimiter() *adaptiveLimiter {
+	limiterOnce.Do(func() {
+		if conf.Adaptive.MaxWorkers > 0 {
+			limiter = &adaptiveLimiter{limit: 1, max: conf.Adaptive.MaxWorkers}
+			limiter.cond = sync.NewCond(&limiter.mu)
+		}
+	})
+	return limiter
+}
+
+func (l *adaptiveLimiter) acquire() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.inFlight >= l.limit {
+		l.cond.Wait()
+	}
+	l.inFlight++
+}
+
+func (l *adaptiveLimiter) release(took time.Duration, throttled bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+	defer l.cond.Broadcast()
+	if throttled {
+		if l.limit > 1 {
+			l.limit /= 2
+			fmt.Printf("Throttled:\t down to %d at a time\n", l.limit)
+		}
+		l.ok = 0
+		return
+	}
+	if l.latency == 0 {
+		l.latency = took
+	} else {
+		l.latency = (l.latency*4 + took) / 5
+	}
+	if l.best == 0 || l.latency < l.best {
+		l.best = l.latency
+	}
+	if l.latency > 2*l.best && l.limit > 1 { // queueing on their end
+		l.limit--
+		l.ok = 0
+		return
+	}
+	l.ok++
+	if l.ok >= l.limit && l.limit < l.max {
+		l.limit++
+		l.ok = 0
+	}
+}
+
+// is this the API telling us to slow down?
+func isThrottled(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{"429", "too many requests", "ratelimitexceeded", "rate limit", "resource_exhausted", "quota exceeded"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// translation.rate_limits: no more than a provider's quota, however many
+// workers there are. Requests and characters each come out of a bucket
+// that fills up at the rate the quota allows, and a request that would
+// take more than is in it waits until there's enough. A second's worth
+// of requests, or a minute's worth of characters, can go in a burst.
+type tokenBucket struct {
+	rate   float64 // tokens a second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(perSecond float64, burst float64) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: perSecond, burst: burst, tokens: burst}
+}
+
+// take n, even if they aren't there yet, and say how long until they
+// will be. Whoever's next waits for what this one took as well.
+func (b *tokenBucket) take(n float64, now time.Time) time.Duration {
+	if b == nil {
+		return 0
+	}
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+type rateLimiter struct {
+	mu       sync.Mutex
+	requests *tokenBucket // nil if there's no limit on them
+	chars    *tokenBucket
+}
+
+var (
+	rateLimiters     map[string]*rateLimiter // provider name -> its limit
+	rateLimitersOnce sync.Once
+)
+
+// nil if the provider has no limit
+func getRateLimiter(p Provider) *rateLimiter {
+	rateLimitersOnce.Do(func() {
+		rateLimiters = map[string]*rateLimiter{}
+		for name, rate := range conf.Translation.RateLimits {
+			r := &rateLimiter{}
+			if rate.RequestsPerSecond > 0 {
+				r.requests = newBucket(rate.RequestsPerSecond, rate.RequestsPerSecond)
+			}
+			if rate.CharsPerMinute > 0 {
+				r.chars = newBucket(float64(rate.CharsPerMinute)/60, float64(rate.CharsPerMinute))
+			}
+			if r.requests != nil || r.chars != nil {
+				rateLimiters[name] = r
+			}
+		}
+	})
+	return rateLimiters[p.Name()]
+}
+
+// until texts can go
+func (r *rateLimiter) wait(texts []string) {
+	if r == nil {
+		return
+	}
+	chars := 0
+	for _, t := range texts {
+		chars += utf8.RuneCountInString(t)
+	}
+	r.mu.Lock()
+	now := time.Now()
+	wait := r.requests.take(1, now)
+	if w := r.chars.take(float64(chars), now); w > wait {
+		wait = w
+	}
+	r.mu.Unlock()
+	time.Sleep(wait)
+}
+
+// is it worth trying again? Throttling, the server having a bad moment
+// (500, 502, 503, 504) and the network letting us down all pass.
+func isTransient(err error) bool {
+	if isThrottled(err) {
+		return true
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{"error 500", "error 502", "error 503", "error 504", "500 internal", "502 bad", "503 service", "504 gateway",
+		"internal error", "backend error", "unavailable", "deadline exceeded", "timeout", "timed out", "connection reset", "connection refused", "unexpected eof", "broken pipe"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// how long to wait before try+1: up to 1s, 2s, 4s and so on, to
+// translation.retry.max_seconds, and somewhere between half that and all
+// of it so a crowd of workers that failed together don't all come back
+// together
+func backoff(try int) time.Duration {
+	max := time.Duration(conf.Translation.Retry.MaxSeconds) * time.Second
+	if max <= 0 {
+		max = 30 * time.Second
+	}
+	d := time.Second << uint(try-1)
+	if d > max || d <= 0 {
+		d = max
+	}
+	return d/2 + time.Duration(time.Now().UnixNano()%int64(d/2+1))
+}
+
+// p.Translate, within the limiters if there are any, and tried again
+// (translation.retry.attempts in all) if it fails in a way that might
+// not last
+func callProvider(p Provider, texts []string, from string, to string) ([]string, error) {
+	return retrying(p, texts, func() ([]string, error) {
+		return p.Translate(texts, from, to)
+	})
+}
+
+// call, which sends texts to p, the same way
+func retrying(p Provider, texts []string, call func() ([]string, error)) ([]string, error) {
+	rate := getRateLimiter(p)
+	l := getLimiter()
+	attempts := conf.Translation.Retry.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	for try := 1; ; try++ {
+		if l != nil {
+			l.acquire()
+		}
+		rate.wait(texts)
+		started := time.Now()
+		out, err := call()
+		if l != nil {
+			l.release(time.Since(started), err != nil && isThrottled(err))
+		}
+		if err == nil || try >= attempts || !isTransient(err) {
+			return out, err
+		}
+		wait := backoff(try)
+		fmt.Printf("Retrying:\t %s in %s (%d of %d), %s\n", p.Name(), wait.Round(100*time.Millisecond), try+1, attempts, strings.TrimSpace(err.Error()))
+		time.Sleep(wait)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	start := time.Now()
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+	b := newBucket(2, 2) // two a second
+	for i, c := range []struct {
+		n    float64
+		at   time.Time
+		want time.Duration
+	}{
+		{1, at(0), 0},
+		{1, at(0), 0},                      // the burst
+		{1, at(0), 500 * time.Millisecond}, // then what refills
+		{1, at(0), time.Second},            // whoever's next waits for that too
+		{1, at(2000), 0},                   // full again 2s later
+		{1, at(2000), 0},
+		{3, at(2000), 1500 * time.Millisecond}, // more than there is
+		{1, at(10000), 0},                      // never more than the burst
+		{1, at(10000), 0},
+		{1, at(10000), 500 * time.Millisecond},
+	} {
+		if got := b.take(c.n, c.at); got != c.want {
+			t.Errorf("%d: take(%v) = %v, want %v", i, c.n, got, c.want)
+		}
+	}
+	var none *tokenBucket
+	if got := none.take(100, start); got != 0 {
+		t.Errorf("no bucket waits %v", got)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	for msg, want := range map[string]bool{
+		"googleapi: Error 429: Too Many Requests":                     true,
+		"googleapi: Error 503: Service Unavailable":                   true,
+		"azure: 502 Bad Gateway":                                      true,
+		"rpc error: code = DeadlineExceeded desc = deadline exceeded": true,
+		"read tcp: connection reset by peer":                          true,
+		"unexpected EOF":                                              true,
+		"googleapi: Error 400: Invalid Value":                         false,
+		"googleapi: Error 403: The caller does not have permission":   false,
+		"llm: no translation in the answer":                           false,
+	} {
+		if got := isTransient(errors.New(msg)); got != want {
+			t.Errorf("isTransient(%q) = %v, want %v", msg, got, want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	conf = defaultConfig()
+	for _, c := range []struct {
+		max, try int
+		want     time.Duration // somewhere between half and all of it
+	}{
+		{0, 1, time.Second},
+		{0, 3, 4 * time.Second},
+		{0, 10, 30 * time.Second},
+		{0, 100, 30 * time.Second}, // past what a Duration holds
+		{5, 3, 4 * time.Second},
+		{5, 4, 5 * time.Second},
+	} {
+		conf.Translation.Retry.MaxSeconds = c.max
+		for i := 0; i < 20; i++ {
+			if got := backoff(c.try); got < c.want/2 || got > c.want {
+				t.Errorf("max %d, backoff(%d) = %v, want %v to %v", c.max, c.try, got, c.want/2, c.want)
+				break
+			}
+		}
+	}
+}
+
+type flakyProvider struct {
+	fails []error
+	calls int
+}
+
+func (p *flakyProvider) Name() string {
+	return "flaky"
+}
+
+func (p *flakyProvider) Translate(texts []string, from string, to string) ([]string, error) {
+	p.calls++
+	if p.calls <= len(p.fails) {
+		return nil, p.fails[p.calls-1]
+	}
+	return texts, nil
+}
+
+func TestRetrying(t *testing.T) {
+	conf = defaultConfig()
+	conf.Translation.Retry = Retry{Attempts: 2, MaxSeconds: 1}
+	unavailable := errors.New("googleapi: Error 503: Service Unavailable")
+	invalid := errors.New("googleapi: Error 400: Invalid Value")
+	for _, c := range []struct {
+		fails   []error
+		wantErr error
+		calls   int
+	}{
+		{nil, nil, 1},
+		{[]error{unavailable}, nil, 2},
+		{[]error{unavailable, unavailable}, unavailable, 2}, // out of attempts
+		{[]error{invalid}, invalid, 1},                      // no point trying again
+	} {
+		p := &flakyProvider{fails: c.fails}
+		_, err := callProvider(p, []string{"Hi"}, "en", "fr")
+		if err != c.wantErr || p.calls != c.calls {
+			t.Errorf("%v: %v after %d calls, want %v after %d", c.fails, err, p.calls, c.wantErr, c.calls)
+		}
+	}
+}
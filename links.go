@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// links.check: when the run's done, every translation of the pages it
+// looked at is checked for links that go nowhere. A heading that's been
+// translated has a new anchor, and a path the API got its hands on
+// doesn't exist, and up to now the first anyone knew of it was a 404
+// after the deploy. Like the rest of this it reads the Markdown, it
+// doesn't build the site: a link to a page is checked against the page's
+// file, so permalinks and translated slugs aren't taken into account.
+
+var (
+	linkDest  = regexp.MustCompile(`!?\[[^\]]*\]\(\s*<?([^)\s>]+)>?(?:\s+["'(][^)]*)?\)`)
+	linkDef   = regexp.MustCompile(`^ {0,3}\[[^\]]+\]:\s*<?([^\s>]+)>?`)
+	htmlLink  = regexp.MustCompile(`(?i)\b(?:href|src)\s*=\s*["']([^"']+)["']`)
+	htmlID    = regexp.MustCompile(`(?i)\b(?:id|name)\s*=\s*["']([^"']+)["']`)
+	atxHead   = regexp.MustCompile(`^ {0,3}#{1,6}\s+(.*?)\s*#*\s*$`)
+	headingID = regexp.MustCompile(`\s*\{#([^}\s]+)[^}]*\}\s*$`)
+	setextRe  = regexp.MustCompile(`^ {0,3}(=+|-+)\s*$`)
+	linkText  = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+)
+
+// a page's body a line at a time, with its line number, leaving out the
+// front matter and code
+func bodyLines(src []byte, fn func(ln string, lineNo int)) {
+	head := false
+	code := false
+	var fc fence
+	lineNo := 0
+	scanner := bufio.NewScanner(bytes.NewReader(bytes.TrimPrefix(src, bom)))
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		ln := scanner.Text()
+		lineNo++
+		if (lineNo == 1 && strings.TrimRight(ln, " \r") == "---") || (head && isFrontMatterEnd(ln)) {
+			head = !head
+			continue
+		}
+		if head {
+			continue
+		}
+		if code {
+			code = !fc.closes(ln)
+			continue
+		}
+		if f, ok := openFence(ln); ok {
+			fc = f
+			code = true
+			continue
+		}
+		if isIndentedCode(ln) && listItem.FindString(strings.TrimLeft(ln, " \t")) == "" {
+			continue
+		}
+		fn(ln, lineNo)
+	}
+	checkError(scanner.Err())
+}
+
+// what Hugo makes a heading's id from its text: lower case, the letters,
+// digits, dashes and underscores, and a dash for each space
+func anchorize(text string) string {
+	text = linkText.ReplaceAllString(text, "$1")
+	var out strings.Builder
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) || r == '-' || r == '_':
+			out.WriteRune(r)
+		case r == ' ':
+			out.WriteByte('-')
+		}
+	}
+	return out.String()
+}
+
+// every id on a page: its headings', the second one with a name getting
+// -1 and so on, and any in the HTML
+func pageAnchors(src []byte) map[string]bool {
+	ids := map[string]bool{}
+	seen := map[string]int{}
+	add := func(heading string) {
+		if m := headingID.FindStringSubmatch(heading); m != nil {
+			ids[m[1]] = true
+			return
+		}
+		id := anchorize(heading)
+		if n := seen[id]; n > 0 {
+			seen[id]++
+			id = fmt.Sprintf("%s-%d", id, n)
+		} else {
+			seen[id] = 1
+		}
+		ids[id] = true
+	}
+	prev := ""
+	bodyLines(src, func(ln string, lineNo int) {
+		if m := atxHead.FindStringSubmatch(ln); m != nil {
+			add(m[1])
+		} else if setextRe.MatchString(ln) && strings.TrimSpace(prev) != "" && listItem.FindString(prev) == "" {
+			add(strings.TrimSpace(prev))
+		}
+		for _, m := range htmlID.FindAllStringSubmatch(ln, -1) {
+			ids[m[1]] = true
+		}
+		prev = ln
+	})
+	return ids
+}
+
+// the page a directory's URL is, in lang
+func pageIn(dir string, lang string) string {
+	names := []string{"index." + fileCode(lang) + ".md", "_index." + fileCode(lang) + ".md"}
+	if dirLayout() {
+		names = []string{"index.md", "_index.md"}
+	}
+	for _, n := range names {
+		if p := filepath.Join(dir, n); exists(p) {
+			return p
+		}
+	}
+	return ""
+}
+
+// the file a link from page goes to, and what's wrong if there isn't one
+func linkFile(page string, lang string, path string) (string, string) {
+	var candidates []string
+	dirOf := func(l string) string {
+		if dirLayout() {
+			return contentDir(l)
+		}
+		return "content"
+	}
+	if strings.HasPrefix(path, "/") {
+		// /fr/a/ is the French page, /a/ is the source's
+		linkLang := conf.Source
+		for _, l := range pageLanguages() {
+			if prefix := "/" + strings.ToLower(l) + "/"; strings.HasPrefix(strings.ToLower(path)+"/", prefix) {
+				linkLang = l
+				path = strings.TrimPrefix(path[len(prefix)-1:], "/")
+				break
+			}
+		}
+		lang = linkLang
+		candidates = append(candidates, filepath.Join(dirOf(lang), filepath.FromSlash(path)), filepath.Join("static", filepath.FromSlash(path)))
+	} else {
+		candidates = append(candidates, filepath.Join(filepath.Dir(page), filepath.FromSlash(path)))
+	}
+	for _, c := range candidates {
+		fi, err := files.Stat(c)
+		if err != nil {
+			continue
+		}
+		if !fi.IsDir() {
+			return c, ""
+		}
+		if p := pageIn(c, lang); p != "" {
+			return p, ""
+		}
+		return "", "there's no " + lang + " page there"
+	}
+	return "", "nothing there"
+}
+
+// what's wrong with a link from page, "" if nothing is
+func checkLink(page string, lang string, dest string, anchors func(string) map[string]bool) string {
+	if strings.HasPrefix(dest, "{{") {
+		return "" // a ref shortcode, Hugo checks those
+	}
+	u, err := url.Parse(dest)
+	if err != nil || u.Scheme != "" || u.Host != "" || strings.HasPrefix(dest, "//") {
+		return ""
+	}
+	file := page
+	if u.Path != "" {
+		var why string
+		file, why = linkFile(page, lang, u.Path)
+		if why != "" {
+			return why
+		}
+	}
+	if u.Fragment != "" && strings.HasSuffix(file, ".md") && !anchors(file)[u.Fragment] {
+		return "no #" + u.Fragment + " on " + file
+	}
+	return ""
+}
+
+// every broken link in a translation, as file:line: link (why)
+func brokenLinks(target string, lang string, anchors func(string) map[string]bool) []string {
+	src, err := files.ReadFile(target)
+	checkError(err)
+	var broken []string
+	bodyLines(src, func(ln string, lineNo int) {
+		ln = inlineCode.ReplaceAllString(ln, "")
+		var dests []string
+		for _, re := range []*regexp.Regexp{linkDest, linkDef, htmlLink} {
+			for _, m := range re.FindAllStringSubmatch(ln, -1) {
+				dests = append(dests, m[1])
+			}
+		}
+		for _, d := range dests {
+			if why := checkLink(target, lang, d, anchors); why != "" {
+				broken = append(broken, fmt.Sprintf("%s:%d: %s (%s)", target, lineNo, d, why))
+			}
+		}
+	})
+	return broken
+}
+
+// check the translations of pages, if links.check says to, and put what's
+// broken in the summary
+func checkLinks(from string, pages []string) {
+	if !conf.Links.Check {
+		return
+	}
+	read := map[string]map[string]bool{}
+	anchors := func(file string) map[string]bool {
+		if ids, ok := read[file]; ok {
+			return ids
+		}
+		src, err := files.ReadFile(file)
+		checkError(err)
+		read[file] = pageAnchors(src)
+		return read[file]
+	}
+	for _, p := range pages {
+		for _, lang := range conf.Languages {
+			target := targetFor(p, from, lang)
+			if !exists(target) {
+				continue
+			}
+			for _, b := range brokenLinks(target, lang, anchors) {
+				countBrokenLink(lang, b)
+			}
+		}
+	}
+}
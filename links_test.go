@@ -0,0 +1,65 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnchorize(t *testing.T) {
+	for text, want := range map[string]string{
+		"Getting Started":           "getting-started",
+		"What's new in 2.0?":        "whats-new-in-20",
+		"Use [the CLI](/cli/) here": "use-the-cli-here",
+		"Démarrer rapidement":       "démarrer-rapidement",
+		"snake_case and kebab-case": "snake_case-and-kebab-case",
+	} {
+		if got := anchorize(text); got != want {
+			t.Errorf("anchorize(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestPageAnchors(t *testing.T) {
+	src := "---\ntitle: Hi\n---\n# Intro\n\n## Intro\n\nSetext\n------\n\n## Named {#custom}\n\n```\n# not a heading\n```\n\n<a id=\"html\"></a>\n\n- list\n---\n"
+	want := map[string]bool{"intro": true, "intro-1": true, "setext": true, "custom": true, "html": true}
+	if got := pageAnchors([]byte(src)); !reflect.DeepEqual(got, want) {
+		t.Errorf("pageAnchors = %v, want %v", got, want)
+	}
+}
+
+func TestBrokenLinks(t *testing.T) {
+	for _, c := range []struct {
+		link string
+		want []string
+	}{
+		{"[ok](#intro)", nil},
+		{"[gone](#einleitung)", []string{"content/a/index.fr.md:5: #einleitung (no #einleitung on content/a/index.fr.md)"}},
+		{"[other](/b/)", nil},
+		{"[other](/fr/b/#salut)", nil},
+		{"[other](/fr/b/#bonjour)", []string{"content/a/index.fr.md:5: /fr/b/#bonjour (no #bonjour on content/b/index.fr.md)"}},
+		{"[other](/c/)", []string{"content/a/index.fr.md:5: /c/ (nothing there)"}},
+		{"[other](/fr/d/)", []string{"content/a/index.fr.md:5: /fr/d/ (there's no fr page there)"}},
+		{"![pic](pic.png)", nil},
+		{"![pic](photo.png)", []string{"content/a/index.fr.md:5: photo.png (nothing there)"}},
+		{"<img src=\"/logo.svg\">", nil},
+		{"[out](https://example.com/nowhere)", nil},
+		{"[ref]({{< ref \"nowhere\" >}})", nil},
+		{"`[code](/nowhere/)`", nil},
+	} {
+		memSite(t, map[string]string{
+			"content/a/index.fr.md": "---\ntitle: Salut\n---\n# Intro\n" + c.link + "\n",
+			"content/a/pic.png":     "",
+			"content/b/index.en.md": "# Hello\n",
+			"content/b/index.fr.md": "# Salut\n",
+			"content/d/index.en.md": "# Hello\n",
+			"static/logo.svg":       "",
+		})
+		anchors := func(file string) map[string]bool {
+			src, _ := files.ReadFile(file)
+			return pageAnchors(src)
+		}
+		if got := brokenLinks("content/a/index.fr.md", "fr", anchors); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: %q, want %q", c.link, got, c.want)
+		}
+	}
+}
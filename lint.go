@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// something in the source that is going to come back broken from the API
+type lintIssue struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (i lintIssue) String() string {
+	return fmt.Sprintf("%s:%d: %s", i.File, i.Line, i.Msg)
+}
+
+var (
+	inlineCode  = regexp.MustCompile("`[^`]*`")
+	spacedLink  = regexp.MustCompile(`\]\s+\(`)
+	openLink    = regexp.MustCompile(`\]\([^)]*$`)
+	spacedStars = regexp.MustCompile(`\s\*+\s`)
+)
+
+// look for the things that ruin machine translation: unclosed emphasis
+// and code spans, malformed links, tabs in front matter
+func lintFile(path string) []lintIssue {
+	src, err := files.ReadFile(path)
+	checkError(err)
+	src = bytes.TrimPrefix(src, bom)
+	var issues []lintIssue
+	add := func(line int, format string, args ...interface{}) {
+		issues = append(issues, lintIssue{path, line, fmt.Sprintf(format, args...)})
+	}
+	if err := checkFrontMatter(path, src); err != nil {
+		add(1, "%v", err)
+	}
+	head := false
+	code := false
+	var fc fence
+	lineNo := 0
+	scanner := bufio.NewScanner(bytes.NewReader(src))
+	for scanner.Scan() {
+		ln := scanner.Text()
+		lineNo++
+		if (lineNo == 1 && strings.TrimRight(ln, " \r") == "---") || (head && isFrontMatterEnd(ln)) {
+			head = !head
+			continue
+		}
+		if head {
+			if strings.Contains(ln, "\t") {
+				add(lineNo, "tab in front matter, YAML wants spaces")
+			}
+			continue
+		}
+		if code {
+			code = !fc.closes(ln)
+			continue
+		}
+		if f, ok := openFence(ln); ok {
+			fc = f
+			code = true
+			continue
+		}
+		if isIndentedCode(ln) || strings.HasPrefix(ln, "{{") {
+			continue
+		}
+		if strings.Count(ln, "`")%2 != 0 {
+			add(lineNo, "unclosed inline code (odd number of backticks)")
+		}
+		text := inlineCode.ReplaceAllString(ln, "")
+		if m := listItem.FindString(text); m != "" {
+			text = text[len(m):]
+		}
+		if strings.Count(text, "**")%2 != 0 {
+			add(lineNo, "unclosed bold (odd number of **)")
+		}
+		single := spacedStars.ReplaceAllString(strings.ReplaceAll(text, "**", ""), " ")
+		if strings.Count(single, "*")%2 != 0 {
+			add(lineNo, "unclosed emphasis (odd number of *)")
+		}
+		if spacedLink.MatchString(text) {
+			add(lineNo, "space between ] and ( in a link")
+		}
+		if openLink.MatchString(text) {
+			add(lineNo, "link target is never closed with )")
+		}
+		if strings.Count(text, "[") != strings.Count(text, "]") {
+			add(lineNo, "unbalanced [ and ]")
+		}
+	}
+	checkError(scanner.Err())
+	return issues
+}
+
+var (
+	linted   = map[string]bool{}
+	lintedMu sync.Mutex
+)
+
+// print whatever lint finds, but keep going. Once per file is enough
+// even though we come through here for every language.
+func warnLint(path string) {
+	lintedMu.Lock()
+	done := linted[path]
+	linted[path] = true
+	lintedMu.Unlock()
+	if done {
+		return
+	}
+	for _, i := range lintFile(path) {
+		fmt.Printf("warning: %s\n", i)
+	}
+}
+
+// translator lint [dir or file], exits 1 if there's anything to fix
+func lintCmd(args []string) {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	configFile := flags.String("config", defaultConfigFile, "config file")
+	flags.StringVar(&profile, "profile", profile, "config profile to use")
+	flags.Parse(args)
+	conf = loadConfig(*configFile)
+	dir := "."
+	if flags.NArg() > 0 {
+		dir = flags.Arg(0)
+	}
+	fi, err := os.Stat(dir)
+	checkError(err)
+	files := []string{dir}
+	if fi.IsDir() {
+		files = sourcePages(conf.Source, dir)
+	}
+	found := 0
+	for _, f := range files {
+		for _, i := range lintFile(f) {
+			fmt.Println(i)
+			found++
+		}
+	}
+	if found > 0 {
+		fmt.Printf("%d problem(s) found\n", found)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// values that are data, not words: a front matter color, a flag that's
+// "true", a date with a time on it, an email address. The API happily
+// translates those ("vrai", "#FFAA00" spelled out), so a segment that's
+// nothing but one of them is kept as it is. Numbers and plain dates have
+// no letters and are kept anyway, see nothingToTranslate.
+var literalKinds = map[string]*regexp.Regexp{
+	"numbers":  regexp.MustCompile(`^[-+]?(\d{1,3}(,\d{3})+|\d*\.?\d+)([eE][-+]?\d+)?%?$`),
+	"booleans": regexp.MustCompile(`^(?i:true|false|null)$`),
+	"colors":   regexp.MustCompile(`^(#[0-9a-fA-F]{3,4}|#[0-9a-fA-F]{6}|#[0-9a-fA-F]{8}|(?i:rgba?|hsla?)\([^)]*\))$`),
+	"dates":    regexp.MustCompile(`^\d{4}-\d{2}-\d{2}([T ]\d{2}:\d{2}(:\d{2}(\.\d+)?)?(Z|[-+]\d{2}:?\d{2})?)?$`),
+	"urls":     regexp.MustCompile(`^(?:` + bareURL.String() + `)$`),
+	"emails":   regexp.MustCompile(`^(?i:mailto:)?` + emailRe.String() + `$`),
+}
+
+var defaultLiterals = []string{"numbers", "booleans", "colors", "dates", "urls", "emails"}
+
+var literalPatterns []*regexp.Regexp
+
+func loadLiteralPatterns() []*regexp.Regexp {
+	if literalPatterns == nil {
+		literalPatterns = []*regexp.Regexp{}
+		for _, k := range conf.Literals.Kinds {
+			literalPatterns = append(literalPatterns, literalKinds[k])
+		}
+		for _, p := range conf.Literals.Patterns {
+			literalPatterns = append(literalPatterns, regexp.MustCompile(`^(?:`+p+`)$`))
+		}
+	}
+	return literalPatterns
+}
+
+// is the whole segment, quotes and spaces aside, one of literals.kinds or
+// literals.patterns?
+func isLiteral(text string) bool {
+	text = strings.TrimSpace(text)
+	if len(text) >= 2 && (text[0] == '"' || text[0] == '\'') && text[len(text)-1] == text[0] {
+		text = strings.TrimSpace(text[1 : len(text)-1])
+	}
+	if text == "" {
+		return false
+	}
+	for _, re := range loadLiteralPatterns() {
+		if re.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// any OpenAI compatible chat completions API
+type llmProvider struct {
+	endpoint string
+	model    string
+	key      string
+	styles   map[string]string // language -> style guide text
+}
+
+func newLLMProvider() *llmProvider {
+	c := conf.Translation.LLM
+	p := &llmProvider{
+		endpoint: strings.TrimRight(c.Endpoint, "/"),
+		model:    c.Model,
+		styles:   map[string]string{},
+	}
+	if p.model == "" {
+		checkError(fmt.Errorf("the llm provider needs translation.llm.model"))
+	}
+	if p.endpoint == "" {
+		p.endpoint = "https://api.openai.com/v1"
+	}
+	keyEnv := c.KeyEnv
+	if keyEnv == "" {
+		keyEnv = "OPENAI_API_KEY"
+	}
+	p.key = os.Getenv(keyEnv)
+	if p.key == "" {
+		checkError(fmt.Errorf("the llm provider needs an API key in $%s", keyEnv))
+	}
+	for lang, path := range c.StyleGuides {
+		guide, err := os.ReadFile(path)
+		checkError(err)
+		p.styles[lang] = string(guide)
+	}
+	return p
+}
+
+func (p *llmProvider) Name() string {
+	return "llm:" + p.model
+}
+
+// the style guide goes in the cache key, so changing the guide for a
+// language means everything in that language gets translated again
+func (p *llmProvider) variant(lang string) string {
+	sum := sha256.Sum256([]byte(p.styles[lang]))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (p *llmProvider) prompt(from string, to string) string {
+	prompt := fmt.Sprintf("You translate Markdown from %s to %s for a Hugo website. "+
+		"You will get a JSON array of strings. Answer with only a JSON array of the translated strings, in the same order. "+
+		"Keep Markdown, HTML, shortcodes, URLs and placeholders like ⟦T0000⟧ or __0__ exactly as they are.", apiCode(from), apiCode(to))
+	if guide := p.styles[to]; guide != "" {
+		prompt += "\n\nFollow this style guide:\n\n" + guide
+	}
+	return prompt
+}
+
+func (p *llmProvider) Translate(texts []string, from string, to string) ([]string, error) {
+	return p.complete(texts, from, to)
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// send one batch to the chat completions API
+func (p *llmProvider) complete(batch []string, from string, to string) ([]string, error) {
+	return p.chat(p.prompt(from, to), batch, len(batch))
+}
+
+// the second pass for pages that deserve it: a machine translation gets
+// post-edited, with the source to check it against
+func (p *llmProvider) refinePrompt(from string, to string) string {
+	prompt := fmt.Sprintf("You post-edit machine translations from %s to %s for a Hugo website. "+
+		"You will get a JSON array of [source, draft translation] pairs. Fix mistranslations, awkward phrasing and terminology in each draft. "+
+		"Answer with only a JSON array of the improved translations, in the same order. "+
+		"Keep Markdown, HTML, shortcodes, URLs and placeholders like ⟦T0000⟧ or __0__ exactly as they are.", apiCode(from), apiCode(to))
+	if guide := p.styles[to]; guide != "" {
+		prompt += "\n\nFollow this style guide:\n\n" + guide
+	}
+	return prompt
+}
+
+func (p *llmProvider) refine(sources []string, drafts []string, from string, to string) ([]string, error) {
+	var pairs [][2]string
+	for i := range sources {
+		pairs = append(pairs, [2]string{sources[i], drafts[i]})
+	}
+	return p.chat(p.refinePrompt(from, to), pairs, len(pairs))
+}
+
+// people's names, written the way they're said in the script to uses,
+// for data.names.transliterate
+func (p *llmProvider) transliterate(names []string, to string) ([]string, error) {
+	prompt := fmt.Sprintf("You write people's names in the script used for %s, the way they are pronounced. "+
+		"You will get a JSON array of names. Answer with only a JSON array of the names, in the same order. "+
+		"Never translate what a name means, and keep a name that's already in that script as it is.", apiCode(to))
+	return p.chat(prompt, names, len(names))
+}
+
+// ask the model, in is sent as JSON and n strings are expected back
+func (p *llmProvider) chat(system string, batch interface{}, n int) ([]string, error) {
+	in, err := json.Marshal(batch)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"model":       p.model,
+		"temperature": 0,
+		"messages": []chatMessage{
+			{"system", system},
+			{"user", string(in)},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", p.endpoint+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.key)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("llm: %s: %s", resp.Status, raw)
+	}
+	var answer struct {
+		Choices []struct {
+			Message chatMessage `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(raw, &answer); err != nil {
+		return nil, fmt.Errorf("llm: %v", err)
+	}
+	if len(answer.Choices) == 0 {
+		return nil, fmt.Errorf("llm: no answer")
+	}
+	content := strings.TrimSpace(answer.Choices[0].Message.Content)
+	// some models wrap the JSON in a code fence no matter what you ask
+	content = strings.TrimPrefix(strings.TrimPrefix(content, "```json"), "```")
+	content = strings.TrimSpace(strings.TrimSuffix(content, "```"))
+	var out []string
+	if err := json.Unmarshal([]byte(content), &out); err != nil {
+		return nil, fmt.Errorf("llm: answer isn't a JSON array: %v", err)
+	}
+	if len(out) != n {
+		return nil, fmt.Errorf("llm: sent %d strings, got %d back", n, len(out))
+	}
+	return out, nil
+}
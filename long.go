@@ -0,0 +1,114 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// providers turn down anything over a few thousand characters, and one
+// long paragraph (or a description, or a bio in a data file) shouldn't
+// fail the page. A segment longer than translation.max_chars goes a few
+// sentences at a time and is put back together afterwards.
+
+// the end of a sentence, and the space after it
+var sentenceEnd = regexp.MustCompile(`[.!?…]["'”’»)\]]*\s+|[。！？]["'”’」』）]*\s*`)
+
+// text in pieces of at most max_chars, split between sentences if it
+// can be, then between words, and only then anywhere. Each piece keeps
+// the space that came after it, so they join back up as they were.
+func splitLong(text string) []string {
+	max := conf.Translation.MaxChars
+	if max <= 0 || utf8.RuneCountInString(text) <= max {
+		return []string{text}
+	}
+	var sentences []string
+	start := 0
+	for _, m := range sentenceEnd.FindAllStringIndex(text, -1) {
+		sentences = append(sentences, text[start:m[1]])
+		start = m[1]
+	}
+	if start < len(text) {
+		sentences = append(sentences, text[start:])
+	}
+	var chunks []string
+	chunk := ""
+	for _, s := range sentences {
+		if chunk != "" && utf8.RuneCountInString(chunk+s) > max {
+			chunks = append(chunks, chunk)
+			chunk = ""
+		}
+		for utf8.RuneCountInString(s) > max { // a sentence that long is words, or not even that
+			cut := cutAt(s, max)
+			chunks = append(chunks, s[:cut])
+			s = s[cut:]
+		}
+		chunk += s
+	}
+	if chunk != "" {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// where to cut s so the first part is at most max runes: after the last
+// space that fits, or at max if there isn't one
+func cutAt(s string, max int) int {
+	end := len(s)
+	n := 0
+	for i := range s {
+		if n == max {
+			end = i
+			break
+		}
+		n++
+	}
+	if sp := strings.LastIndexAny(s[:end], " \t\n"); sp > 0 {
+		return sp + 1
+	}
+	return end
+}
+
+// translateText, a piece at a time if it's too long
+func translateLong(from string, to string, text string, where string) (string, error) {
+	chunks := splitLong(text)
+	if len(chunks) == 1 {
+		return translateText(from, to, text, where)
+	}
+	var out strings.Builder
+	gap := ""
+	for i, c := range chunks {
+		translated, err := translateText(from, to, strings.TrimSpace(c), where)
+		if err != nil {
+			return "", err
+		}
+		lead := c[:len(c)-len(strings.TrimLeft(c, " \t\n"))]
+		if i == 0 {
+			out.WriteString(lead)
+		} else {
+			out.WriteString(sentenceGap(gap+lead, to))
+		}
+		out.WriteString(strings.TrimSpace(translated))
+		gap = c[len(strings.TrimRight(c, " \t\n")):]
+	}
+	out.WriteString(gap)
+	return out.String(), nil
+}
+
+// what goes between two translated pieces, for what was between them in
+// the source. A line break stays, otherwise it's how the language does
+// it: nothing between sentences in Chinese or Japanese, a space in the
+// rest, even if the source was one of those.
+func sentenceGap(gap string, lang string) string {
+	if strings.Contains(gap, "\n") {
+		return gap
+	}
+	switch strings.ToLower(strings.FieldsFunc(apiCode(lang), func(r rune) bool { return r == '-' || r == '_' })[0]) {
+	case "zh", "ja", "yue":
+		return ""
+	}
+	if gap == "" {
+		return " "
+	}
+	return gap
+}
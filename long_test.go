@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSplitLong(t *testing.T) {
+	conf = defaultConfig()
+	for _, c := range []struct {
+		max  int
+		text string
+		want []string
+	}{
+		{0, "One. Two. Three.", []string{"One. Two. Three."}},
+		{100, "One. Two. Three.", []string{"One. Two. Three."}},
+		{10, "One. Two. Three.", []string{"One. Two. ", "Three."}},
+		{12, "Is it? Yes! Done…", []string{"Is it? Yes! ", "Done…"}},
+		{8, "一つ。二つ。三つ。四つ。", []string{"一つ。二つ。", "三つ。四つ。"}},
+		{10, "Averyveryverylongword and more", []string{"Averyveryv", "erylongwor", "d and more"}},
+		{10, "Some words that go on without a stop", []string{"Some ", "words ", "that go ", "on ", "without a ", "stop"}},
+	} {
+		conf.Translation.MaxChars = c.max
+		got := splitLong(c.text)
+		if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", c.want) {
+			t.Errorf("splitLong(%q) at %d = %q, want %q", c.text, c.max, got, c.want)
+		}
+		if strings.Join(got, "") != c.text {
+			t.Errorf("splitLong(%q) doesn't join back up: %q", c.text, got)
+		}
+	}
+}
+
+func TestSentenceGap(t *testing.T) {
+	conf = defaultConfig()
+	conf.Codes = map[string]LanguageCodes{"zh-hans": {API: "zh-CN"}}
+	for _, c := range []struct {
+		gap, lang, want string
+	}{
+		{" ", "fr", " "},
+		{"  ", "fr", "  "},
+		{"", "fr", " "}, // after a 。 with nothing after it
+		{"\n", "fr", "\n"},
+		{" ", "ja", ""},
+		{" ", "zh-hans", ""},
+		{"\n", "ja", "\n"},
+	} {
+		if got := sentenceGap(c.gap, c.lang); got != c.want {
+			t.Errorf("sentenceGap(%q, %s) = %q, want %q", c.gap, c.lang, got, c.want)
+		}
+	}
+}
+
+func TestTranslateLong(t *testing.T) {
+	memSite(t, nil)
+	current = markProvider{}
+	conf.Translation.MaxChars = 10
+	for _, c := range []struct {
+		from, to, text, want string
+	}{
+		{"en", "fr", "One. Two. Three.", "«One. Two.» «Three.»"},
+		{"en", "ja", "One. Two. Three.", "«One. Two.»«Three.»"},
+		{"ja", "fr", "一つ。二つ。三つ。四つ。", "«一つ。二つ。三つ。» «四つ。»"},
+		{"en", "fr", " Short. ", "« Short. »"},
+	} {
+		got, err := translateLong(c.from, c.to, c.text, "a.md:1")
+		if err != nil || got != c.want {
+			t.Errorf("translateLong(%q, %s) = %q, %v, want %q", c.text, c.to, got, err, c.want)
+		}
+	}
+}
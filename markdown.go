@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// markdown.parser "goldmark": instead of guessing at the body a line at
+// a time, it's parsed the way Hugo parses it, and what's translated is
+// the text of each paragraph, heading, list item and table cell. A
+// paragraph goes as one segment however many lines it's wrapped over, so
+// the provider sees whole sentences, and comes back on one line. Code,
+// HTML, link definitions and anything else that isn't text are copied
+// byte for byte, so there's nothing for the regexes to fix afterwards.
+// Shortcodes are still done a line at a time, their bodies are text to
+// goldmark.
+
+var markdownParser = goldmark.New(
+	goldmark.WithExtensions(extension.GFM, extension.Footnote, extension.DefinitionList),
+	goldmark.WithParserOptions(parser.WithHeadingAttribute()),
+).Parser()
+
+// a part of the body that's replaced by its translation
+type mdEdit struct {
+	start, stop int
+	text        string // what's sent, "" if it's a shortcode line
+}
+
+// the blocks whose text is prose
+func textBlock(n ast.Node) bool {
+	switch n.Kind() {
+	case ast.KindParagraph, ast.KindHeading, ast.KindTextBlock, extast.KindTableCell, extast.KindDefinitionTerm:
+		return true
+	}
+	return false
+}
+
+// the shortcode lines in body, start of the line -> where it ends, and
+// the lines inside a shortcode whose body is code
+func shortcodeLines(body []byte) (map[int]int, map[int]bool) {
+	lines := map[int]int{}
+	inside := map[int]bool{}
+	scBody := ""
+	for pos := 0; pos < len(body); {
+		end := bytes.IndexByte(body[pos:], '\n')
+		if end < 0 {
+			end = len(body) - pos
+		}
+		ln := strings.TrimRight(string(body[pos:pos+end]), "\r")
+		switch {
+		case scBody != "":
+			inside[pos] = true
+			if closesShortcode(ln, scBody) {
+				scBody = ""
+			}
+		case strings.HasPrefix(ln, "{{"):
+			lines[pos] = pos + len(ln)
+			_, scBody = shortcodeLine(ln, func(s string) string { return s })
+		}
+		pos += end + 1
+	}
+	return lines, inside
+}
+
+// the start of the line pos is on
+func lineStart(body []byte, pos int) int {
+	return bytes.LastIndexByte(body[:pos], '\n') + 1
+}
+
+// what's to be translated in body, in order
+func markdownEdits(body []byte) []mdEdit {
+	scLines, scInside := shortcodeLines(body)
+	var edits []mdEdit
+	doc := markdownParser.Parse(text.NewReader(body))
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering || n.Type() != ast.TypeBlock || !textBlock(n) {
+			return ast.WalkContinue, nil
+		}
+		lines := n.Lines()
+		quoted := n.Kind() == ast.KindParagraph && n.Parent().Kind() == ast.KindBlockquote && n.Parent().FirstChild() == n
+		var piece []string
+		start, stop := -1, -1
+		flush := func() {
+			if start >= 0 && strings.TrimSpace(strings.Join(piece, "")) != "" {
+				edits = append(edits, mdEdit{start, stop, strings.Join(piece, " ")})
+			}
+			piece, start = nil, -1
+		}
+		for i := 0; i < lines.Len(); i++ {
+			seg := lines.At(i)
+			ls := lineStart(body, seg.Start)
+			if scInside[ls] {
+				flush()
+				continue
+			}
+			if end, ok := scLines[ls]; ok { // its arguments, if they're prose
+				flush()
+				edits = append(edits, mdEdit{start: ls, stop: end})
+				continue
+			}
+			raw := strings.TrimRight(string(seg.Value(body)), "\r\n")
+			title := false
+			if lead := len(raw) - len(strings.TrimLeft(raw, " \t")); i == 0 && quoted {
+				if tok := calloutType.FindString(raw[lead:]); tok != "" { // > [!note], see callouts.go
+					seg.Start += lead + len(tok)
+					raw = raw[lead+len(tok):]
+					title = true // a line of its own, not the start of the body
+				}
+			}
+			content := strings.TrimRight(raw, " \t")
+			hard := title || strings.HasSuffix(raw, "  ") || strings.HasSuffix(content, "\\") // a line break that's meant
+			if strings.HasSuffix(content, "\\") && n.Kind() == ast.KindParagraph {
+				content = content[:len(content)-1]
+			}
+			if start < 0 {
+				start = seg.Start + len(raw) - len(strings.TrimLeft(raw, " \t"))
+			}
+			piece = append(piece, strings.TrimSpace(content))
+			stop = seg.Start + len(content)
+			if hard {
+				flush()
+			}
+		}
+		flush()
+		return ast.WalkSkipChildren, nil
+	})
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+	return edits
+}
+
+// the body, from line firstLine of readFile, translated to xfile
+func xlateMarkdown(xfile io.StringWriter, body []byte, firstLine int, readFile string, xl func(text string, where string) string) {
+	last := 0
+	for _, e := range markdownEdits(body) {
+		where := fmt.Sprintf("%s:%d", readFile, firstLine+bytes.Count(body[:e.start], []byte("\n")))
+		tr := func(s string) string { return xl(s, where) }
+		xfile.WriteString(string(body[last:e.start]))
+		if e.text == "" {
+			ln, _ := shortcodeLine(string(body[e.start:e.stop]), tr)
+			xfile.WriteString(ln)
+		} else {
+			xfile.WriteString(tr(e.text))
+		}
+		last = e.stop
+	}
+	xfile.WriteString(string(body[last:]))
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// swaps bits of text we don't want the API to see (or mess with) for
+// numbered placeholders and puts them back afterwards
+type masker struct {
+	saved []string
+}
+
+// a way of writing placeholders. The first of translation.placeholders
+// is what the provider sees; a segment that comes back without all of
+// them goes again in the next.
+type placeholderStyle struct {
+	format string         // with the number
+	re     *regexp.Regexp // one of them, and the spaces the API likes to put in
+}
+
+var placeholderStyles = map[string]placeholderStyle{
+	// brackets no language writes prose with and a fixed width, so
+	// there's nothing in them to translate, reorder or tidy up
+	"brackets":    {"⟦T%04d⟧", regexp.MustCompile(`⟦ ?[Tt] ?(\d+) ?⟧`)},
+	"underscores": {"__%d__", regexp.MustCompile(`__ ?(\d+) ?__`)},
+}
+
+func placeholderStyleNames() []string {
+	if len(conf.Translation.Placeholders) == 0 {
+		return defaultConfig().Translation.Placeholders
+	}
+	return conf.Translation.Placeholders
+}
+
+// the style masked segments are sent in
+func sentStyle() placeholderStyle {
+	return placeholderStyles[placeholderStyleNames()[0]]
+}
+
+func placeholder(i int) string {
+	return fmt.Sprintf(sentStyle().format, i)
+}
+
+// a placeholder in any of the styles
+var placeholderRe = regexp.MustCompile(`⟦ ?[Tt] ?(\d+) ?⟧|__ ?(\d+) ?__`)
+
+// which one it is, s being one in style
+func (style placeholderStyle) number(s string) (int, error) {
+	return strconv.Atoi(style.re.FindStringSubmatch(s)[1])
+}
+
+// every placeholder in text written in from, written in to instead
+func restyle(text string, from placeholderStyle, to placeholderStyle) string {
+	return from.re.ReplaceAllStringFunc(text, func(s string) string {
+		i, err := from.number(s)
+		if err != nil {
+			return s
+		}
+		return fmt.Sprintf(to.format, i)
+	})
+}
+
+// the placeholders in sent, in style, that didn't all come back in got
+func lostPlaceholders(sent string, got string, style placeholderStyle) []string {
+	count := map[int]int{}
+	for _, s := range style.re.FindAllString(got, -1) {
+		if i, err := style.number(s); err == nil {
+			count[i]++
+		}
+	}
+	var lost []string
+	for _, s := range style.re.FindAllString(sent, -1) {
+		i, err := style.number(s)
+		if err != nil {
+			continue
+		}
+		if count[i] == 0 {
+			lost = append(lost, s)
+		}
+		count[i]--
+	}
+	return lost
+}
+
+// translateLong, and if any of the placeholders go missing on the way,
+// again with the next style in translation.placeholders. If none of them
+// get through the first translation is kept, and a warning says what it
+// lost.
+func translateMasked(from string, to string, send string, where string) (string, error) {
+	translated, err := translateLong(from, to, send, where)
+	first := sentStyle()
+	lost := lostPlaceholders(send, translated, first)
+	if err != nil || len(lost) == 0 {
+		return translated, err
+	}
+	for _, name := range placeholderStyleNames()[1:] {
+		style := placeholderStyles[name]
+		if style.re.MatchString(send) { // it'd be one of ours when it came back
+			continue
+		}
+		fmt.Printf("warning: %s (%s): %s came back without %s, trying %s instead\n", where, to, provider().Name(), strings.Join(lost, " "), fmt.Sprintf(style.format, 0))
+		again := restyle(send, first, style)
+		out, err := translateLong(from, to, again, where)
+		if err == nil && len(lostPlaceholders(again, out, style)) == 0 && !first.re.MatchString(out) {
+			return restyle(out, style, first), nil
+		}
+	}
+	fmt.Printf("warning: %s (%s): the translation is missing %s, so is what they stood for\n", where, to, strings.Join(lost, " "))
+	return translated, nil
+}
+
+// the cached translation of a masked segment. Before there was a
+// choice of placeholders they were all __N__, and a translation cached
+// then is just as good now, put back into this style.
+func (c *transCache) getMasked(provider string, from string, to string, text string) (string, bool) {
+	if hit, ok := c.get(provider, from, to, text); ok {
+		return hit, true
+	}
+	style, old := sentStyle(), placeholderStyles["underscores"]
+	if style.format == old.format || !style.re.MatchString(text) || old.re.MatchString(text) {
+		return "", false
+	}
+	before := restyle(text, style, old)
+	hit, ok := c.get(provider, from, to, before)
+	if !ok || len(lostPlaceholders(before, hit, old)) > 0 {
+		return "", false
+	}
+	return restyle(hit, old, style), true
+}
+
+var bareURL = regexp.MustCompile(`(?i)\b(https?|ftp)://\S+|\bwww\.\S+|\bmailto:\S+`)
+
+// no letters in it once placeholders and links are out of the way: blank
+// values, numbers, punctuation, emoji and bare URLs. Those cost money to
+// send and sometimes come back changed, so they're kept as they are.
+func nothingToTranslate(text string) bool {
+	text = placeholderRe.ReplaceAllString(text, "")
+	text = bareURL.ReplaceAllString(text, "")
+	return strings.IndexFunc(text, unicode.IsLetter) < 0
+}
+
+// replace everything re matches (and keep passes OK) with placeholders
+func (m *masker) mask(text string, re *regexp.Regexp, keep func(string) bool) string {
+	return re.ReplaceAllStringFunc(text, func(s string) string {
+		if keep != nil && !keep(s) {
+			return s
+		}
+		m.saved = append(m.saved, s)
+		return placeholder(len(m.saved) - 1)
+	})
+}
+
+// only what's in the style that was sent: __3__ in a translation that
+// went as ⟦T0003⟧ is something else
+func (m *masker) unmask(text string) string {
+	if len(m.saved) == 0 {
+		return text
+	}
+	style := sentStyle()
+	return style.re.ReplaceAllStringFunc(text, func(s string) string {
+		i, err := style.number(s)
+		if err != nil || i >= len(m.saved) {
+			return s
+		}
+		return m.saved[i]
+	})
+}
+
+var (
+	emailRe = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	phoneRe = regexp.MustCompile(`(\+\d{1,3}[\s.-]?)?\(?\d{3}\)?[\s.-]?\d{3}[\s.-]?\d{4}\b`)
+	// well known key prefixes, and anything long and random looking
+	tokenRe = regexp.MustCompile(`\b((sk|pk|rk)_(live|test)_[A-Za-z0-9]{10,}|gh[pousr]_[A-Za-z0-9]{20,}|xox[abprs]-[A-Za-z0-9-]{10,}|AKIA[A-Z0-9]{16}|AIza[A-Za-z0-9_-]{35}|[A-Za-z0-9_\-]{32,})\b`)
+)
+
+// a 32+ character run only counts as a key if it has letters and digits
+func tokenLike(s string) bool {
+	return strings.ContainsAny(s, "0123456789") && strings.ContainsAny(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+}
+
+// hide email addresses, phone numbers and API keys from the API
+func (m *masker) maskPII(text string) string {
+	text = m.mask(text, emailRe, nil)
+	text = m.mask(text, tokenRe, tokenLike)
+	return m.mask(text, phoneRe, nil)
+}
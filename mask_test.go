@@ -0,0 +1,98 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// a provider that loses ⟦T0000⟧ placeholders, the way some do
+type loseBrackets struct{}
+
+func (loseBrackets) Name() string {
+	return "lose"
+}
+
+func (loseBrackets) Translate(texts []string, from string, to string) ([]string, error) {
+	var out []string
+	for _, t := range texts {
+		out = append(out, strings.ToUpper(regexp.MustCompile(`⟦[^⟧]*⟧`).ReplaceAllString(t, "")))
+	}
+	return out, nil
+}
+
+func TestUnmaskOnlyWhatWasSent(t *testing.T) {
+	conf = defaultConfig()
+	var m masker
+	send := m.mask("mail me@example.com", emailRe, nil)
+	if send != "mail ⟦T0000⟧" {
+		t.Fatalf("masked: %q", send)
+	}
+	if got := m.unmask("écrire à ⟦T0000⟧, __0__ est gras"); got != "écrire à me@example.com, __0__ est gras" {
+		t.Errorf("unmasked: %q", got)
+	}
+}
+
+func TestTranslateMaskedRetries(t *testing.T) {
+	memSite(t, nil)
+	current = loseBrackets{}
+	got, err := translateMasked("en", "fr", "mail ⟦T0000⟧ now", "a.md:1")
+	if err != nil || got != "MAIL ⟦T0000⟧ NOW" {
+		t.Errorf("translateMasked = %q, %v", got, err)
+	}
+	// __1__ is in the text already, so underscores can't be used
+	got, _ = translateMasked("en", "fr", "mail ⟦T0000⟧ __1__", "a.md:1")
+	if strings.Contains(got, "⟦T0000⟧") {
+		t.Errorf("underscores were used when the text has them: %q", got)
+	}
+}
+
+func TestCachedInTheOldStyle(t *testing.T) {
+	memSite(t, nil)
+	c := loadCache()
+	c.put("mark", "en", "fr", "mail __0__ now", "écrire __0__ maintenant")
+	if got, ok := c.getMasked("mark", "en", "fr", "mail ⟦T0000⟧ now"); !ok || got != "écrire ⟦T0000⟧ maintenant" {
+		t.Errorf("getMasked = %q, %v", got, ok)
+	}
+	if _, ok := c.getMasked("mark", "en", "fr", "mail ⟦T0000⟧ later"); ok {
+		t.Errorf("a hit for something that isn't cached")
+	}
+}
+
+func TestMaskSegmentKeepsLookalikes(t *testing.T) {
+	memSite(t, nil)
+	conf.Privacy.MaskPII = true
+	for _, style := range []string{"brackets", "underscores"} {
+		conf.Translation.Placeholders = []string{style}
+		text := "Write to me@example.com or you@example.com, __1__ times ⟦T0001⟧"
+		send, pii := maskSegment("en", "fr", text)
+		if strings.Contains(send, "me@example.com") {
+			t.Errorf("%s: the email was sent: %q", style, send)
+		}
+		if got := pii.unmask(send); got != text {
+			t.Errorf("%s: %q came back as %q", style, text, got)
+		}
+	}
+}
+
+func TestMaskShortcuts(t *testing.T) {
+	memSite(t, nil)
+	conf.Keys.Names = map[string]map[string]string{"de": {"Ctrl": "Strg"}}
+	current = markProvider{}
+	for _, c := range []struct {
+		text, want string
+	}{
+		{"Press <kbd>Ctrl</kbd>+<kbd>C</kbd> to copy.", "«Press <kbd>Strg</kbd>+<kbd>C</kbd> to copy.»"},
+		{"Press <kbd>Ctrl</kbd> + <kbd>Shift</kbd> + <kbd>P</kbd>.", "«Press <kbd>Strg</kbd> + <kbd>Shift</kbd> + <kbd>P</kbd>.»"},
+		{"Press Ctrl+Shift+P.", "«Press Strg+Shift+P.»"},
+		{"Press <kbd>Enter</kbd> and <b>wait</b>.", "«Press <kbd>Enter</kbd> and <b>wait</b>.»"},
+	} {
+		send, _ := maskSegment("en", "de", c.text)
+		if strings.Contains(send, "Ctrl") || strings.Contains(send, "kbd") {
+			t.Errorf("%q: sent %q", c.text, send)
+		}
+		if got := xl("en", "de", c.text); got != c.want {
+			t.Errorf("%q: got %q, want %q", c.text, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+func exists(path string) bool {
+	_, err := files.Stat(path)
+	return err == nil
+}
+
+// translate pages that come from Hugo modules. Hugo lays the project's
+// content over the module's, so a translation written into the mount
+// target overrides (well, adds to) the module without touching it. A page
+// the project has its own copy of is the project's to translate, and one
+// the module already ships in this language is left alone.
+func translateMounts(from string, lang string) {
+	for _, m := range conf.Mounts {
+		for _, src := range sourcePages(from, m.Source) {
+			rel, err := filepath.Rel(m.Source, src)
+			checkError(err)
+			local := filepath.Join(m.Target, rel)
+			if !translatedInto(src, lang) || !translatedInto(local, lang) { // pages.languages, by either path
+				continue
+			}
+			if _, err := projectFiles().Stat(local); err == nil || exists(targetFor(src, from, lang)) {
+				continue
+			}
+			toFile := targetFor(local, from, lang)
+			if exists(toFile) && !retranslate(src, toFile) {
+				countSkipped(lang)
+				continue
+			}
+			if reason := skipReason(src); reason != "" {
+				fmt.Printf("Skipping:\t %s (%s)\n", src, reason)
+				countSkipped(lang)
+				continue
+			}
+			if strictSkip(src) {
+				countSkipped(lang)
+				continue
+			}
+			checkError(files.MkdirAll(filepath.Dir(toFile)))
+			fmt.Printf("Translating:\t %s\nto: \t\t%s\n", src, toFile)
+			warnLint(src)
+			state.mark(src, toFile, lang, "failed")
+			status := translatePage(from, lang, src, toFile)
+			if status == "" {
+				continue
+			}
+			commitTranslation(toFile)
+			state.mark(src, toFile, lang, status)
+			if status == "done" {
+				countCreated(lang)
+			}
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// front_matter.noindex: fields like robots: noindex that go in every
+// machine translation, so search engines leave it alone until someone
+// has been through it. Locking a translation with translation: manual
+// says it's been reviewed, and the next run takes them back out.
+
+// the front_matter.noindex fields for a translation, the source's own
+// values for them aren't copied
+func noindexFrontMatter() string {
+	var keys []string
+	for k := range conf.FrontMatter.Noindex {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var out strings.Builder
+	for _, k := range keys {
+		out.WriteString(k + ": " + conf.FrontMatter.Noindex[k] + "\n")
+	}
+	return out.String()
+}
+
+func noindexKey(key string) bool {
+	for k := range conf.FrontMatter.Noindex {
+		if strings.EqualFold(k, strings.TrimSpace(key)) {
+			return true
+		}
+	}
+	return false
+}
+
+// is key: value one of front_matter.noindex, as it is there?
+func noindexValue(key string, value string) bool {
+	for k, v := range conf.FrontMatter.Noindex {
+		if strings.EqualFold(k, strings.TrimSpace(key)) && unquote(value) == unquote(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// take the noindex fields out of the reviewed translations of pages
+func indexReviewed(from string, pages []string) {
+	if len(conf.FrontMatter.Noindex) == 0 {
+		return
+	}
+	for _, p := range pages {
+		for _, lang := range conf.Languages {
+			target := targetFor(p, from, lang)
+			if reviewed(target) && dropNoindex(target) {
+				fmt.Printf("Indexing:\t %s (reviewed, so it's not noindex any more)\n", target)
+			}
+		}
+	}
+}
+
+// drop the front matter lines that are still what front_matter.noindex
+// says, one someone has changed is theirs
+func dropNoindex(target string) bool {
+	src, err := files.ReadFile(target)
+	checkError(err)
+	end := frontMatterEnd(src)
+	if end < 0 {
+		return false
+	}
+	var out strings.Builder
+	dropped := false
+	for i, ln := range strings.SplitAfter(string(src[:end]), "\n") {
+		kv := strings.SplitN(strings.TrimRight(ln, "\r\n"), ":", 2)
+		if i > 0 && len(kv) == 2 && !strings.HasPrefix(kv[0], " ") && noindexValue(kv[0], kv[1]) {
+			dropped = true
+			continue
+		}
+		out.WriteString(ln)
+	}
+	if !dropped {
+		return false
+	}
+	out.Write(src[end:])
+	checkError(files.WriteFile(target, []byte(out.String())))
+	return true
+}
@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestNoindexInPages(t *testing.T) {
+	conf = defaultConfig()
+	conf.FrontMatter.Noindex = map[string]string{"robots": "noindex", "sitemap_exclude": "true"}
+	for _, c := range []struct {
+		src, want string
+	}{
+		{"---\ntitle: Hi\n---\n", "---\ntitle: < Hi>\nrobots: noindex\nsitemap_exclude: true\n---\n"},
+		{"---\nrobots: all\ntitle: Hi\n---\n", "---\ntitle: < Hi>\nrobots: noindex\nsitemap_exclude: true\n---\n"}, // the source's doesn't go
+		{"No front matter.\n", "<No front matter.>\n"},
+	} {
+		if got := xlatePage(t, c.src); got != c.want {
+			t.Errorf("%q:\n%s\nwant\n%s", c.src, got, c.want)
+		}
+	}
+}
+
+func TestDropNoindex(t *testing.T) {
+	for _, c := range []struct {
+		page, want string
+		dropped    bool
+	}{
+		{"---\ntitle: Salut\nrobots: noindex\ntranslation: manual\n---\nrobots: noindex\n", "---\ntitle: Salut\ntranslation: manual\n---\nrobots: noindex\n", true},
+		{"---\ntitle: Salut\nrobots: \"noindex\"\n---\n", "---\ntitle: Salut\n---\n", true},
+		{"---\ntitle: Salut\nrobots: noindex, nofollow\n---\n", "---\ntitle: Salut\nrobots: noindex, nofollow\n---\n", false}, // someone changed it
+		{"---\ntitle: Salut\nparams:\n  robots: noindex\n---\n", "---\ntitle: Salut\nparams:\n  robots: noindex\n---\n", false},
+		{"robots: noindex\n", "robots: noindex\n", false},
+	} {
+		m := memSite(t, map[string]string{"content/a/index.fr.md": c.page})
+		conf.FrontMatter.Noindex = map[string]string{"robots": "noindex"}
+		if got := dropNoindex("content/a/index.fr.md"); got != c.dropped {
+			t.Errorf("%q: dropped %v, want %v", c.page, got, c.dropped)
+		}
+		if got := m.dump()["content/a/index.fr.md"]; got != c.want {
+			t.Errorf("%q:\n%s\nwant\n%s", c.page, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// og_images.manifest: the titles an Open Graph card generator draws on
+// the images it makes for each page. It's a data file like any other,
+// anywhere in the site, and each language gets its own copy next to it,
+// cards.fr.json for cards.json, for the generator to make that
+// language's cards from. A card only has so much room, so og_images
+// max_chars warns about titles that have got too long to fit.
+
+// the manifest as a data file
+func ogDataFile() DataFile {
+	og := conf.OGImages
+	f := DataFile{Path: og.Manifest, Translate: og.Translate, Out: og.Out, MaxChars: og.MaxChars}
+	if len(f.Translate) == 0 {
+		f.Translate = []string{"title", "-"}
+	}
+	if f.Out == "" {
+		ext := filepath.Ext(og.Manifest)
+		f.Out = strings.TrimSuffix(og.Manifest, ext) + ".{lang}" + ext
+	}
+	return f
+}
+
+func translateOGImages(from string, lang string) {
+	if conf.OGImages.Manifest == "" {
+		return
+	}
+	translateDataTo(ogDataFile(), from, lang)
+}
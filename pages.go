@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// pages Hugo never renders on their own: headless bundles and anything
+// with _build (or build, since Hugo 0.123) render set to never. They're
+// usually there to hold resources for other pages, so unless asked to we
+// don't spend any quota on them.
+func isHidden(lines []string) bool {
+	if v, ok := fmValue(lines, "headless"); ok && v == "true" {
+		return true
+	}
+	for _, parent := range []string{"_build", "build"} {
+		if v, ok := fmNested(lines, parent, "render"); ok && (v == "never" || v == "false") {
+			return true
+		}
+	}
+	return false
+}
+
+// why a page shouldn't be translated, or "" if it should
+func skipReason(path string) string {
+	src, err := files.ReadFile(path)
+	checkError(err)
+	lines := frontMatterLines(src)
+	if !conf.Pages.TranslateHidden && isHidden(lines) {
+		return "headless or never rendered"
+	}
+	now := time.Now()
+	if conf.Pages.SkipExpired {
+		if t, ok := fmDate(lines, "expiryDate", "unpublishdate"); ok && t.Before(now) {
+			return "expired " + t.Format("2006-01-02")
+		}
+	}
+	if days := conf.Pages.FutureDays; days > 0 {
+		if t, ok := fmDate(lines, "publishDate", "pubdate", "published"); ok && t.After(now.AddDate(0, 0, days)) {
+			return fmt.Sprintf("publishes %s, more than %d days away", t.Format("2006-01-02"), days)
+		}
+	}
+	return ""
+}
+
+// pages.languages: the languages a page is translated into. Not every
+// section is worth every language, "content/blog": ["fr", "de"] keeps
+// the blog to two of them. A section is a glob or a path prefix, and
+// dir/** is everything under dir. The most specific one a page is in
+// wins, ["*"] is all of conf.Languages, and so is a page in none.
+func languagesFor(path string) []string {
+	path = filepath.ToSlash(filepath.Clean(path))
+	var langs []string
+	longest := -1
+	for section, l := range conf.Pages.Languages {
+		s := strings.TrimSuffix(filepath.ToSlash(filepath.Clean(section)), "/**")
+		if ok, _ := filepath.Match(s, path); (ok || strings.HasPrefix(path, s+"/")) && len(s) > longest {
+			langs, longest = l, len(s)
+		}
+	}
+	if longest < 0 || (len(langs) == 1 && langs[0] == "*") {
+		return conf.Languages
+	}
+	var out []string
+	for _, lang := range conf.Languages { // in the config's order, and only ones it has
+		for _, l := range langs {
+			if l == lang {
+				out = append(out, lang)
+				break
+			}
+		}
+	}
+	return out
+}
+
+func translatedInto(path string, lang string) bool {
+	for _, l := range languagesFor(path) {
+		if l == lang {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLanguagesFor(t *testing.T) {
+	conf = defaultConfig()
+	conf.Languages = []string{"fr", "de", "es"}
+	conf.Pages.Languages = map[string][]string{
+		"content/blog/**":     {"de", "fr"},
+		"content/blog/big/**": {"*"},
+		"content/news":        {"es"},
+	}
+	for _, c := range []struct {
+		path string
+		want []string
+	}{
+		{"content/docs/a/index.en.md", []string{"fr", "de", "es"}},
+		{"content/blog/a/index.en.md", []string{"fr", "de"}},
+		{"content/blog/big/index.en.md", []string{"fr", "de", "es"}},
+		{"content/news/a/index.en.md", []string{"es"}},
+	} {
+		if got := languagesFor(c.path); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("languagesFor(%s) = %v, want %v", c.path, got, c.want)
+		}
+	}
+	alt := alternatesFrontMatter("fr", "content/blog/a/index.en.md", "content/blog/a/index.fr.md")
+	if strings.Contains(alt, "lang: es") || !strings.Contains(alt, "lang: de") || !strings.Contains(alt, "lang: en") {
+		t.Errorf("alternates for a blog page:\n%s", alt)
+	}
+}
+
+func TestSkipDates(t *testing.T) {
+	soon := time.Now().AddDate(0, 0, 5).Format("2006-01-02")
+	for _, c := range []struct {
+		expired bool
+		future  int
+		fm      string
+		want    string
+	}{
+		{false, 0, "expiryDate: 2000-01-01", ""},
+		{true, 0, "expiryDate: 2000-01-01", "expired 2000-01-01"},
+		{true, 0, "unpublishdate: \"2000-01-01T10:00:00Z\"", "expired 2000-01-01"},
+		{true, 0, "expiryDate: 2999-01-01", ""},
+		{true, 0, "expiryDate: someday", ""},
+		{false, 0, "publishDate: 2999-01-01", ""},
+		{false, 30, "publishDate: 2999-01-01 09:00:00", "publishes 2999-01-01, more than 30 days away"},
+		{false, 30, "pubdate: 2999-01-01T09:00:00", "publishes 2999-01-01, more than 30 days away"},
+		{false, 30, "publishDate: " + soon, ""},
+		{false, 30, "publishDate: 2000-01-01", ""},
+	} {
+		memSite(t, map[string]string{"content/a/index.en.md": "---\ntitle: Hi\n" + c.fm + "\n---\nText.\n"})
+		conf.Pages.SkipExpired = c.expired
+		conf.Pages.FutureDays = c.future
+		if got := skipReason("content/a/index.en.md"); got != c.want {
+			t.Errorf("%v, %d, %s: %q, want %q", c.expired, c.future, c.fm, got, c.want)
+		}
+	}
+}
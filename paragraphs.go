@@ -0,0 +1,107 @@
+package main
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// A paragraph wrapped over several lines is one segment, not one a line,
+// so the provider sees whole sentences. It comes back on one line, or
+// wrapped at paragraphs.wrap. A line on its own goes exactly as it
+// always has, so what's cached for it still is.
+
+// lines of a paragraph waiting for the end of it. Anything else written
+// to the page ends the paragraph first.
+type paragraph struct {
+	w     io.StringWriter
+	lines []string
+	first int // the line it started on
+	tr    func(text string, lineNo int) string
+}
+
+func (p *paragraph) WriteString(s string) (int, error) {
+	p.flush()
+	return p.w.WriteString(s)
+}
+
+var thematicBreak = regexp.MustCompile(`^ {0,3}((\*[ \t]*){3,}|(-[ \t]*){3,}|(_[ \t]*){3,})$`)
+
+// can ln be part of a paragraph? Not if it's anything with markup at the
+// start that Markdown cares about
+func joinable(ln string) bool {
+	if strings.TrimSpace(ln) == "" || strings.HasPrefix(ln, " ") || strings.HasPrefix(ln, "\t") {
+		return false
+	}
+	if strings.ContainsAny(ln[:1], "#>|<!{=") {
+		return false
+	}
+	return listItem.FindString(ln) == "" && !linkDef.MatchString(ln) && !setextRe.MatchString(ln) && !thematicBreak.MatchString(ln)
+}
+
+// ends with a line break that's meant: two spaces, or a backslash
+func hardBreak(ln string) string {
+	trimmed := strings.TrimRight(ln, " \t")
+	switch {
+	case strings.HasSuffix(trimmed, "\\"):
+		return "\\"
+	case strings.HasSuffix(ln, "  "):
+		return ln[len(trimmed):]
+	}
+	return ""
+}
+
+func (p *paragraph) add(ln string, lineNo int) {
+	if len(p.lines) == 0 {
+		p.first = lineNo
+	}
+	p.lines = append(p.lines, ln)
+	if hardBreak(ln) != "" {
+		p.flush()
+	}
+}
+
+// translate what's waiting and write it out
+func (p *paragraph) flush() {
+	lines := p.lines
+	p.lines = nil
+	switch len(lines) {
+	case 0:
+		return
+	case 1:
+		p.w.WriteString(p.tr(lines[0], p.first) + "\n")
+		return
+	}
+	brk := hardBreak(lines[len(lines)-1])
+	var parts []string
+	for _, ln := range lines {
+		parts = append(parts, strings.TrimSpace(ln))
+	}
+	text := strings.TrimSuffix(strings.Join(parts, " "), brk)
+	p.w.WriteString(wrapText(p.tr(text, p.first), conf.Paragraphs.Wrap) + brk + "\n")
+}
+
+// text on lines of at most width characters, broken between words. A
+// word longer than that, or text without spaces, goes on a line of its
+// own. 0 is one line.
+func wrapText(text string, width int) string {
+	if width <= 0 {
+		return text
+	}
+	var out strings.Builder
+	n := 0
+	for _, w := range strings.Fields(text) {
+		l := utf8.RuneCountInString(w)
+		if n > 0 && n+1+l > width && joinable(w) { // a line can't start with a # or a 1.
+			out.WriteString("\n")
+			n = 0
+		} else if n > 0 {
+			out.WriteString(" ")
+			n++
+		}
+		out.WriteString(w)
+		n += l
+	}
+	return out.String()
+}
@@ -0,0 +1,80 @@
+package main
+
+import "testing"
+
+func TestJoinable(t *testing.T) {
+	for ln, want := range map[string]bool{
+		"Plain text.":       true,
+		"*Emphasis* first.": true,
+		"":                  false,
+		"  indented":        false,
+		"# Heading":         false,
+		"> quote":           false,
+		"| table |":         false,
+		"<div>":             false,
+		"{{< shortcode >}}": false,
+		"- item":            false,
+		"1. item":           false,
+		"[id]: /docs/":      false,
+		"===":               false,
+		"***":               false,
+		"!image":            false,
+	} {
+		if got := joinable(ln); got != want {
+			t.Errorf("joinable(%q) = %v, want %v", ln, got, want)
+		}
+	}
+}
+
+func TestHardBreak(t *testing.T) {
+	for ln, want := range map[string]string{
+		"two spaces  ": "  ",
+		"backslash\\":  "\\",
+		"one space ":   "",
+		"none":         "",
+	} {
+		if got := hardBreak(ln); got != want {
+			t.Errorf("hardBreak(%q) = %q, want %q", ln, got, want)
+		}
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	for _, c := range []struct {
+		text  string
+		width int
+		want  string
+	}{
+		{"one two three four", 0, "one two three four"},
+		{"one two three four", 9, "one two\nthree\nfour"},
+		{"a-very-long-word here", 5, "a-very-long-word\nhere"},
+		{"Chapter ends on # 1", 15, "Chapter ends on #\n1"}, // a line can't start with #
+	} {
+		if got := wrapText(c.text, c.width); got != c.want {
+			t.Errorf("wrapText(%q, %d) = %q, want %q", c.text, c.width, got, c.want)
+		}
+	}
+}
+
+func TestParagraphsInPages(t *testing.T) {
+	for _, c := range []struct {
+		name      string
+		join      bool
+		wrap      int
+		src, want string
+	}{
+		{"joined", true, 0, "One line\nand the next.\n\nAlone.\n", "<One line and the next.>\n\n<Alone.>\n"},
+		{"a hard break", true, 0, "First  \nSecond\nThird\n", "<First  >\n<Second Third>\n"},
+		{"a backslash break", true, 0, "First\\\nSecond\n", "<First\\>\n<Second>\n"},
+		{"broken after two lines", true, 0, "First\nline  \nSecond\n", "<First line>  \n<Second>\n"},
+		{"wrapped", true, 12, "One line\nand the next.\n", "<One line\nand the\nnext.>\n"},
+		{"up to a heading", true, 0, "Text\n# Heading\n", "<Text>\n<# Heading>\n"},
+		{"not joined", false, 0, "One line\nand the next.\n", "<One line>\n<and the next.>\n"},
+	} {
+		conf = defaultConfig()
+		conf.Paragraphs.Join, conf.Paragraphs.Wrap = c.join, c.wrap
+		if got := xlatePage(t, c.src); got != c.want {
+			t.Errorf("%s:\n%q\nwant\n%q", c.name, got, c.want)
+		}
+	}
+}
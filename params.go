@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// site params, like the footer text or an announcement bar, live in the
+// Hugo config and not in any page. The ones in params.translate get a
+// params.fr.toml for each language in config/_default, which Hugo reads
+// as languages.fr.params on top of the site's own. Like everything else
+// here it's a rough read of the config, not a TOML or YAML parser: a
+// param has to be a string on one line.
+
+var (
+	tomlTable  = regexp.MustCompile(`^\[\s*([\w.-]+)\s*\]`)
+	tomlString = regexp.MustCompile(`^([\w.-]+)\s*=\s*("(?:[^"\\]|\\.)*"|'[^']*')\s*(?:#.*)?$`)
+	yamlString = regexp.MustCompile(`^(\s*)([\w-]+):[ \t]*(.*?)\s*$`)
+)
+
+// a string from a config file, and the line it's on
+type configString struct {
+	value string
+	line  int
+}
+
+// where the params are: config/_default/params.* if there's one, where
+// they're at the top, otherwise the Hugo config, under params
+func paramsSource() (string, string) {
+	for _, ext := range []string{".toml", ".yaml", ".yml", ".json"} {
+		p := filepath.Join("config", "_default", "params"+ext)
+		if exists(p) {
+			return p, ""
+		}
+	}
+	_, _, path := hugoLanguages()
+	return path, "params."
+}
+
+// where a language's params go: params.out with {lang} filled in, or
+// params.fr.toml in config/_default, in the same format as the source
+func paramsTarget(source string, lang string) string {
+	if conf.Params.Out != "" {
+		return filepath.Clean(strings.Replace(conf.Params.Out, "{lang}", fileCode(lang), -1))
+	}
+	return filepath.Join("config", "_default", "params."+fileCode(lang)+filepath.Ext(source))
+}
+
+// every string in a config file, by its dotted path
+func configStrings(path string, f []byte) map[string]configString {
+	found := map[string]configString{}
+	switch filepath.Ext(path) {
+	case ".json":
+		var all map[string]interface{}
+		if json.Unmarshal(f, &all) == nil {
+			flattenStrings("", all, found)
+		}
+		return found
+	case ".toml":
+		table := ""
+		for i, ln := range strings.Split(string(f), "\n") {
+			t := strings.TrimSpace(ln)
+			if strings.HasPrefix(t, "[[") { // an array of tables, not for us
+				table = "\x00"
+			} else if m := tomlTable.FindStringSubmatch(t); m != nil {
+				table = m[1] + "."
+			} else if m := tomlString.FindStringSubmatch(t); m != nil && table != "\x00" {
+				found[table+m[1]] = configString{tomlText(m[2]), i + 1}
+			}
+		}
+		return found
+	}
+	type level struct {
+		indent int
+		key    string
+	}
+	var stack []level
+	block := -1 // the indent of a block scalar we're in, its lines aren't keys
+	for i, ln := range strings.Split(string(f), "\n") {
+		t := strings.TrimSpace(ln)
+		indent := len(ln) - len(strings.TrimLeft(ln, " \t"))
+		if t == "" || strings.HasPrefix(t, "#") || (block >= 0 && indent > block) {
+			continue
+		}
+		block = -1
+		m := yamlString.FindStringSubmatch(ln)
+		if m == nil {
+			continue
+		}
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		var path []string
+		for _, l := range stack {
+			path = append(path, l.key)
+		}
+		path = append(path, m[2])
+		value := m[3]
+		switch {
+		case value == "":
+			stack = append(stack, level{indent, m[2]})
+		case strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">"):
+			block = indent
+		default:
+			if !strings.HasPrefix(value, `"`) && !strings.HasPrefix(value, "'") {
+				if c := strings.Index(value, " #"); c >= 0 {
+					value = strings.TrimSpace(value[:c])
+				}
+			}
+			found[strings.Join(path, ".")] = configString{unquote(value), i + 1}
+		}
+	}
+	return found
+}
+
+func flattenStrings(prefix string, m map[string]interface{}, found map[string]configString) {
+	for k, v := range m {
+		switch v := v.(type) {
+		case string:
+			found[prefix+k] = configString{v, 0}
+		case map[string]interface{}:
+			flattenStrings(prefix+k+".", v, found)
+		}
+	}
+}
+
+// a TOML string's text
+func tomlText(s string) string {
+	if strings.HasPrefix(s, "'") {
+		return s[1 : len(s)-1]
+	}
+	if text, err := strconv.Unquote(s); err == nil {
+		return text
+	}
+	return s[1 : len(s)-1]
+}
+
+func translateParams(from string, lang string) {
+	if len(conf.Params.Translate) == 0 {
+		return
+	}
+	source, prefix := paramsSource()
+	if source == "" {
+		fmt.Println("warning: params.translate, but there's no Hugo config to find them in")
+		return
+	}
+	toFile := paramsTarget(source, lang)
+	if exists(toFile) && !retranslate(source, toFile) {
+		countSkipped(lang)
+		return
+	}
+	f, err := files.ReadFile(source)
+	checkError(err)
+	found := configStrings(source, f)
+	translated := map[string]string{}
+	fmt.Printf("Translating:\t %s (params)\nto: \t\t%s\n", source, toFile)
+	checkError(files.MkdirAll(filepath.Dir(toFile)))
+	state.mark(source, toFile, lang, "failed")
+	err = tryPage(func() {
+		for _, p := range conf.Params.Translate {
+			s, ok := found[prefix+p]
+			if !ok {
+				fmt.Printf("warning: %s doesn't have %s%s, or it isn't a string on one line\n", source, prefix, p)
+				continue
+			}
+			translated[p] = xlAt(from, lang, s.value, fmt.Sprintf("%s:%d", source, s.line), false)
+		}
+	})
+	if err != nil {
+		fmt.Printf("Skipping:\t %s (%s)\n", toFile, strings.TrimSpace(err.Error()))
+		countFailedPage(lang, toFile+", skipped")
+		return
+	}
+	checkError(writePending(toFile, []byte(paramsFile(toFile, source, translated))))
+	commitTranslation(toFile)
+	state.mark(source, toFile, lang, "done")
+	countCreated(lang)
+}
+
+// the translated params, in the format file's extension says
+func paramsFile(file string, source string, params map[string]string) string {
+	var paths []string
+	for p := range params {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	note := "translated from " + filepath.ToSlash(source) + " by translate, see params.translate"
+	var out strings.Builder
+	switch filepath.Ext(file) {
+	case ".json":
+		tree := map[string]interface{}{}
+		for _, p := range paths {
+			keys := strings.Split(p, ".")
+			m := tree
+			for _, k := range keys[:len(keys)-1] {
+				next, ok := m[k].(map[string]interface{})
+				if !ok {
+					next = map[string]interface{}{}
+					m[k] = next
+				}
+				m = next
+			}
+			m[keys[len(keys)-1]] = params[p]
+		}
+		b, err := json.MarshalIndent(tree, "", "  ")
+		checkError(err)
+		return string(b) + "\n"
+	case ".yaml", ".yml":
+		out.WriteString("# " + note + "\n")
+		var last []string
+		for _, p := range paths {
+			keys := strings.Split(p, ".")
+			same := 0 // the maps it shares with the one before
+			for same < len(keys)-1 && same < len(last)-1 && keys[same] == last[same] {
+				same++
+			}
+			for d := same; d < len(keys)-1; d++ {
+				out.WriteString(strings.Repeat("  ", d) + keys[d] + ":\n")
+			}
+			out.WriteString(strings.Repeat("  ", len(keys)-1) + keys[len(keys)-1] + ": " + strconv.Quote(params[p]) + "\n")
+			last = keys
+		}
+		return out.String()
+	}
+	out.WriteString("# " + note + "\n")
+	for _, p := range paths { // dotted keys, so there are no tables to get in the way
+		out.WriteString(p + " = " + strconv.Quote(params[p]) + "\n")
+	}
+	return out.String()
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"plugin"
+	"strings"
+	"sync"
+)
+
+// Go plugins (go build -buildmode=plugin) listed in the config. The ABI
+// is deliberately small and only uses plain Go types, a plugin exports
+// either or both of:
+//
+//	func TranslateSegment(from, to, text string) (string, error)
+//	func FixSegment(from, to, source, translated string) string
+//
+// and optionally var Name string (defaults to the file name). One with
+// TranslateSegment can be used as translation.provider "plugin:<name>",
+// every FixSegment runs on every translation.
+type goPlugin struct {
+	name      string
+	translate func(from, to, text string) (string, error)
+	fix       func(from, to, source, translated string) string
+}
+
+var (
+	plugins     []*goPlugin
+	pluginsOnce sync.Once
+)
+
+func loadPlugins() []*goPlugin {
+	pluginsOnce.Do(func() {
+		for _, path := range conf.Plugins {
+			p, err := plugin.Open(path)
+			checkError(err)
+			gp := &goPlugin{name: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))}
+			if sym, err := p.Lookup("Name"); err == nil {
+				if name, ok := sym.(*string); ok {
+					gp.name = *name
+				}
+			}
+			if sym, err := p.Lookup("TranslateSegment"); err == nil {
+				fn, ok := sym.(func(string, string, string) (string, error))
+				if !ok {
+					checkError(fmt.Errorf("%s: TranslateSegment has the wrong signature", path))
+				}
+				gp.translate = fn
+			}
+			if sym, err := p.Lookup("FixSegment"); err == nil {
+				fn, ok := sym.(func(string, string, string, string) string)
+				if !ok {
+					checkError(fmt.Errorf("%s: FixSegment has the wrong signature", path))
+				}
+				gp.fix = fn
+			}
+			if gp.translate == nil && gp.fix == nil {
+				checkError(fmt.Errorf("%s: exports neither TranslateSegment nor FixSegment", path))
+			}
+			plugins = append(plugins, gp)
+		}
+	})
+	return plugins
+}
+
+// a plugin standing in for the translation API
+type pluginProvider struct {
+	p *goPlugin
+}
+
+func newPluginProvider(name string) pluginProvider {
+	for _, p := range loadPlugins() {
+		if p.name == name && p.translate != nil {
+			return pluginProvider{p}
+		}
+	}
+	checkError(fmt.Errorf("no plugin called %q with a TranslateSegment", name))
+	return pluginProvider{}
+}
+
+func (pp pluginProvider) Name() string {
+	return "plugin:" + pp.p.name
+}
+
+func (pp pluginProvider) Translate(texts []string, from string, to string) ([]string, error) {
+	var out []string
+	for _, t := range texts {
+		translated, err := pp.p.translate(apiCode(from), apiCode(to), t)
+		if err != nil {
+			return nil, fmt.Errorf("plugin %s: %v", pp.p.name, err)
+		}
+		out = append(out, translated)
+	}
+	return out, nil
+}
+
+// every plugin's FixSegment, in config order
+func runFixers(from string, to string, source string, translated string) string {
+	for _, p := range loadPlugins() {
+		if p.fix != nil {
+			translated = p.fix(from, to, source, translated)
+		}
+	}
+	return translated
+}
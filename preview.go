@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"html"
+	"html/template"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var previewIndex = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Lang}} translations</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+td { padding: 0.2em 1em 0.2em 0; }
+</style>
+</head>
+<body>
+<h1>{{.Lang}} translations in {{.Dir}}</h1>
+<table>
+{{range .Pages}}<tr><td><a href="/page?src={{.Source}}">{{.Title}}</a></td><td>{{.Source}}</td><td>{{.Modified.Format "2006-01-02 15:04"}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+var previewPage = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+.pages { display: flex; gap: 2em; }
+.pages > div { flex: 1; min-width: 0; }
+pre { background: #f4f4f4; padding: 0.5em; overflow-x: auto; }
+img { max-width: 100%; }
+</style>
+</head>
+<body>
+<p><a href="/">all pages</a></p>
+<div class="pages">
+<div><h1>{{.SourceTitle}}</h1>{{.Source}}</div>
+<div><h1>{{.Title}}</h1>{{.Translation}}</div>
+</div>
+</body>
+</html>
+`))
+
+type previewEntry struct {
+	Source   string
+	Title    string
+	Modified time.Time
+}
+
+// translator preview --lang fr [--builtin] [--port 1313] [dir]
+func previewCmd(args []string) {
+	flags := flag.NewFlagSet("preview", flag.ExitOnError)
+	configFile := flags.String("config", defaultConfigFile, "config file")
+	flags.StringVar(&profile, "profile", profile, "config profile to use")
+	lang := flags.String("lang", "", "language to preview")
+	port := flags.Int("port", 1313, "port to serve on")
+	builtin := flags.Bool("builtin", false, "use the built in renderer even if hugo is installed")
+	flags.Parse(args)
+	conf = loadConfig(*configFile)
+	if *lang == "" {
+		fmt.Println("usage: translate preview --lang fr [--builtin] [--port 1313] [dir]")
+		os.Exit(1)
+	}
+	dir := "content"
+	if flags.NArg() > 0 {
+		dir = flags.Arg(0)
+	}
+	if hugo, err := exec.LookPath("hugo"); err == nil && !*builtin {
+		// the real thing, drafts and all since that's what's being reviewed
+		fmt.Printf("Running hugo server, the %s pages are under http://localhost:%d/%s/\n", *lang, *port, *lang)
+		cmd := exec.Command(hugo, "server", "--buildDrafts", "--port", fmt.Sprint(*port))
+		cmd.Stdout, cmd.Stderr, cmd.Stdin = os.Stdout, os.Stderr, os.Stdin
+		checkError(cmd.Run())
+		return
+	}
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		var pages []previewEntry
+		for _, p := range sourcePages(conf.Source, dir) {
+			target := targetFor(p, conf.Source, *lang)
+			fi, err := os.Stat(target)
+			if err != nil {
+				continue
+			}
+			src, err := os.ReadFile(target)
+			checkError(err)
+			title, _ := fmValue(frontMatterLines(src), "title")
+			pages = append(pages, previewEntry{p, unquote(title), fi.ModTime()})
+		}
+		// what was just translated first
+		sort.Slice(pages, func(i, j int) bool { return pages[i].Modified.After(pages[j].Modified) })
+		previewIndex.Execute(w, struct {
+			Lang  string
+			Dir   string
+			Pages []previewEntry
+		}{*lang, dir, pages})
+	})
+	http.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		source := r.URL.Query().Get("src")
+		if !isValueInList(source, sourcePages(conf.Source, dir)) { // nothing but the pages we know about
+			http.NotFound(w, r)
+			return
+		}
+		src, err := os.ReadFile(source)
+		checkError(err)
+		tr, err := os.ReadFile(targetFor(source, conf.Source, *lang))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		// images in the bundle, relative to the page
+		rel, err := filepath.Rel(dir, filepath.Dir(source))
+		checkError(err)
+		base := "/content/" + filepath.ToSlash(rel) + "/"
+		srcTitle, srcBody := renderMarkdown(src, base)
+		title, body := renderMarkdown(tr, base)
+		previewPage.Execute(w, struct {
+			SourceTitle string
+			Source      template.HTML
+			Title       string
+			Translation template.HTML
+		}{srcTitle, srcBody, title, body})
+	})
+	http.Handle("/content/", http.StripPrefix("/content/", http.FileServer(http.Dir(dir))))
+	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir(filepath.Join(filepath.Dir(filepath.Clean(dir)), "static")))))
+	fmt.Printf("Previewing the %s translations in %s at http://localhost:%d/\n", *lang, dir, *port)
+	checkError(http.ListenAndServe(fmt.Sprintf("localhost:%d", *port), nil))
+}
+
+var (
+	mdHeading = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	mdOrdered = regexp.MustCompile(`^\s*\d+[.)]\s+`)
+	mdImage   = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)[^)]*\)`)
+	mdLink    = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)[^)]*\)`)
+	mdCode    = regexp.MustCompile("`([^`]+)`")
+	mdBold    = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	mdItalic  = regexp.MustCompile(`\*([^*]+)\*`)
+)
+
+// just enough Markdown to read a translation: headings, paragraphs,
+// lists, code blocks, links, images and emphasis. Raw HTML and
+// shortcodes show up as text, which for checking a translation is no
+// bad thing.
+func renderMarkdown(src []byte, base string) (string, template.HTML) {
+	src = bytes.TrimPrefix(src, bom)
+	title, _ := fmValue(frontMatterLines(src), "title")
+	if end := frontMatterEnd(src); end >= 0 {
+		if nl := bytes.IndexByte(src[end:], '\n'); nl >= 0 {
+			src = src[end+nl+1:]
+		}
+	}
+	var out strings.Builder
+	var para []string
+	list := "" // "ul" or "ol" while in one
+	code := false
+	flush := func() {
+		if len(para) > 0 {
+			out.WriteString("<p>" + inlineMarkdown(strings.Join(para, " "), base) + "</p>\n")
+			para = nil
+		}
+		if list != "" {
+			out.WriteString("</" + list + ">\n")
+			list = ""
+		}
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(src))
+	for scanner.Scan() {
+		ln := scanner.Text()
+		if strings.HasPrefix(strings.TrimSpace(ln), "```") || strings.HasPrefix(strings.TrimSpace(ln), "~~~") {
+			if code {
+				out.WriteString("</code></pre>\n")
+			} else {
+				flush()
+				out.WriteString("<pre><code>")
+			}
+			code = !code
+			continue
+		}
+		if code {
+			out.WriteString(html.EscapeString(ln) + "\n")
+			continue
+		}
+		if strings.TrimSpace(ln) == "" {
+			flush()
+			continue
+		}
+		if m := mdHeading.FindStringSubmatch(ln); m != nil {
+			flush()
+			n := len(m[1])
+			fmt.Fprintf(&out, "<h%d>%s</h%d>\n", n, inlineMarkdown(m[2], base), n)
+			continue
+		}
+		kind := ""
+		if listItem.MatchString(ln) {
+			kind = "ul"
+			if mdOrdered.MatchString(ln) {
+				kind = "ol"
+			}
+		}
+		if kind != "" {
+			if len(para) > 0 || list != kind {
+				flush()
+				out.WriteString("<" + kind + ">\n")
+				list = kind
+			}
+			item := mdOrdered.ReplaceAllString(ln, "")
+			item = strings.TrimLeft(strings.TrimSpace(item), "-*+ ")
+			out.WriteString("<li>" + inlineMarkdown(item, base) + "</li>\n")
+			continue
+		}
+		if list != "" { // a list item running on
+			out.WriteString("<li>" + inlineMarkdown(strings.TrimSpace(ln), base) + "</li>\n")
+			continue
+		}
+		para = append(para, strings.TrimSpace(ln))
+	}
+	flush()
+	if code {
+		out.WriteString("</code></pre>\n")
+	}
+	return unquote(title), template.HTML(out.String())
+}
+
+// base is where relative image paths are served from, absolute ones come
+// from the site's static directory
+func inlineMarkdown(text string, base string) string {
+	text = html.EscapeString(text)
+	text = mdImage.ReplaceAllStringFunc(text, func(img string) string {
+		m := mdImage.FindStringSubmatch(img)
+		src := m[2]
+		if strings.HasPrefix(src, "/") {
+			src = "/static" + src
+		} else if !strings.Contains(src, "://") {
+			src = base + src
+		}
+		return `<img src="` + src + `" alt="` + m[1] + `">`
+	})
+	text = mdLink.ReplaceAllString(text, `<a href="$2">$1</a>`)
+	text = mdCode.ReplaceAllString(text, "<code>$1</code>")
+	text = mdBold.ReplaceAllString(text, "<strong>$1</strong>")
+	return mdItalic.ReplaceAllString(text, "<em>$1</em>")
+}
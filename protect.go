@@ -0,0 +1,45 @@
+package main
+
+import (
+	"regexp"
+)
+
+// things in prose that are really code: ./configs/app.yaml, /etc/hosts,
+// --flag-name, -v, GOPATH, $HOME and CONSTANT_CASE. The API translates
+// them or puts spaces around the hyphens.
+var defaultProtect = []string{
+	`(?:\.{1,2}|~)/[\w.-]+(?:/[\w.-]+)*/?`,
+	`\B/[\w.-]+(?:/[\w.-]+)+/?`,
+	`\b[\w.-]+(?:/[\w.-]+)*\.(?:ya?ml|json|toml|go|mod|sum|md|js|ts|py|rb|rs|sh|txt|conf|cfg|ini|env|lock|xml|html|css)\b`,
+	`\B--[A-Za-z][\w-]*|\B-[A-Za-z]\b`,
+	`\b[A-Z][A-Z0-9]*_[A-Z0-9_]*[A-Z0-9]\b|\b[A-Z]+(?:PATH|HOME|ROOT|DIR)\b|\$\{?[A-Za-z_]\w*\}?`,
+}
+
+var protectPatterns []*regexp.Regexp
+
+func loadProtectPatterns() []*regexp.Regexp {
+	if protectPatterns == nil {
+		protectPatterns = []*regexp.Regexp{}
+		for _, p := range conf.Protect.Patterns {
+			protectPatterns = append(protectPatterns, regexp.MustCompile(p))
+		}
+	}
+	return protectPatterns
+}
+
+// hide anything that looks like a path, a flag or an identifier
+func (m *masker) maskCode(text string) string {
+	for _, re := range loadProtectPatterns() {
+		text = m.mask(text, re, nil)
+	}
+	return text
+}
+
+// v1.2.3, 1.2.3-beta.1, 1.21rc2, go1.22. Always protected, the API likes
+// to turn the dots into commas. Plain 3.5 is left alone since that one
+// should be localized.
+var versionRe = regexp.MustCompile(`\bv\d+(?:\.\d+)*(?:-[\w.]+)?\b|\b\d+\.\d+\.\d+(?:-[\w.]+)?(?:\+[\w.]+)?\b|\b\d+\.\d+(?:rc|beta|alpha)\d*\b|\b[a-z]+\d+(?:\.\d+)+(?:rc\d+|beta\d*)?\b`)
+
+func (m *masker) maskVersions(text string) string {
+	return m.mask(text, versionRe, nil)
+}
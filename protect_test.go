@@ -0,0 +1,51 @@
+package main
+
+import "testing"
+
+func TestMaskCode(t *testing.T) {
+	conf = defaultConfig()
+	protectPatterns = nil
+	defer func() { protectPatterns = nil }()
+	for text, want := range map[string]string{
+		"Edit ./configs/app.yaml first.":      "Edit ⟦T0000⟧ first.",
+		"It's in /etc/hosts on Linux.":        "It's in ⟦T0000⟧ on Linux.",
+		"Copy config.toml to the site.":       "Copy ⟦T0000⟧ to the site.",
+		"Run it with --dry-run or -v.":        "Run it with ⟦T0000⟧ or ⟦T0001⟧.",
+		"Set GOPATH and MAX_WORKERS.":         "Set ⟦T0000⟧ and ⟦T0001⟧.",
+		"It uses $HOME and ${XDG_CONFIG}.":    "It uses ⟦T0000⟧ and ⟦T0001⟧.",
+		"Read and/or write, it's well-known.": "Read and/or write, it's well-known.",
+		"A cat sat on the mat.":               "A cat sat on the mat.",
+	} {
+		var m masker
+		if got := m.maskCode(text); got != want {
+			t.Errorf("maskCode(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestProtectPatternsOff(t *testing.T) {
+	conf = defaultConfig()
+	conf.Protect.Patterns = []string{}
+	protectPatterns = nil
+	defer func() { protectPatterns = nil }()
+	var m masker
+	if got := m.maskCode("Edit ./configs/app.yaml first."); got != "Edit ./configs/app.yaml first." {
+		t.Errorf("masked with the patterns off: %q", got)
+	}
+}
+
+func TestMaskVersions(t *testing.T) {
+	for text, want := range map[string]string{
+		"Upgrade to v1.2.3 now.":        "Upgrade to ⟦T0000⟧ now.",
+		"Since 1.2.3-beta.1 it works.":  "Since ⟦T0000⟧ it works.",
+		"Try 1.21rc2 or go1.22.":        "Try ⟦T0000⟧ or ⟦T0001⟧.",
+		"It's 3.5 times faster.":        "It's 3.5 times faster.",
+		"Version 2 is out.":             "Version 2 is out.",
+		"Built as 1.0.0+build.5 today.": "Built as ⟦T0000⟧ today.",
+	} {
+		var m masker
+		if got := m.maskVersions(text); got != want {
+			t.Errorf("maskVersions(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
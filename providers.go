@@ -0,0 +1,192 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// something that can translate text for us
+type Provider interface {
+	Name() string
+	Translate(texts []string, from string, to string) ([]string, error)
+}
+
+// the plain old Google Translate API
+type googleProvider struct{}
+
+func (googleProvider) Name() string {
+	return "google"
+}
+
+func (googleProvider) Translate(texts []string, from string, to string) ([]string, error) {
+	if !useV2() {
+		return translateV3(texts, from, to)
+	}
+	return translateTextWithModel(apiCode(to), texts, conf.Translation.Model)
+}
+
+// translation.provider "noop" (or --provider noop) hands everything back
+// as it was. The pages are found, written and counted like on a real
+// run, so you can see what a run would do before it costs anything.
+type noopProvider struct{}
+
+func (noopProvider) Name() string {
+	return "noop"
+}
+
+func (noopProvider) Translate(texts []string, from string, to string) ([]string, error) {
+	return texts, nil
+}
+
+// providers that don't call anything, and runs with them shouldn't
+// either: no LLM second pass, no falling back to a paid provider
+func offline(name string) bool {
+	return name == "noop" || name == "pseudo"
+}
+
+var current Provider
+
+// whatever translation.provider says, Google if it doesn't say
+func provider() Provider {
+	if current == nil {
+		current = newProvider(conf.Translation.Provider)
+	}
+	return current
+}
+
+func newProvider(name string) Provider {
+	switch name {
+	case "", "google":
+		return googleProvider{}
+	case "llm":
+		return newLLMProvider()
+	case "azure":
+		return newAzureProvider()
+	case "pseudo":
+		return pseudoProvider{}
+	case "noop":
+		return noopProvider{}
+	}
+	if plugin := strings.TrimPrefix(name, "plugin:"); plugin != name {
+		return newPluginProvider(plugin)
+	}
+	checkError(fmt.Errorf("unknown translation provider %q", name))
+	return nil
+}
+
+// some pairs come out better going through a third language, nl to pt
+// through English say. translation.pivots has "nl/pt" (or "*/pt") -> "en".
+func pivotFor(from string, to string) string {
+	for _, k := range []string{from + "/" + to, "*/" + to} {
+		if pivot, ok := conf.Translation.Pivots[k]; ok && pivot != from && pivot != to {
+			return pivot
+		}
+	}
+	return ""
+}
+
+// translate a single piece of text with the configured provider, through
+// a pivot language if there is one. Both legs are cached, the fixes in xl
+// only happen once at the end.
+func translateText(from string, to string, text string, where string) (string, error) {
+	if pivot := pivotFor(from, to); pivot != "" {
+		mid, err := translateDirect(from, pivot, text, to, where)
+		if err != nil {
+			return "", err
+		}
+		return translateDirect(pivot, to, mid, to, where)
+	}
+	return translateDirect(from, to, text, to, where)
+}
+
+// one call to the provider, unless we've translated it before, and then
+// to each of translation.fallbacks in turn if it can't. lang is who to
+// count it against, the pivot leg is paid for by the language it's for.
+func translateDirect(from string, to string, text string, lang string, where string) (string, error) {
+	chain := providerChain()
+	if len(chain) == 1 {
+		return translateWith(chain[0], from, to, text, lang, where)
+	}
+	var why []string
+	for i, p := range chain {
+		if !supportsLanguage(p, to) {
+			why = append(why, fmt.Sprintf("%s doesn't do %s", p.Name(), to))
+			continue
+		}
+		out, err := translateWith(p, from, to, text, lang, where)
+		if err == errNotCached { // the next one's cache won't have it either
+			return "", err
+		}
+		if err != nil {
+			fmt.Printf("warning: %s failed, trying the next provider: %v\n", p.Name(), err)
+			why = append(why, p.Name()+" failed")
+			continue
+		}
+		if i > 0 {
+			countFallback(lang, p.Name()+", because "+strings.Join(why, " and "))
+		}
+		return out, nil
+	}
+	return "", fmt.Errorf("no provider could translate into %s: %s", to, strings.Join(why, ", "))
+}
+
+var (
+	fallbacks     []Provider
+	fallbacksOnce sync.Once
+)
+
+// the provider and then its fallbacks, in the order they're tried
+func providerChain() []Provider {
+	fallbacksOnce.Do(func() {
+		for _, name := range conf.Translation.Fallbacks {
+			fallbacks = append(fallbacks, newProvider(name))
+		}
+	})
+	return append([]Provider{provider()}, fallbacks...)
+}
+
+// --cache-readonly: for PR builds, translate from the cache and nothing
+// else. What isn't in it stays as it is and is counted, so the run says
+// what it would have cost, and the cache isn't written.
+var cacheReadonly bool
+
+var errNotCached = errors.New("not in the cache")
+
+// one provider, from the cache if we can. where is the page and line
+// it's for, for the audit log.
+func translateWith(p Provider, from string, to string, text string, lang string, where string) (string, error) {
+	key := providerKey(p, to)
+	if err := wasRejected(cacheKey(key, from, to, text)); err != nil {
+		return "", err // no point paying to hear it again
+	}
+	c := loadCache()
+	if hit, ok := c.getMasked(key, from, to, text); ok {
+		if takePrefetched(cacheKey(key, from, to, text)) {
+			countSent(lang, text)
+			countLabel(billingLabel(where), text)
+		} else {
+			countHit(lang, text)
+		}
+		return hit, nil
+	}
+	if cacheReadonly {
+		return "", errNotCached
+	}
+	countSent(lang, text)
+	countLabel(billingLabel(where), text)
+	out, err := callProvider(p, []string{text}, from, to)
+	if err != nil {
+		return "", err
+	}
+	if len(out) == 0 {
+		return "", nil
+	}
+	if _, ok := p.(*collector); ok { // not a translation
+		return out[0], nil
+	}
+	audit(p.Name(), from, to, lang, []string{text}, where)
+	c.put(key, from, to, text, out[0])
+	return out[0], nil
+}
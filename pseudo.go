@@ -0,0 +1,74 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// translation.provider "pseudo" translates without an API and costs
+// nothing. Every letter gets an accent and every segment gets a third
+// longer and goes in brackets, [Ĥéļļö ŵöŕļð~~~], so a run shows up what
+// would break, like front matter that doesn't parse, titles too long for
+// the theme, and text that never got translated, before paying for the
+// real thing.
+type pseudoProvider struct{}
+
+func (pseudoProvider) Name() string {
+	return "pseudo"
+}
+
+func (pseudoProvider) Translate(texts []string, from string, to string) ([]string, error) {
+	var out []string
+	for _, t := range texts {
+		out = append(out, pseudoTranslate(t))
+	}
+	return out, nil
+}
+
+const (
+	pseudoFrom = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	pseudoTo   = "áƀçðéƒĝĥîĵķļɱñöþǫŕšţûṽŵẋýžÅƁÇÐÉƑĜĤÎĴĶĻṀÑÖÞǪŔŠŢÛṼŴẊÝŽ"
+)
+
+var (
+	// what a real translation wouldn't touch either: placeholders, tags,
+	// shortcodes, link targets, entities, code and URLs
+	pseudoKeep = regexp.MustCompile("⟦T\\d+⟧|__\\d+__|<[^>]*>|\\{\\{.*?\\}\\}|\\]\\([^)]*\\)|&#?\\w+;|`[^`]*`|https?:/\\S*")
+	// and the Markdown and quotes around the text, outside the brackets
+	pseudoLead  = regexp.MustCompile(`^\s*(([#>*+-]+|\d+[.)])\s+)*["']?`)
+	pseudoTrail = regexp.MustCompile(`["']?\s*$`)
+)
+
+func pseudoTranslate(text string) string {
+	lead := pseudoLead.FindString(text)
+	text = text[len(lead):]
+	trail := pseudoTrail.FindString(text)
+	text = text[:len(text)-len(trail)]
+	if text == "" {
+		return lead + trail
+	}
+	to := []rune(pseudoTo)
+	letters := 0
+	accent := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if i := strings.IndexRune(pseudoFrom, r); i >= 0 {
+				letters++
+				return to[i]
+			}
+			return r
+		}, s)
+	}
+	var out strings.Builder
+	last := 0
+	for _, loc := range pseudoKeep.FindAllStringIndex(text, -1) {
+		out.WriteString(accent(text[last:loc[0]]))
+		out.WriteString(text[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	out.WriteString(accent(text[last:]))
+	pad := letters * 3 / 10
+	if pad == 0 && letters > 0 {
+		pad = 1
+	}
+	return lead + "[" + out.String() + strings.Repeat("~", pad) + "]" + trail
+}
@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestPseudoTranslate(t *testing.T) {
+	for _, c := range []struct {
+		text, want string
+	}{
+		{"Hello world", "[Ĥéļļö ŵöŕļð~~~]"},
+		{"# Hello", "# [Ĥéļļö~]"},
+		{"- 1. Hello", "- 1. [Ĥéļļö~]"},
+		{`"Quoted" `, `"[Ǫûöţéð~]" `},
+		{"See ⟦T0000⟧ now", "[Šéé ⟦T0000⟧ ñöŵ~]"},
+		{"Run `go test` now", "[Ŕûñ `go test` ñöŵ~]"},
+		{"Go to [docs](/docs/).", "[Ĝö ţö [ðöçš](/docs/).~~]"},
+		{"See https://example.com ok", "[Šéé https://example.com öķ~]"},
+		{"a &amp; b", "[á &amp; ƀ~]"},
+		{"42", "[42]"},
+		{"  ", "  "},
+	} {
+		if got := pseudoTranslate(c.text); got != c.want {
+			t.Errorf("pseudoTranslate(%q) = %q, want %q", c.text, got, c.want)
+		}
+	}
+}
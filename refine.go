@@ -0,0 +1,58 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// is this page in one of the refine.sections? Those are matched as globs
+// or as path prefixes.
+func inRefinedSection(path string) bool {
+	path = filepath.ToSlash(filepath.Clean(path))
+	for _, s := range conf.Refine.Sections {
+		s = filepath.ToSlash(filepath.Clean(s))
+		if ok, _ := filepath.Match(s, path); ok || strings.HasPrefix(path, s+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	refiner     *llmProvider
+	refinerOnce sync.Once
+)
+
+// the LLM from translation.llm, whatever the main provider is
+func getRefiner() *llmProvider {
+	refinerOnce.Do(func() {
+		if len(conf.Refine.Sections) > 0 {
+			refiner = newLLMProvider()
+		}
+	})
+	return refiner
+}
+
+// have the LLM post-edit a draft translation, cached like anything else
+func refineText(from string, to string, source string, draft string, where string) (string, error) {
+	r := getRefiner()
+	key := "refine:" + providerKey(r, to)
+	text := source + "\x00" + draft
+	c := loadCache()
+	if hit, ok := c.get(key, from, to, text); ok {
+		return hit, nil
+	}
+	if cacheReadonly {
+		return draft, nil
+	}
+	out, err := retrying(r, []string{source, draft}, func() ([]string, error) {
+		return r.refine([]string{source}, []string{draft}, from, to)
+	})
+	if err != nil {
+		return "", err
+	}
+	audit("refine:"+r.Name(), from, to, to, []string{source, draft}, where)
+	c.put(key, from, to, text, out[0])
+	return out[0], nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// reference links, [text][id] with an [id]: https://... somewhere on the
+// page. The definitions are copied as they are, and the label never goes
+// to the provider: [text][id] is sent as [text and a placeholder, so only
+// the text is translated. [text][] and [text] take their label from the
+// text, so they come back as [translation][text] and still point at the
+// same definition.
+
+var (
+	refLabel    = regexp.MustCompile(`^ {0,3}\[([^\]^][^\]]*)\]:`) // not [^1]:, that's a footnote
+	refLink     = regexp.MustCompile(`(\[[^\[\]]*)\]\[([^\[\]]*)\]`)
+	shortcutRef = regexp.MustCompile(`\[([^\[\]]+)\]`)
+)
+
+// is ln a link definition, rather than a footnote?
+func isLinkDefinition(ln string) bool {
+	return refLabel.MatchString(ln) && linkDef.MatchString(ln)
+}
+
+// labels match without regard to case or spacing
+func normalLabel(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
+
+// the labels defined in a page
+func refLabels(src []byte) map[string]bool {
+	labels := map[string]bool{}
+	bodyLines(src, func(ln string, lineNo int) {
+		if isLinkDefinition(ln) {
+			labels[normalLabel(refLabel.FindStringSubmatch(ln)[1])] = true
+		}
+	})
+	return labels
+}
+
+// [text] as [text][text], where text is one of labels
+func fullReferences(text string, labels map[string]bool) string {
+	if len(labels) == 0 {
+		return text
+	}
+	code := inlineCode.FindAllStringIndex(text, -1)
+	var out strings.Builder
+	last := 0
+	for _, m := range shortcutRef.FindAllStringSubmatchIndex(text, -1) {
+		start, end := m[0], m[1]
+		if start > 0 && text[start-1] == ']' || !labels[normalLabel(text[m[2]:m[3]])] {
+			continue // the label of a full reference, or just brackets
+		}
+		if rest := text[end:]; strings.HasPrefix(rest, "(") || strings.HasPrefix(rest, ":") || strings.HasPrefix(rest, "[") {
+			continue // an inline link, a definition, or it has a label
+		}
+		inCode := false
+		for _, c := range code {
+			inCode = inCode || start >= c[0] && start < c[1]
+		}
+		if inCode {
+			continue
+		}
+		out.WriteString(text[last:end])
+		out.WriteString(text[start:end]) // the label, the same as the text
+		last = end
+	}
+	out.WriteString(text[last:])
+	return out.String()
+}
+
+// hide the labels of reference links, ][id] and all
+func (m *masker) maskReferences(text string) string {
+	return refLink.ReplaceAllStringFunc(text, func(s string) string {
+		sm := refLink.FindStringSubmatch(s)
+		label := sm[2]
+		if label == "" {
+			label = sm[1][1:]
+		}
+		m.saved = append(m.saved, "]["+label+"]")
+		return sm[1] + placeholder(len(m.saved)-1)
+	})
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestIsLinkDefinition(t *testing.T) {
+	for ln, want := range map[string]bool{
+		"[docs]: https://example.com/docs":            true,
+		`[Docs]: https://example.com/docs "The Docs"`: true,
+		"   [docs]: /docs/":                           true,
+		"[^1]: A footnote.":                           false,
+		"[docs] is where it is.":                      false,
+		"    [docs]: /docs/":                          false,
+	} {
+		if got := isLinkDefinition(ln); got != want {
+			t.Errorf("isLinkDefinition(%q) = %v, want %v", ln, got, want)
+		}
+	}
+}
+
+func TestRefLabels(t *testing.T) {
+	src := "---\ntitle: Hi\n---\n\nSee [the docs][docs].\n\n[Docs]: /docs/\n[Hugo  Site]: https://gohugo.io\n[^1]: A note.\n"
+	if got := fmt.Sprint(refLabels([]byte(src))); got != "map[docs:true hugo site:true]" {
+		t.Errorf("refLabels = %s", got)
+	}
+}
+
+func TestFullReferences(t *testing.T) {
+	labels := map[string]bool{"hugo": true, "docs": true}
+	for text, want := range map[string]string{
+		"Use [Hugo].":                "Use [Hugo][Hugo].",
+		"Use [Hugo][].":              "Use [Hugo][].",
+		"Read [the docs][docs].":     "Read [the docs][docs].",
+		"Use [Hugo](https://x.org).": "Use [Hugo](https://x.org).",
+		"Use `[Hugo]` literally.":    "Use `[Hugo]` literally.",
+		"A [note] in brackets.":      "A [note] in brackets.",
+	} {
+		if got := fullReferences(text, labels); got != want {
+			t.Errorf("fullReferences(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestMaskReferences(t *testing.T) {
+	for _, c := range []struct {
+		text, send, back string
+	}{
+		{"Read [the docs][docs] now.", "Read [the docs⟦T0000⟧ now.", "Read [the docs][docs] now."},
+		{"Use [Hugo][] for it.", "Use [Hugo⟦T0000⟧ for it.", "Use [Hugo][Hugo] for it."},
+		{"Use [Hugo](/hugo/).", "Use [Hugo](/hugo/).", "Use [Hugo](/hugo/)."},
+	} {
+		var m masker
+		send := m.maskReferences(c.text)
+		if send != c.send {
+			t.Errorf("maskReferences(%q) = %q, want %q", c.text, send, c.send)
+		}
+		if got := m.unmask(send); got != c.back {
+			t.Errorf("unmask(%q) = %q, want %q", send, got, c.back)
+		}
+	}
+}
+
+func TestReferencesInPages(t *testing.T) {
+	conf = defaultConfig()
+	src := "Use [Hugo] and [the docs][docs].\n\n[hugo]: https://gohugo.io\n[docs]: /docs/\n"
+	want := "<Use [Hugo][Hugo] and [the docs][docs].>\n\n[hugo]: https://gohugo.io\n[docs]: /docs/\n"
+	if got := xlatePage(t, src); got != want {
+		t.Errorf("got\n%q\nwant\n%q", got, want)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// a shared cache anywhere that takes GET and PUT on <url>/<key>: a small
+// cache server, a WebDAV share, or a bucket behind its HTTP API. The local
+// cache reads through to it so CI and laptops share what they've paid for.
+type remoteCache struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+var remote *remoteCache
+
+func getRemote() *remoteCache {
+	if remote != nil || conf.Cache.Remote.URL == "" {
+		return remote
+	}
+	remote = &remoteCache{
+		url:    strings.TrimRight(conf.Cache.Remote.URL, "/"),
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+	if env := conf.Cache.Remote.TokenEnv; env != "" {
+		remote.token = os.Getenv(env)
+	}
+	return remote
+}
+
+func (r *remoteCache) request(method string, key string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, r.url+"/"+key, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return r.client.Do(req)
+}
+
+// a miss or an unreachable server are both just a miss, the remote cache
+// is never worth failing a run over
+func (r *remoteCache) get(key string) (*cacheEntry, bool) {
+	resp, err := r.request("GET", key, nil)
+	if err != nil {
+		fmt.Printf("warning: remote cache: %v\n", err)
+		return nil, false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, false
+	}
+	var e cacheEntry
+	if err := json.NewDecoder(resp.Body).Decode(&e); err != nil {
+		return nil, false
+	}
+	return &e, true
+}
+
+func (r *remoteCache) put(key string, e *cacheEntry) {
+	body, err := json.Marshal(e)
+	checkError(err)
+	resp, err := r.request("PUT", key, body)
+	if err != nil {
+		fmt.Printf("warning: remote cache: %v\n", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		fmt.Printf("warning: remote cache: PUT %s: %s\n", key, resp.Status)
+	}
+}
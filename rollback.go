@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// every run gets one, it's what you hand to rollback. The process id
+// keeps two runs started in the same second apart.
+var runID = fmt.Sprintf("%s-%d", time.Now().Format("20060102-150405"), os.Getpid())
+
+var backupDir = filepath.Join(stateDir, "backups")
+
+// what a run did to one translation: Backup is where the old one went,
+// or "" if there wasn't one before
+type backupEntry struct {
+	Target string `json:"target"`
+	Backup string `json:"backup"`
+}
+
+var (
+	backups   []backupEntry
+	backupsMu sync.Mutex
+)
+
+func backupManifest(id string) string {
+	return filepath.Join(backupDir, id, "manifest.json")
+}
+
+// where run id keeps its copy of target: under the same path in its
+// backups as target has in the site. One outside the site (../x, or
+// /x) has no such path, and could be written anywhere, so it's an error.
+func backupPath(id string, target string) (string, error) {
+	root, err := filepath.Abs(".")
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(target)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the site, so there's nowhere to back it up for rollback", target)
+	}
+	return filepath.Join(backupDir, id, rel), nil
+}
+
+// keep a copy of a translation we're about to overwrite, and remember
+// the ones we create, so the run can be undone
+func backupTarget(target string) {
+	entry := backupEntry{Target: target}
+	if old, err := files.ReadFile(target); err == nil {
+		entry.Backup, err = backupPath(runID, target)
+		checkError(err)
+		checkError(os.MkdirAll(filepath.Dir(entry.Backup), 0755))
+		checkError(os.WriteFile(entry.Backup, old, 0644))
+	}
+	backupsMu.Lock()
+	defer backupsMu.Unlock()
+	backups = append(backups, entry)
+	out, err := json.MarshalIndent(backups, "", "  ")
+	checkError(err)
+	checkError(os.MkdirAll(filepath.Join(backupDir, runID), 0755))
+	checkError(os.WriteFile(backupManifest(runID), out, 0644))
+}
+
+// if the run changed anything, how to take it back
+func printRunID() {
+	if len(backups) > 0 {
+		fmt.Printf("Run %s, to undo it: translate rollback %s\n", runID, runID)
+	}
+}
+
+// translate rollback <run-id>
+func rollbackCmd(args []string) {
+	if len(args) != 1 {
+		fmt.Println("usage: translate rollback <run-id>")
+		os.Exit(1)
+	}
+	f, err := os.ReadFile(backupManifest(args[0]))
+	if os.IsNotExist(err) {
+		checkError(fmt.Errorf("no backups for run %s", args[0]))
+	}
+	checkError(err)
+	var entries []backupEntry
+	checkError(json.Unmarshal(f, &entries))
+	for _, e := range entries {
+		if e.Backup == "" {
+			if err := os.Remove(e.Target); err != nil && !os.IsNotExist(err) {
+				checkError(err)
+			}
+			state.mu.Lock()
+			delete(state.Pages, e.Target)
+			state.mu.Unlock()
+			fmt.Printf("Removed:\t %s\n", e.Target)
+			continue
+		}
+		old, err := os.ReadFile(e.Backup)
+		checkError(err)
+		checkError(os.WriteFile(e.Target, old, 0644))
+		fmt.Printf("Restored:\t %s\n", e.Target)
+	}
+	state.save()
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestBackupPath(t *testing.T) {
+	abs, err := filepath.Abs("content/a/index.fr.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for target, want := range map[string]string{
+		"content/a/index.fr.md":          filepath.Join(backupDir, "run", "content/a/index.fr.md"),
+		"./content/../content/x.fr.md":   filepath.Join(backupDir, "run", "content/x.fr.md"),
+		abs:                              filepath.Join(backupDir, "run", "content/a/index.fr.md"),
+		"../elsewhere/index.fr.md":       "",
+		"content/../../../etc/passwd.md": "",
+		"/tmp/out-of-tree/index.fr.md":   "",
+	} {
+		got, err := backupPath("run", target)
+		if want == "" {
+			if err == nil {
+				t.Errorf("backupPath(%s) = %s, want an error", target, got)
+			}
+			continue
+		}
+		if err != nil || got != want {
+			t.Errorf("backupPath(%s) = %s, %v, want %s", target, got, err, want)
+		}
+	}
+}
+
+func TestRunIDHasTheProcess(t *testing.T) {
+	if !strings.HasSuffix(runID, "-"+strconv.Itoa(os.Getpid())) {
+		t.Errorf("runID %s doesn't end with the process id", runID)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+)
+
+// what a run did for one language
+type langStats struct {
+	Lang      string         `json:"lang"`
+	Created   int            `json:"created"`
+	Skipped   int            `json:"skipped"`
+	Chars     int            `json:"chars_sent"`
+	Sent      int            `json:"segments_sent"`
+	CacheHits int            `json:"cache_hits"`
+	Saved     int            `json:"chars_saved"`                // not sent because the cache had them
+	Fuzzy     int            `json:"fuzzy_matches"`              // close enough to something cached, see fuzzy.go
+	Missed    int            `json:"not_cached,omitempty"`       // --cache-readonly: what would have been sent
+	MissedCh  int            `json:"not_cached_chars,omitempty"` // and how long it was
+	Cost      float64        `json:"estimated_cost"`
+	Elapsed   time.Duration  `json:"-"`
+	Seconds   float64        `json:"elapsed_seconds"`
+	Fallbacks map[string]int `json:"fallbacks,omitempty"`    // segments by the provider that did them, and why
+	Failed    []string       `json:"failed_pages,omitempty"` // and what they got instead, see failures.go
+	Broken    []string       `json:"broken_links,omitempty"` // with links.check, see links.go
+}
+
+// and for one billing label, see billing.go
+type labelStats struct {
+	Label string  `json:"label"`
+	Chars int     `json:"chars_sent"`
+	Cost  float64 `json:"estimated_cost"`
+}
+
+var (
+	runStats   = map[string]*langStats{}
+	byLabel    = map[string]*labelStats{}
+	runStatsMu sync.Mutex
+)
+
+func record(lang string, f func(s *langStats)) {
+	runStatsMu.Lock()
+	defer runStatsMu.Unlock()
+	s, ok := runStats[lang]
+	if !ok {
+		s = &langStats{Lang: lang}
+		runStats[lang] = s
+	}
+	f(s)
+}
+
+func countCreated(lang string) { record(lang, func(s *langStats) { s.Created++ }) }
+func countSkipped(lang string) { record(lang, func(s *langStats) { s.Skipped++ }) }
+
+func countHit(lang string, text string) {
+	record(lang, func(s *langStats) {
+		s.CacheHits++
+		s.Saved += utf8.RuneCountInString(text)
+	})
+}
+
+func countFuzzy(lang string, text string) {
+	record(lang, func(s *langStats) {
+		s.Fuzzy++
+		s.Saved += utf8.RuneCountInString(text)
+	})
+}
+
+func countMissed(lang string, text string) {
+	record(lang, func(s *langStats) {
+		s.Missed++
+		s.MissedCh += utf8.RuneCountInString(text)
+	})
+}
+
+func countSent(lang string, text string) {
+	record(lang, func(s *langStats) {
+		s.Sent++
+		s.Chars += utf8.RuneCountInString(text)
+	})
+}
+
+// what was sent for a page with a billing label, "" for the rest
+func countLabel(label string, text string) {
+	if len(conf.Billing.Labels) == 0 {
+		return
+	}
+	runStatsMu.Lock()
+	defer runStatsMu.Unlock()
+	s, ok := byLabel[label]
+	if !ok {
+		s = &labelStats{Label: label}
+		byLabel[label] = s
+	}
+	s.Chars += utf8.RuneCountInString(text)
+}
+
+// every label's spend, the pages without one last
+func summarizeLabels() []*labelStats {
+	runStatsMu.Lock()
+	defer runStatsMu.Unlock()
+	var all []*labelStats
+	for _, s := range byLabel {
+		s.Cost = float64(s.Chars) * pricePerMillion / 1000000
+		all = append(all, s)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if (all[i].Label == "") != (all[j].Label == "") {
+			return all[j].Label == ""
+		}
+		return all[i].Label < all[j].Label
+	})
+	return all
+}
+
+func countFallback(lang string, why string) {
+	record(lang, func(s *langStats) {
+		if s.Fallbacks == nil {
+			s.Fallbacks = map[string]int{}
+		}
+		s.Fallbacks[why]++
+	})
+}
+
+func countFailedPage(lang string, what string) {
+	record(lang, func(s *langStats) { s.Failed = append(s.Failed, what) })
+}
+
+func countBrokenLink(lang string, what string) {
+	record(lang, func(s *langStats) { s.Broken = append(s.Broken, what) })
+}
+
+func countElapsed(lang string, d time.Duration) {
+	record(lang, func(s *langStats) { s.Elapsed += d })
+}
+
+// every language's numbers and the total
+func summarize() ([]*langStats, *langStats) {
+	runStatsMu.Lock()
+	defer runStatsMu.Unlock()
+	var all []*langStats
+	total := &langStats{Lang: "total"}
+	for _, lang := range conf.Languages {
+		s, ok := runStats[lang]
+		if !ok {
+			s = &langStats{Lang: lang}
+		}
+		s.Cost = float64(s.Chars) * pricePerMillion / 1000000
+		s.Seconds = s.Elapsed.Seconds()
+		total.Created += s.Created
+		total.Skipped += s.Skipped
+		total.Chars += s.Chars
+		total.Sent += s.Sent
+		total.CacheHits += s.CacheHits
+		total.Saved += s.Saved
+		total.Missed += s.Missed
+		total.MissedCh += s.MissedCh
+		total.Fuzzy += s.Fuzzy
+		total.Cost += s.Cost
+		total.Elapsed += s.Elapsed
+		for why, n := range s.Fallbacks {
+			if total.Fallbacks == nil {
+				total.Fallbacks = map[string]int{}
+			}
+			total.Fallbacks[why] += n
+		}
+		total.Failed = append(total.Failed, s.Failed...)
+		total.Broken = append(total.Broken, s.Broken...)
+		all = append(all, s)
+	}
+	total.Seconds = total.Elapsed.Seconds()
+	return all, total
+}
+
+// the table at the end of a run, and the same thing as JSON if asked
+func printSummary(jsonPath string) {
+	all, total := summarize()
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "LANG\tCREATED\tSKIPPED\tCHARS SENT\tCACHE HITS\tFUZZY\tCOST\tTIME")
+	for _, s := range append(all, total) {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t$%.2f\t%s\n", s.Lang, s.Created, s.Skipped, s.Chars, s.CacheHits, s.Fuzzy, s.Cost, s.Elapsed.Round(time.Millisecond))
+	}
+	w.Flush()
+	for _, s := range all {
+		var whys []string
+		for why := range s.Fallbacks {
+			whys = append(whys, why)
+		}
+		sort.Strings(whys)
+		for _, why := range whys {
+			fmt.Printf("%s: %d segments from %s\n", s.Lang, s.Fallbacks[why], why)
+		}
+	}
+	for _, s := range all {
+		for _, what := range s.Failed {
+			fmt.Printf("%s: couldn't translate %s\n", s.Lang, what)
+		}
+	}
+	for _, s := range all {
+		for _, what := range s.Broken {
+			fmt.Printf("%s: broken link %s\n", s.Lang, what)
+		}
+	}
+	for _, s := range append(all, total) {
+		if s.Missed > 0 {
+			fmt.Printf("%s: %d segments (%d characters, about $%.2f) aren't in the cache and were left as they were\n", s.Lang, s.Missed, s.MissedCh, float64(s.MissedCh)*pricePerMillion/1000000)
+		}
+	}
+	labels := summarizeLabels()
+	if len(labels) > 0 {
+		fmt.Println()
+		w = tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "LABEL\tCHARS SENT\tCOST")
+		for _, s := range labels {
+			name := s.Label
+			if name == "" {
+				name = "(none)"
+			}
+			fmt.Fprintf(w, "%s\t%d\t$%.2f\n", name, s.Chars, s.Cost)
+		}
+		w.Flush()
+	}
+	if jsonPath == "" {
+		return
+	}
+	out, err := json.MarshalIndent(struct {
+		Languages []*langStats  `json:"languages"`
+		Total     *langStats    `json:"total"`
+		Labels    []*labelStats `json:"labels,omitempty"`
+	}{all, total, labels}, "", "  ")
+	checkError(err)
+	checkError(os.WriteFile(jsonPath, out, 0644))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// when a file last changed. A fresh clone gives everything the same
+// mtime, so if git has the file and it hasn't been touched since, the
+// last commit is the better answer.
+func lastChanged(path string) time.Time {
+	fi, err := files.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	dir, name := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	if dirty, err := exec.Command("git", "-C", dir, "status", "--porcelain", "--", name).Output(); err == nil && len(dirty) == 0 {
+		out, err := exec.Command("git", "-C", dir, "log", "-1", "--format=%ct", "--", name).Output()
+		if secs, perr := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64); err == nil && perr == nil {
+			return time.Unix(secs, 0)
+		}
+	}
+	return fi.ModTime()
+}
+
+// --force: overwrite translations even when someone has edited them
+var force bool
+
+// a translation someone has reviewed can be locked with translation:
+// manual (or notranslate: true) in its front matter, and then nothing
+// overwrites it, --force included, unless it's run with
+// --respect-locks=false
+var respectLocks = true
+
+func locked(target string) bool {
+	return respectLocks && reviewed(target)
+}
+
+// does the translation say it's been reviewed, with translation: manual
+// or notranslate: true?
+func reviewed(target string) bool {
+	src, err := files.ReadFile(target)
+	if err != nil {
+		return false
+	}
+	lines := frontMatterLines(src)
+	if v, ok := fmValue(lines, "translation"); ok && strings.EqualFold(v, "manual") {
+		return true
+	}
+	v, ok := fmValue(lines, "notranslate")
+	return ok && strings.EqualFold(v, "true")
+}
+
+// has someone been at the translation since the source last changed?
+// One we wrote ourselves and nobody has touched since doesn't count.
+func newerTarget(source string, target string) bool {
+	if !exists(target) || !state.editedSince(target) {
+		return false
+	}
+	return lastChanged(target).After(lastChanged(source))
+}
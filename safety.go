@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// per language word lists, loaded the first time we need them
+var blocklists = map[string][]*regexp.Regexp{}
+
+func loadBlocklist(lang string) []*regexp.Regexp {
+	if bl, ok := blocklists[lang]; ok {
+		return bl
+	}
+	var bl []*regexp.Regexp
+	if path, ok := conf.Safety.Blocklists[lang]; ok {
+		f, err := os.Open(path)
+		checkError(err)
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			term := strings.TrimSpace(scanner.Text())
+			if term == "" || strings.HasPrefix(term, "#") {
+				continue
+			}
+			bl = append(bl, regexp.MustCompile(`(?i)(?:^|[^\pL])(`+regexp.QuoteMeta(term)+`)(?:$|[^\pL])`))
+		}
+		checkError(scanner.Err())
+	}
+	blocklists[lang] = bl
+	return bl
+}
+
+// make sure nothing unfortunate came back from the API. Anything on the
+// blocklist gets flagged for a human to look at, we don't try to fix it.
+func checkSafety(file string, lang string, source string, translated string) {
+	for _, re := range loadBlocklist(lang) {
+		found := re.FindStringSubmatch(translated)
+		if found == nil {
+			continue
+		}
+		m := found[1]
+		fmt.Printf("review:\t %s (%s) has %q\n", file, lang, m)
+		report := conf.Safety.Report
+		if report == "" {
+			report = filepath.Join(stateDir, "review.log")
+		}
+		checkError(os.MkdirAll(filepath.Dir(report), 0755))
+		f, err := os.OpenFile(report, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		checkError(err)
+		fmt.Fprintf(f, "%s\t%s\t%s\nsource:\t%s\ntranslated:\t%s\n\n", file, lang, m, source, translated)
+		f.Close()
+		return
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestCheckSafety(t *testing.T) {
+	conf = defaultConfig()
+	dir := t.TempDir()
+	list := filepath.Join(dir, "fr.txt")
+	os.WriteFile(list, []byte("# not a term\n\nmerde\nnul à chier\n"), 0644)
+	conf.Safety.Blocklists = map[string]string{"fr": list}
+	blocklists = map[string][]*regexp.Regexp{}
+	defer func() { blocklists = map[string][]*regexp.Regexp{} }()
+	for _, c := range []struct {
+		lang, text, want string
+	}{
+		{"fr", "Tout va bien.", ""},
+		{"fr", "Oh, merde !", "merde"},
+		{"fr", "MERDE", "MERDE"},
+		{"fr", "emmerder", ""}, // inside another word
+		{"fr", "C'est nul à chier.", "nul à chier"},
+		{"fr", "not a term", ""},
+		{"de", "Oh, merde !", ""}, // no list
+	} {
+		conf.Safety.Report = filepath.Join(t.TempDir(), "review.log")
+		checkSafety("a.md", c.lang, "source", c.text)
+		got, _ := os.ReadFile(conf.Safety.Report)
+		if c.want == "" {
+			if len(got) > 0 {
+				t.Errorf("%s %q was flagged:\n%s", c.lang, c.text, got)
+			}
+			continue
+		}
+		if !strings.HasPrefix(string(got), "a.md\t"+c.lang+"\t"+c.want+"\n") {
+			t.Errorf("%s %q: report is\n%s\nwant %q flagged", c.lang, c.text, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// --sample 5%: translate only some of the pages that need it, to try a
+// new provider or config on a big site before paying for all of it. The
+// pages are picked at random, but the same ones every time for the same
+// --seed, and a page that's in the sample stays in it as the site grows.
+var (
+	sampleSpec string
+	sampleSeed int64
+	sampled    int // pages in the sample
+	eligible   int // out of the ones that needed translating
+)
+
+// how many of n: "5%", or just a number of pages
+func parseSample(spec string, n int) (int, error) {
+	if p := strings.TrimSuffix(spec, "%"); p != spec {
+		pct, err := strconv.ParseFloat(p, 64)
+		if err != nil || pct <= 0 || pct > 100 {
+			return 0, fmt.Errorf("--sample %q should be a percentage like 5%%, or a number of pages", spec)
+		}
+		k := int(math.Ceil(float64(n) * pct / 100))
+		return k, nil
+	}
+	k, err := strconv.Atoi(spec)
+	if err != nil || k <= 0 {
+		return 0, fmt.Errorf("--sample %q should be a percentage like 5%%, or a number of pages", spec)
+	}
+	if k > n {
+		k = n
+	}
+	return k, nil
+}
+
+// does the page have anything for a run to do?
+func needsWork(from string, page string) bool {
+	if skipReason(page) != "" {
+		return false
+	}
+	for _, lang := range languagesFor(page) {
+		target := targetFor(page, from, lang)
+		if !exists(target) || retranslate(page, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func sampleRank(page string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprint(sampleSeed) + "\x00" + filepath.ToSlash(page)))
+	return hex.EncodeToString(sum[:])
+}
+
+// the pages to do, all of them unless it's a sample
+func samplePages(from string, pages []string) []string {
+	if sampleSpec == "" {
+		return pages
+	}
+	var todo []string
+	for _, p := range pages {
+		if needsWork(from, p) {
+			todo = append(todo, p)
+		}
+	}
+	k, err := parseSample(sampleSpec, len(todo))
+	checkError(err)
+	sort.Slice(todo, func(i, j int) bool { return sampleRank(todo[i]) < sampleRank(todo[j]) })
+	picked := todo[:k]
+	sort.Strings(picked) // back in site order
+	sampled, eligible = len(picked), len(todo)
+	fmt.Printf("Sample:\t\t %d of the %d pages that need translating (seed %d)\n", sampled, eligible, sampleSeed)
+	return picked
+}
+
+// what everything would cost, going by the sample
+func sampleEstimate() {
+	if sampleSpec == "" || sampled == 0 {
+		return
+	}
+	_, total := summarize()
+	fmt.Printf("Sample:\t\t $%.2f for %d pages, so about $%.2f for all %d\n", total.Cost, sampled, total.Cost*float64(eligible)/float64(sampled), eligible)
+}
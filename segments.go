@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// a page's segments go to the provider together, translation.requests
+// at a time, instead of a request a line, and straight into the cache.
+// Putting the page together afterwards is the same as always, it's just
+// all cache hits. Anything that goes wrong here is left for that to deal
+// with a segment at a time, fallbacks and failures and all.
+
+// what xlAt would send for the page, masked the way it would be, and the
+// file:line each is from
+func pageSegments(from string, lang string, readFile string, writeFile string, src []byte, bump bool, refine bool) ([]string, []string) {
+	var texts, wheres []string
+	seen := map[string]bool{}
+	xlateLines(io.Discard.(io.StringWriter), from, lang, readFile, writeFile, src, bump, func(text string, where string) string {
+		if nothingToTranslate(text) || isLiteral(text) {
+			return text
+		}
+		if _, ok := humanTranslation(from, lang, text); ok {
+			return text
+		}
+		send, _ := maskSegment(from, lang, text)
+		if _, ok := fuzzyTranslation(from, lang, send); (ok && !refine) || nothingToTranslate(send) {
+			return text
+		}
+		for _, chunk := range splitLong(send) {
+			chunk = strings.TrimSpace(chunk)
+			if !seen[chunk] {
+				seen[chunk] = true
+				texts = append(texts, chunk)
+				wheres = append(wheres, where)
+			}
+		}
+		return text
+	})
+	return texts, wheres
+}
+
+// translate what the page needs that isn't cached, in as few requests as
+// translation.requests allows
+func prefetchPage(from string, lang string, readFile string, writeFile string, src []byte, bump bool, refine bool) {
+	p := provider()
+	if _, ok := p.(*collector); ok {
+		return
+	}
+	if cacheReadonly || conf.Translation.Requests.Segments == 1 || pivotFor(from, lang) != "" || !supportsLanguage(p, lang) {
+		return
+	}
+	texts, wheres := pageSegments(from, lang, readFile, writeFile, src, bump, refine)
+	key := providerKey(p, lang)
+	c := loadCache()
+	var todo, at []string
+	for i, t := range texts {
+		if _, ok := c.getMasked(key, from, lang, t); !ok {
+			todo = append(todo, t)
+			at = append(at, wheres[i])
+		}
+	}
+	for len(todo) > 1 { // one on its own may as well go the usual way
+		n := requestSize(todo)
+		if !sendSegments(p, from, lang, todo[:n], at[:n]) {
+			return
+		}
+		todo, at = todo[n:], at[n:]
+	}
+}
+
+// how many of texts fit in the next request, at least one
+func requestSize(texts []string) int {
+	limit := conf.Translation.Requests
+	n, chars := 0, 0
+	for n < len(texts) && (limit.Segments <= 0 || n < limit.Segments) {
+		chars += utf8.RuneCountInString(texts[n])
+		if n > 0 && limit.Chars > 0 && chars > limit.Chars {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+// one request for texts, into the cache. False if it didn't work out.
+// A request the provider turns down, not one it couldn't get to, is
+// split in half and each half sent on its own, down to the segment it
+// won't take. That one's left as it is, and the rest of the page is
+// translated.
+func sendSegments(p Provider, from string, lang string, texts []string, wheres []string) bool {
+	out, err := callProvider(p, texts, from, lang)
+	if err == nil && len(out) != len(texts) {
+		err = fmt.Errorf("sent %d segments and got %d back", len(texts), len(out))
+	}
+	if err != nil && !isTransient(err) {
+		if len(texts) == 1 {
+			rejectSegment(p, from, lang, texts[0], wheres[0], err)
+			return true
+		}
+		fmt.Printf("warning: %s turned down %d segments at once, splitting them up to find the one it won't take: %s\n", p.Name(), len(texts), strings.TrimSpace(err.Error()))
+		half := len(texts) / 2
+		return sendSegments(p, from, lang, texts[:half], wheres[:half]) && sendSegments(p, from, lang, texts[half:], wheres[half:])
+	}
+	if err != nil {
+		fmt.Printf("warning: %s couldn't do %d segments at once, sending them one at a time: %s\n", p.Name(), len(texts), strings.TrimSpace(err.Error()))
+		return false
+	}
+	c := loadCache()
+	key := providerKey(p, lang)
+	prefetchedMu.Lock()
+	defer prefetchedMu.Unlock()
+	for i, t := range texts {
+		c.put(key, from, lang, t, out[i])
+		prefetched[cacheKey(key, from, lang, t)] = true
+	}
+	audit(p.Name(), from, lang, lang, texts, wheres[0])
+	return true
+}
+
+var (
+	prefetched   = map[string]bool{}  // cache keys sendSegments filled that nothing has used yet
+	rejected     = map[string]error{} // cache keys of segments the provider won't take, and why
+	prefetchedMu sync.Mutex
+)
+
+// what translateWith hands back for a segment that was rejected, so xlAt
+// leaves it as it is instead of failing the page
+type rejectedSegment struct {
+	err error
+}
+
+func (r *rejectedSegment) Error() string {
+	return "rejected before: " + strings.TrimSpace(r.err.Error())
+}
+
+// the one segment of a request the provider won't take
+func rejectSegment(p Provider, from string, lang string, text string, where string, err error) {
+	why := strings.TrimSpace(err.Error())
+	fmt.Printf("warning: %s (%s): %s won't translate this, it's left as it is: %s\n", where, lang, p.Name(), why)
+	countFailedPage(lang, fmt.Sprintf("%s, one segment left as it is (%s)", where, why))
+	prefetchedMu.Lock()
+	defer prefetchedMu.Unlock()
+	rejected[cacheKey(providerKey(p, lang), from, lang, text)] = err
+}
+
+// did sendSegments find the provider won't take this one?
+func wasRejected(key string) error {
+	prefetchedMu.Lock()
+	defer prefetchedMu.Unlock()
+	if err, ok := rejected[key]; ok {
+		return &rejectedSegment{err}
+	}
+	return nil
+}
+
+// was the hit sent for this page, so it's counted as sent and not as a
+// hit? Only the first time.
+func takePrefetched(key string) bool {
+	prefetchedMu.Lock()
+	defer prefetchedMu.Unlock()
+	if !prefetched[key] {
+		return false
+	}
+	delete(prefetched, key)
+	return true
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRequestSize(t *testing.T) {
+	conf = defaultConfig()
+	for _, c := range []struct {
+		segments, chars int
+		texts           []string
+		want            int
+	}{
+		{0, 0, []string{"aaaa", "bbbb", "cccc"}, 3},
+		{2, 0, []string{"aaaa", "bbbb", "cccc"}, 2},
+		{0, 8, []string{"aaaa", "bbbb", "cccc"}, 2},
+		{0, 7, []string{"aaaa", "bbbb", "cccc"}, 1},
+		{0, 2, []string{"aaaa", "bbbb"}, 1},      // too long, but it has to go somehow
+		{0, 8, []string{"éééé", "ßßßß", "c"}, 2}, // characters, not bytes
+		{5, 100, []string{"aaaa"}, 1},
+	} {
+		conf.Translation.Requests = Requests{Segments: c.segments, Chars: c.chars}
+		if got := requestSize(c.texts); got != c.want {
+			t.Errorf("%d segments, %d chars: requestSize(%q) = %d, want %d", c.segments, c.chars, c.texts, got, c.want)
+		}
+	}
+}
+
+// turns down any request with "bad" in it
+type pickyProvider struct {
+	sizes []int
+}
+
+func (p *pickyProvider) Name() string {
+	return "picky"
+}
+
+func (p *pickyProvider) Translate(texts []string, from string, to string) ([]string, error) {
+	p.sizes = append(p.sizes, len(texts))
+	var out []string
+	for _, t := range texts {
+		if strings.Contains(t, "bad") {
+			return nil, errors.New("googleapi: Error 400: Invalid Value")
+		}
+		out = append(out, "«"+t+"»")
+	}
+	return out, nil
+}
+
+func TestSendSegments(t *testing.T) {
+	for _, c := range []struct {
+		texts []string
+		sizes []int
+	}{
+		{[]string{"a", "b", "c", "d"}, []int{4}},
+		{[]string{"a", "bad", "c", "d"}, []int{4, 2, 1, 1, 2}},
+		{[]string{"a", "b", "c", "bad one", "bad two"}, []int{5, 2, 3, 1, 2, 1, 1}},
+		{[]string{"bad"}, []int{1}},
+	} {
+		memSite(t, nil)
+		rejected = map[string]error{}
+		prefetched = map[string]bool{}
+		p := &pickyProvider{}
+		if !sendSegments(p, "en", "fr", c.texts, make([]string, len(c.texts))) {
+			t.Errorf("%q: didn't work out", c.texts)
+		}
+		if !reflect.DeepEqual(p.sizes, c.sizes) {
+			t.Errorf("%q: sent %v, want %v", c.texts, p.sizes, c.sizes)
+		}
+		cache := loadCache()
+		for _, text := range c.texts {
+			got, ok := cache.get("picky", "en", "fr", text)
+			bad := strings.Contains(text, "bad")
+			if ok == bad || (ok && got != "«"+text+"»") {
+				t.Errorf("%q: %q is cached as %q, %v", c.texts, text, got, ok)
+			}
+			if err := wasRejected(cacheKey("picky", "en", "fr", text)); (err != nil) != bad {
+				t.Errorf("%q: %q rejected: %v", c.texts, text, err)
+			}
+		}
+	}
+	rejected = map[string]error{}
+	prefetched = map[string]bool{}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// {{ < ref "x" > }} and {{ % notice % }}, the API loves spaces
+	scOpen  = regexp.MustCompile(`\{\s*\{\s*([<%])`)
+	scClose = regexp.MustCompile(`([>%])\s*\}\s*\}`)
+	// a whole shortcode once the delimiters are back together
+	scCall = regexp.MustCompile(`\{\{([<%])(.*?)([>%])\}\}`)
+	// name = "value" -> name="value"
+	scEquals = regexp.MustCompile(`([\w-]+)\s+=\s*"|([\w-]+)\s*=\s+"`)
+	// typographic quotes some languages get instead of plain ones, French
+	// puts (non-breaking) spaces inside them too
+	scQuotes = regexp.MustCompile(`[\x{201C}\x{201D}\x{201E}]|\x{00AB}[\s\x{00A0}\x{202F}]*|[\s\x{00A0}\x{202F}]*\x{00BB}`)
+	// " value " -> "value"
+	scPadded = regexp.MustCompile(`"[\s\x{00A0}\x{202F}]*([^"]*?)[\s\x{00A0}\x{202F}]*"`)
+)
+
+// put shortcodes the API mangled back the way Hugo wants them
+func repairShortcodes(text string) string {
+	if !strings.Contains(text, "{") {
+		return text
+	}
+	text = scOpen.ReplaceAllString(text, "{{$1")
+	text = scClose.ReplaceAllString(text, "$1}}")
+	return scCall.ReplaceAllStringFunc(text, func(sc string) string {
+		sc = scQuotes.ReplaceAllString(sc, `"`)
+		sc = scPadded.ReplaceAllString(sc, `"$1"`)
+		return scEquals.ReplaceAllString(sc, `$1$2="`)
+	})
+}
+
+// anything repairShortcodes couldn't fix that would break the Hugo build
+func shortcodeProblems(text string) []string {
+	var problems []string
+	if strings.Count(text, "{{<")+strings.Count(text, "{{%") != strings.Count(text, ">}}")+strings.Count(text, "%}}") {
+		problems = append(problems, "shortcode delimiters don't match up")
+	}
+	for _, sc := range scCall.FindAllString(text, -1) {
+		if strings.Count(sc, `"`)%2 != 0 {
+			problems = append(problems, fmt.Sprintf("unbalanced quotes in %s", sc))
+		}
+	}
+	return problems
+}
+
+func checkShortcodes(file string, lang string, translated string) {
+	for _, p := range shortcodeProblems(translated) {
+		fmt.Printf("warning: %s (%s): %s\n", file, lang, p)
+	}
+}
+
+// which parts of a shortcode are prose. By default a shortcode line is
+// left alone and its body gets translated like anything else.
+type ShortcodePolicy struct {
+	Args       []string `json:"args"`       // named arguments to translate
+	Positional []int    `json:"positional"` // positional arguments to translate, from 0
+	CodeArg    string   `json:"code_arg"`   // if this argument is set the body is code
+	SkipBody   bool     `json:"skip_body"`  // never translate the body
+}
+
+// what the popular themes ship
+var shortcodePresets = map[string]map[string]ShortcodePolicy{
+	"docsy": {
+		"alert":          {Args: []string{"title"}},
+		"pageinfo":       {},
+		"tab":            {Args: []string{"header"}, CodeArg: "lang"},
+		"card":           {Args: []string{"header", "title", "subtitle", "footer"}, CodeArg: "code"},
+		"blocks/cover":   {Args: []string{"title"}},
+		"blocks/feature": {Args: []string{"title", "url_text"}},
+	},
+	"hextra": {
+		"callout":              {},
+		"details":              {Args: []string{"title"}},
+		"card":                 {Args: []string{"title", "subtitle"}},
+		"hextra/feature-card":  {Args: []string{"title", "subtitle"}},
+		"hextra/hero-button":   {Args: []string{"text"}},
+		"hextra/hero-badge":    {},
+		"hextra/hero-headline": {},
+	},
+	"doks": {
+		"callout":   {Args: []string{"title"}},
+		"alert":     {Args: []string{"text"}},
+		"details":   {Positional: []int{0}},
+		"card":      {Args: []string{"title"}},
+		"link-card": {Args: []string{"title", "description"}},
+	},
+	"relearn": {
+		"notice": {Args: []string{"title"}, Positional: []int{1}},
+		"expand": {Args: []string{"title"}, Positional: []int{0}},
+		"tab":    {Args: []string{"title"}},
+		"button": {},
+	},
+}
+
+// the presets for the configured themes, with the config's own on top
+func shortcodePolicy(name string) (ShortcodePolicy, bool) {
+	if p, ok := conf.Shortcodes.Custom[name]; ok {
+		return p, true
+	}
+	for _, theme := range conf.Shortcodes.Themes {
+		if p, ok := shortcodePresets[theme][name]; ok {
+			return p, true
+		}
+	}
+	return ShortcodePolicy{}, false
+}
+
+var (
+	// {{< name args >}} or {{% name args %}} at the start of a line
+	scLine = regexp.MustCompile(`^\{\{([<%])\s*([\w/.-]+)(.*?)\s*/?([>%])\}\}`)
+	// name="value", "value" or value
+	scArg = regexp.MustCompile(`([\w-]+)\s*=\s*"([^"]*)"|([\w-]+)\s*=\s*(\S+)|"([^"]*)"|(\S+)`)
+	// {{< name />}}, which has no body
+	scSelfClosing = regexp.MustCompile(`/\s*[>%]\}\}$`)
+)
+
+// translate the arguments the policy says are prose. Also says which
+// shortcode's body to leave alone, if it's one of those and it has a body
+// on the lines after it: not {{< name />}}, and not one closed on the
+// same line.
+func shortcodeLine(ln string, tr func(string) string) (string, string) {
+	m := scLine.FindStringSubmatchIndex(ln)
+	if m == nil {
+		return ln, ""
+	}
+	name := ln[m[4]:m[5]]
+	p, ok := shortcodePolicy(name)
+	if !ok {
+		return ln, ""
+	}
+	args := ln[m[6]:m[7]]
+	var b strings.Builder
+	last := 0
+	pos := 0
+	code := false
+	for _, a := range scArg.FindAllStringSubmatchIndex(args, -1) {
+		var start, end int // the value, if it's to be translated
+		switch {
+		case a[2] >= 0: // name="value"
+			code = code || (p.CodeArg != "" && args[a[2]:a[3]] == p.CodeArg)
+			if isValueInList(args[a[2]:a[3]], p.Args) {
+				start, end = a[4], a[5]
+			}
+		case a[6] >= 0: // name=value
+			code = code || (p.CodeArg != "" && args[a[6]:a[7]] == p.CodeArg)
+		case a[10] >= 0: // "value"
+			if containsInt(p.Positional, pos) {
+				start, end = a[10], a[11]
+			}
+			pos++
+		default:
+			pos++
+		}
+		if end > start && strings.TrimSpace(args[start:end]) != "" {
+			b.WriteString(args[last:start])
+			// a quote in the translation would end the value early
+			b.WriteString(strings.ReplaceAll(tr(args[start:end]), `"`, "'"))
+			last = end
+		}
+	}
+	b.WriteString(args[last:])
+	skip := ""
+	if (p.SkipBody || code) && !scSelfClosing.MatchString(ln[m[0]:m[1]]) && !closesShortcode(ln[m[1]:], name) {
+		skip = name
+	}
+	return ln[:m[6]] + b.String() + ln[m[7]:], skip
+}
+
+// is this the closing {{< /name >}}?
+func closesShortcode(ln string, name string) bool {
+	return regexp.MustCompile(`\{\{[<%]\s*/` + regexp.QuoteMeta(name) + `\s*[>%]\}\}`).MatchString(ln)
+}
+
+func containsInt(list []int, v int) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
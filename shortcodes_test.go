@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// a page through xlateLines with a "translator" that brackets what it's
+// given, so what was translated is easy to see
+func xlatePage(t *testing.T, src string) string {
+	t.Helper()
+	var out strings.Builder
+	xlateLines(&out, "en", "fr", "content/a/index.en.md", "content/a/index.fr.md", []byte(src), false, func(text string, where string) string {
+		return "<" + text + ">"
+	})
+	return out.String()
+}
+
+func TestShortcodeLine(t *testing.T) {
+	conf = defaultConfig()
+	conf.Shortcodes.Themes = []string{"docsy"}
+	tr := func(s string) string { return "<" + s + ">" }
+	for _, c := range []struct {
+		ln, want, skip string
+	}{
+		{`{{< alert title="Warning" >}}`, `{{< alert title="<Warning>" >}}`, ""},
+		{`{{< card header="Example" code=true >}}`, `{{< card header="<Example>" code=true >}}`, "card"},
+		{`{{< card header="Example" code=true />}}`, `{{< card header="<Example>" code=true />}}`, ""},
+		{`{{< card code=true >}}x := 1{{< /card >}}`, `{{< card code=true >}}x := 1{{< /card >}}`, ""},
+		{`{{< unknown title="Hi" >}}`, `{{< unknown title="Hi" >}}`, ""},
+	} {
+		got, skip := shortcodeLine(c.ln, tr)
+		if got != c.want || skip != c.skip {
+			t.Errorf("shortcodeLine(%q) = %q, %q, want %q, %q", c.ln, got, skip, c.want, c.skip)
+		}
+	}
+}
+
+func TestShortcodesInPages(t *testing.T) {
+	conf = defaultConfig()
+	conf.Shortcodes.Themes = []string{"docsy"}
+	for _, c := range []struct {
+		name, src, want string
+	}{
+		{
+			"in a fence",
+			"```\n{{< alert title=\"Warning\" >}}\n```\n\nAfter.\n",
+			"```\n{{< alert title=\"Warning\" >}}\n```\n\n<After.>\n",
+		},
+		{
+			"self-closing code card",
+			"{{< card header=\"Example\" code=true />}}\n\nThis paragraph comes after the code.\n\n{{< card header=\"Next\" code=true />}}\n\nThe rest.\n",
+			"{{< card header=\"<Example>\" code=true />}}\n\n<This paragraph comes after the code.>\n\n{{< card header=\"<Next>\" code=true />}}\n\n<The rest.>\n",
+		},
+		{
+			"code card with a body",
+			"{{< card header=\"Example\" code=true >}}\nx := 1\n{{< /card >}}\n\nAfter.\n",
+			"{{< card header=\"<Example>\" code=true >}}\nx := 1\n{{< /card >}}\n\n<After.>\n",
+		},
+	} {
+		if got := xlatePage(t, c.src); got != c.want {
+			t.Errorf("%s:\n%s\nwant\n%s", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// With pages.translate_slugs a translation gets its own URL: the slug in
+// the front matter is translated, or if there isn't one, made from the
+// translated title.
+
+// lower case letters and digits with dashes between. Accents and other
+// scripts stay, Hugo is fine with them.
+func slugify(s string) string {
+	var out strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) {
+			if dash && out.Len() > 0 {
+				out.WriteByte('-')
+			}
+			out.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return out.String()
+}
+
+// my-first-post -> "my first post" is what gets translated
+func translateSlug(slug string, tr func(string) string) string {
+	return slugify(tr(strings.ReplaceAll(unquote(slug), "-", " ")))
+}
+
+// the section a page's URL is in: content/posts for
+// content/posts/hello/index.en.md, content for content/posts/_index.en.md
+func slugSection(source string) string {
+	return filepath.Dir(filepath.Dir(source))
+}
+
+type slugKey struct {
+	lang    string
+	section string
+	slug    string
+}
+
+type sluggedPage struct {
+	source  string
+	target  string
+	written bool // by this run
+}
+
+// Two pages in a section with the same slug is one page that Hugo quietly
+// drops. When translations collide, the one with the first source path
+// keeps the slug and the rest get -2, -3 and so on, so it comes out the
+// same whatever order the workers got to them. A translation this run
+// didn't write keeps its slug, it's already out there.
+func resolveSlugCollisions(from string, dir string) {
+	if !conf.Pages.TranslateSlugs {
+		return
+	}
+	written := map[string]bool{}
+	backupsMu.Lock()
+	for _, b := range backups {
+		written[filepath.Clean(b.Target)] = true
+	}
+	backupsMu.Unlock()
+	groups := map[slugKey][]sluggedPage{}
+	for _, p := range sourcePages(from, dir) {
+		for _, lang := range conf.Languages {
+			target := targetFor(p, from, lang)
+			src, err := files.ReadFile(target)
+			if err != nil {
+				continue
+			}
+			slug, ok := fmValue(frontMatterLines(src), "slug")
+			if !ok || slug == "" {
+				continue
+			}
+			k := slugKey{lang, slugSection(p), slug}
+			groups[k] = append(groups[k], sluggedPage{p, target, written[filepath.Clean(target)]})
+		}
+	}
+	var keys []slugKey
+	for k, pages := range groups {
+		if len(pages) > 1 {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.lang != b.lang {
+			return a.lang < b.lang
+		}
+		if a.section != b.section {
+			return a.section < b.section
+		}
+		return a.slug < b.slug
+	})
+	for _, k := range keys {
+		pages := groups[k]
+		sort.Slice(pages, func(i, j int) bool {
+			if pages[i].written != pages[j].written {
+				return !pages[i].written
+			}
+			return pages[i].source < pages[j].source
+		})
+		fmt.Printf("Slug collision (%s):\t %q in %s\n", k.lang, k.slug, k.section)
+		fmt.Printf("\t%s keeps it\n", pages[0].target)
+		n := 2
+		for _, p := range pages[1:] {
+			if !p.written {
+				fmt.Printf("warning: %s has it too and wasn't translated this run, it's left alone\n", p.target)
+				continue
+			}
+			for groups[slugKey{k.lang, k.section, fmt.Sprintf("%s-%d", k.slug, n)}] != nil {
+				n++
+			}
+			slug := fmt.Sprintf("%s-%d", k.slug, n)
+			groups[slugKey{k.lang, k.section, slug}] = []sluggedPage{p}
+			setSlug(p.target, slug)
+			fmt.Printf("\t%s is now %s\n", p.target, slug)
+		}
+	}
+}
+
+var slugLine = regexp.MustCompile(`(?mi)^slug:.*$`)
+
+// change the slug in a translation we've just written, and say so in the
+// manifest so it doesn't look like it was edited by hand
+func setSlug(target string, slug string) {
+	src, err := files.ReadFile(target)
+	checkError(err)
+	end := frontMatterEnd(src)
+	if end < 0 {
+		return
+	}
+	out := append(slugLine.ReplaceAll(src[:end], []byte("slug: "+slug)), src[end:]...)
+	if bytes.Equal(out, src) {
+		return
+	}
+	checkError(files.WriteFile(target, out))
+	state.touch(target)
+}
@@ -0,0 +1,57 @@
+package main
+
+import "testing"
+
+func TestSlugify(t *testing.T) {
+	for s, want := range map[string]string{
+		"My First Post":        "my-first-post",
+		"  Déjà vu, again!  ":  "déjà-vu-again",
+		"C'est l'été":          "c-est-l-été",
+		"日本語のページ":              "日本語のページ",
+		"version 2.0 -- final": "version-2-0-final",
+		"!!!":                  "",
+	} {
+		if got := slugify(s); got != want {
+			t.Errorf("slugify(%q) = %q, want %q", s, got, want)
+		}
+	}
+}
+
+func TestTranslateSlug(t *testing.T) {
+	tr := func(s string) string {
+		if s != "my first post" {
+			t.Errorf("sent %q", s)
+		}
+		return "Mon premier article"
+	}
+	if got := translateSlug(`"my-first-post"`, tr); got != "mon-premier-article" {
+		t.Errorf("translateSlug = %q", got)
+	}
+}
+
+func TestResolveSlugCollisions(t *testing.T) {
+	m := memSite(t, map[string]string{
+		"content/posts/b/index.en.md": "---\ntitle: B\n---\n",
+		"content/posts/a/index.en.md": "---\ntitle: A\n---\n",
+		"content/posts/c/index.en.md": "---\ntitle: C\n---\n",
+		"content/posts/a/index.fr.md": "---\ntitle: Salut\nslug: salut\n---\n",
+		"content/posts/b/index.fr.md": "---\ntitle: Salut\nslug: salut\n---\n",
+		"content/posts/c/index.fr.md": "---\ntitle: Salut\nslug: salut\n---\n",
+	})
+	conf.Pages.TranslateSlugs = true
+	saved := backups
+	defer func() { backups = saved }()
+	// c was there already, a and b are new
+	backups = []backupEntry{{Target: "content/posts/a/index.fr.md"}, {Target: "content/posts/b/index.fr.md"}}
+	resolveSlugCollisions("en", "content/posts")
+	got := m.dump()
+	for target, want := range map[string]string{
+		"content/posts/c/index.fr.md": "---\ntitle: Salut\nslug: salut\n---\n",
+		"content/posts/a/index.fr.md": "---\ntitle: Salut\nslug: salut-2\n---\n",
+		"content/posts/b/index.fr.md": "---\ntitle: Salut\nslug: salut-3\n---\n",
+	} {
+		if got[target] != want {
+			t.Errorf("%s:\n%s\nwant\n%s", target, got[target], want)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// everything the translator remembers between runs lives in here
+const stateDir = ".translator"
+
+var statePath = filepath.Join(stateDir, "state.json")
+
+// what happened the last time we translated a page
+type pageState struct {
+	Source     string    `json:"source"`
+	Lang       string    `json:"lang"`
+	SourceHash string    `json:"source_hash"`
+	TargetHash string    `json:"target_hash,omitempty"` // what we wrote, to tell if someone edits it
+	Status     string    `json:"status"`                // "done", "failed", "preview" or "untranslated"
+	Updated    time.Time `json:"updated"`
+}
+
+// the state manifest, keyed by the translated file
+type manifest struct {
+	Pages map[string]*pageState `json:"pages"`
+	mu    sync.Mutex
+}
+
+var state = loadState()
+
+// read the manifest, an empty one is fine if we've never run before
+func loadState() *manifest {
+	m := &manifest{Pages: map[string]*pageState{}}
+	f, err := os.ReadFile(statePath)
+	if os.IsNotExist(err) {
+		return m
+	}
+	checkError(err)
+	checkError(json.Unmarshal(f, m))
+	if m.Pages == nil {
+		m.Pages = map[string]*pageState{}
+	}
+	return m
+}
+
+func (m *manifest) save() {
+	checkError(os.MkdirAll(stateDir, 0755))
+	out, err := json.MarshalIndent(m, "", "  ")
+	checkError(err)
+	checkError(os.WriteFile(statePath, out, 0644))
+}
+
+// record how a page went. We mark it failed before translating and done
+// afterwards, so a run that dies halfway leaves the page marked failed.
+func (m *manifest) mark(source string, target string, lang string, status string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if status == "done" && cacheReadonly { // maybe not all translated, so not done
+		status = "preview"
+	}
+	ps := &pageState{
+		Source:     source,
+		Lang:       lang,
+		SourceHash: hashFile(source),
+		Status:     status,
+		Updated:    time.Now(),
+	}
+	if status == "done" {
+		ps.TargetHash = hashFile(target)
+	}
+	m.Pages[target] = ps
+	notePage(target, status)
+	m.save()
+}
+
+// a translation we changed after it was marked done, so it doesn't look
+// like it was edited by hand
+func (m *manifest) touch(target string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if ps, ok := m.Pages[target]; ok {
+		ps.Updated = time.Now()
+		if ps.Status == "done" {
+			ps.TargetHash = hashFile(target)
+		}
+		m.save()
+	}
+}
+
+// has a translation we wrote been changed since? Older state files don't
+// have the hash, so for those it's whether it was saved afterwards.
+func (m *manifest) editedSince(target string) bool {
+	m.mu.Lock()
+	ps, ok := m.Pages[target]
+	m.mu.Unlock()
+	if !ok || ps.Status != "done" {
+		return true
+	}
+	if ps.TargetHash != "" {
+		return ps.TargetHash != hashFile(target)
+	}
+	fi, err := files.Stat(target)
+	return err == nil && fi.ModTime().After(ps.Updated)
+}
+
+// a translation we made whose source has changed since is translated
+// again, unless someone has edited it in the meantime (--force does it
+// anyway). One a run didn't finish, or a --cache-readonly run did, is
+// done again too. The paragraphs that didn't change come out of the
+// cache, so only the edits are paid for.
+func retranslate(source string, target string) bool {
+	retranslateMu.Lock()
+	defer retranslateMu.Unlock()
+	if redo, ok := retranslateChecked[target]; ok { // the batch asked already
+		return redo
+	}
+	redo := needsRetranslation(source, target)
+	retranslateChecked[target] = redo
+	return redo
+}
+
+var (
+	retranslateChecked = map[string]bool{}
+	retranslateMu      sync.Mutex
+)
+
+func needsRetranslation(source string, target string) bool {
+	state.mu.Lock()
+	ps, ok := state.Pages[target]
+	state.mu.Unlock()
+	if !ok || ps.Source != source || ps.SourceHash == "" {
+		return false // not one of ours, or from before we kept track
+	}
+	if doneBefore(target) && ps.SourceHash == hashFile(source) {
+		return false // the run we're resuming got through it
+	}
+	if ps.Status == "done" && ps.SourceHash == hashFile(source) {
+		return false
+	}
+	if locked(target) {
+		fmt.Printf("Skipping:\t %s (locked, translation: manual)\n", target)
+		return false
+	}
+	if ps.Status != "done" { // didn't finish, or only from the cache
+		return true
+	}
+	if !force && state.editedSince(target) {
+		fmt.Printf("Skipping:\t %s (%s changed, but so has this translation, --force to redo it)\n", target, source)
+		return false
+	}
+	return true
+}
+
+// we changed a source page ourselves, and not in a way that makes its
+// translations stale
+func (m *manifest) sourceChanged(source string, oldHash string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	newHash := hashFile(source)
+	for _, ps := range m.Pages {
+		if ps.Source == source && ps.SourceHash == oldHash {
+			ps.SourceHash = newHash
+		}
+	}
+	m.save()
+}
+
+func hashFile(path string) string {
+	f, err := files.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(f)
+	return hex.EncodeToString(sum[:])
+}
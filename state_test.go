@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNeedsRetranslation(t *testing.T) {
+	const source, target = "content/a/index.en.md", "content/a/index.fr.md"
+	saved := state
+	defer func() { state, force = saved, false }()
+	for _, c := range []struct {
+		ps    *pageState
+		force bool
+		want  bool
+	}{
+		{nil, false, false}, // not one of ours
+		{&pageState{Source: "content/b/index.en.md", SourceHash: "old", Status: "done"}, false, false},
+		{&pageState{Source: source, Status: "done"}, false, false}, // from before we kept track
+		{&pageState{Source: source, SourceHash: "{source}", Status: "done", TargetHash: "{target}"}, false, false},
+		{&pageState{Source: source, SourceHash: "{source}", Status: "failed"}, false, true},
+		{&pageState{Source: source, SourceHash: "old", Status: "done", TargetHash: "{target}"}, false, true},
+		{&pageState{Source: source, SourceHash: "old", Status: "done", TargetHash: "edited"}, false, false},
+		{&pageState{Source: source, SourceHash: "old", Status: "done", TargetHash: "edited"}, true, true},
+		{&pageState{Source: source, SourceHash: "old", Status: "done", Updated: time.Now().Add(time.Hour)}, false, true}, // an older state file
+		{&pageState{Source: source, SourceHash: "old", Status: "done"}, false, false},                                    // saved since
+	} {
+		memSite(t, map[string]string{source: "Hello.\n", target: "Salut.\n"})
+		state = &manifest{Pages: map[string]*pageState{}}
+		if c.ps != nil {
+			ps := *c.ps
+			if ps.SourceHash == "{source}" {
+				ps.SourceHash = hashFile(source)
+			}
+			if ps.TargetHash == "{target}" {
+				ps.TargetHash = hashFile(target)
+			}
+			state.Pages[target] = &ps
+		}
+		force = c.force
+		if got := needsRetranslation(source, target); got != c.want {
+			t.Errorf("%+v, force %v: %v, want %v", c.ps, c.force, got, c.want)
+		}
+	}
+}
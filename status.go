@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+)
+
+// Google charges per character sent, this is the NMT list price in USD
+const pricePerMillion = 20.0
+
+// how far along a language is
+type langStatus struct {
+	Lang       string
+	Total      int
+	Translated int      // translated and up to date
+	Stale      []string // source changed since it was translated
+	Failed     []string // the last attempt died
+	Missing    []string // never translated
+	Chars      int      // characters left to send to get to 100%
+}
+
+func (l *langStatus) Coverage() float64 {
+	if l.Total == 0 {
+		return 100
+	}
+	return float64(l.Translated) * 100 / float64(l.Total)
+}
+
+func (l *langStatus) Cost() float64 {
+	return float64(l.Chars) * pricePerMillion / 1000000
+}
+
+// find all the source pages (index.en.md and _index.en.md, or index.md
+// and _index.md in the directory layout) under dir
+func sourcePages(from string, dir string) []string {
+	names := []string{"index." + fileCode(from) + ".md", "_index." + fileCode(from) + ".md"}
+	if dirLayout() {
+		names = []string{"index.md", "_index.md"}
+		if lang := otherLanguageDir(dir, from); lang != "" {
+			checkError(fmt.Errorf("%s is the %s content, the pages to translate are in %s", dir, lang, contentDir(from)))
+		}
+	}
+	var pages []string
+	err := walkFiles(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == "images" {
+				return filepath.SkipDir
+			}
+			if dirLayout() && otherLanguageDir(path, from) != "" { // translations, not sources
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if dirLayout() && !within(path, contentDir(from)) { // not in any language's tree
+			return nil
+		}
+		if isValueInList(d.Name(), names) {
+			pages = append(pages, path)
+		}
+		return nil
+	})
+	checkError(err)
+	return pages
+}
+
+// index.en.md -> index.fr.md, or content/en/a/index.md ->
+// content/fr/a/index.md in the directory layout
+func targetFor(source string, from string, lang string) string {
+	if dirLayout() {
+		if rel, ok := relWithin(source, contentDir(from)); ok {
+			return filepath.Join(contentDir(lang), rel)
+		}
+	}
+	return strings.TrimSuffix(source, "."+fileCode(from)+".md") + "." + fileCode(lang) + ".md"
+}
+
+// is the translation older than its source?
+func isStale(source string, target string) bool {
+	if ps, ok := state.Pages[target]; ok && ps.SourceHash != "" {
+		return ps.SourceHash != hashFile(source)
+	}
+	// never recorded, so all we can go on is the timestamps
+	si, err := files.Stat(source)
+	checkError(err)
+	ti, err := files.Stat(target)
+	checkError(err)
+	return si.ModTime().After(ti.ModTime())
+}
+
+func charCount(path string) int {
+	f, err := files.ReadFile(path)
+	checkError(err)
+	return utf8.RuneCount(f)
+}
+
+// work out where every language stands for the pages under dir
+func siteStatus(from string, dir string) []*langStatus {
+	var pages []string
+	for _, src := range sourcePages(from, dir) {
+		if skipReason(src) == "" { // not missing if we'd never translate it
+			pages = append(pages, src)
+		}
+	}
+	var all []*langStatus
+	for _, lang := range conf.Languages {
+		ls := &langStatus{Lang: lang}
+		for _, src := range pages {
+			if !translatedInto(src, lang) { // pages.languages leaves it out
+				continue
+			}
+			ls.Total++
+			target := targetFor(src, from, lang)
+			_, err := files.Stat(target)
+			switch {
+			case state.Pages[target] != nil && (state.Pages[target].Status == "failed" || state.Pages[target].Status == "untranslated"):
+				ls.Failed = append(ls.Failed, target)
+			case os.IsNotExist(err):
+				ls.Missing = append(ls.Missing, target)
+			case isStale(src, target):
+				ls.Stale = append(ls.Stale, target)
+			default:
+				ls.Translated++
+				continue
+			}
+			ls.Chars += charCount(src)
+		}
+		all = append(all, ls)
+	}
+	return all
+}
+
+var statusPage = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Translation status</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.4em 0.8em; text-align: left; }
+.bar { background: #eee; width: 10em; }
+.bar div { background: #4caf50; height: 1em; }
+</style>
+</head>
+<body>
+<h1>Translation status</h1>
+<p>Generated {{.Generated.Format "2006-01-02 15:04"}} for <code>{{.Dir}}</code></p>
+<table>
+<tr><th>Language</th><th>Coverage</th><th></th><th>Up to date</th><th>Stale</th><th>Failed</th><th>Missing</th><th>Cost to complete</th></tr>
+{{range .Langs}}<tr><td>{{.Lang}}</td><td>{{printf "%.1f" .Coverage}}%</td><td><div class="bar"><div style="width: {{printf "%.0f" .Coverage}}%"></div></div></td><td>{{.Translated}} / {{.Total}}</td><td>{{len .Stale}}</td><td>{{len .Failed}}</td><td>{{len .Missing}}</td><td>${{printf "%.2f" .Cost}}</td></tr>
+{{end}}</table>
+{{range .Langs}}{{if or .Stale .Failed}}<h2>{{.Lang}}</h2>
+{{if .Failed}}<h3>Failed</h3><ul>{{range .Failed}}<li>{{.}}</li>{{end}}</ul>{{end}}
+{{if .Stale}}<h3>Stale</h3><ul>{{range .Stale}}<li>{{.}}</li>{{end}}</ul>{{end}}
+{{end}}{{end}}</body>
+</html>
+`))
+
+// shields.io endpoint badge, see https://shields.io/endpoint
+type badge struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	Label         string `json:"label"`
+	Message       string `json:"message"`
+	Color         string `json:"color"`
+}
+
+func badgeColor(pct float64) string {
+	switch {
+	case pct >= 90:
+		return "brightgreen"
+	case pct >= 75:
+		return "green"
+	case pct >= 50:
+		return "yellow"
+	case pct >= 25:
+		return "orange"
+	}
+	return "red"
+}
+
+// one coverage-<lang>.json badge per language plus a coverage.json with
+// the numbers behind them
+func writeBadges(dir string, all []*langStatus) {
+	checkError(os.MkdirAll(dir, 0755))
+	type summary struct {
+		Lang       string  `json:"lang"`
+		Coverage   float64 `json:"coverage"`
+		Translated int     `json:"translated"`
+		Stale      int     `json:"stale"`
+		Failed     int     `json:"failed"`
+		Missing    int     `json:"missing"`
+		Total      int     `json:"total"`
+	}
+	var sum []summary
+	for _, ls := range all {
+		pct := ls.Coverage()
+		b := badge{
+			SchemaVersion: 1,
+			Label:         strings.ToUpper(ls.Lang) + " translation",
+			Message:       fmt.Sprintf("%.0f%%", pct),
+			Color:         badgeColor(pct),
+		}
+		out, err := json.MarshalIndent(b, "", "  ")
+		checkError(err)
+		checkError(os.WriteFile(filepath.Join(dir, "coverage-"+ls.Lang+".json"), out, 0644))
+		sum = append(sum, summary{ls.Lang, math.Round(pct*10) / 10, ls.Translated, len(ls.Stale), len(ls.Failed), len(ls.Missing), ls.Total})
+	}
+	out, err := json.MarshalIndent(sum, "", "  ")
+	checkError(err)
+	checkError(os.WriteFile(filepath.Join(dir, "coverage.json"), out, 0644))
+	fmt.Printf("Wrote coverage badges to %s\n", dir)
+}
+
+// translator status [--html status.html] [--badges dir] [dir]
+func statusCmd(args []string) {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	html := flags.String("html", "", "write an HTML dashboard to this file")
+	configFile := flags.String("config", defaultConfigFile, "config file")
+	flags.StringVar(&profile, "profile", profile, "config profile to use")
+	badges := flags.String("badges", "", "write shields.io coverage badges and a coverage.json summary into this directory")
+	flags.Parse(args)
+	conf = loadConfig(*configFile)
+	dir := "."
+	if flags.NArg() > 0 {
+		dir = flags.Arg(0)
+	}
+	all := siteStatus(conf.Source, dir)
+	if *badges != "" {
+		writeBadges(*badges, all)
+	}
+	if *html != "" {
+		f, err := os.Create(*html)
+		checkError(err)
+		defer f.Close()
+		checkError(statusPage.Execute(f, struct {
+			Dir       string
+			Generated time.Time
+			Langs     []*langStatus
+		}{dir, time.Now(), all}))
+		fmt.Printf("Wrote %s\n", *html)
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "LANG\tCOVERAGE\tUP TO DATE\tSTALE\tFAILED\tMISSING\tCOST")
+	for _, ls := range all {
+		fmt.Fprintf(w, "%s\t%.1f%%\t%d/%d\t%d\t%d\t%d\t$%.2f\n", ls.Lang, ls.Coverage(), ls.Translated, ls.Total, len(ls.Stale), len(ls.Failed), len(ls.Missing), ls.Cost())
+	}
+	w.Flush()
+}
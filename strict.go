@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// --strict: a page with anything in it the masking doesn't know how to
+// keep away from the API is skipped and reported, rather than translated
+// and maybe broken
+var strict bool
+
+var (
+	scAnywhere  = regexp.MustCompile(`\{\{[<%]`)
+	scWhole     = regexp.MustCompile(`\{\{[<%].*?[>%]\}\}`)
+	openTag     = regexp.MustCompile(`<[a-zA-Z][\w-]*(\s[^>]*)?$`)
+	almostFence = regexp.MustCompile("^ {0,3}`{3,}.*`") // a backtick after ``` and it's not a fence
+)
+
+// the structural things in a page that translation can't be trusted
+// with: shortcodes that aren't all on one line or don't look like
+// shortcodes, fences that aren't quite fences or never close, and HTML
+// tags split over lines
+func structuralProblems(path string) []lintIssue {
+	src, err := files.ReadFile(path)
+	checkError(err)
+	src = bytes.TrimPrefix(src, bom)
+	var issues []lintIssue
+	add := func(line int, format string, args ...interface{}) {
+		issues = append(issues, lintIssue{path, line, fmt.Sprintf(format, args...)})
+	}
+	head := false
+	code := false
+	var fc fence
+	fenceLine := 0
+	lineNo := 0
+	scanner := bufio.NewScanner(bytes.NewReader(src))
+	for scanner.Scan() {
+		ln := scanner.Text()
+		lineNo++
+		if (lineNo == 1 && strings.TrimRight(ln, " \r") == "---") || (head && isFrontMatterEnd(ln)) {
+			head = !head
+			continue
+		}
+		if head {
+			continue
+		}
+		if code {
+			code = !fc.closes(ln)
+			continue
+		}
+		if f, ok := openFence(ln); ok {
+			fc = f
+			code = true
+			fenceLine = lineNo
+			continue
+		}
+		if isIndentedCode(ln) {
+			continue
+		}
+		if almostFence.MatchString(ln) {
+			add(lineNo, "looks like a code fence but isn't one")
+		}
+		if strings.HasPrefix(ln, "{{") && !scLine.MatchString(ln) {
+			add(lineNo, "shortcode in a form the translator doesn't know")
+		}
+		if len(scAnywhere.FindAllString(ln, -1)) != len(scWhole.FindAllString(ln, -1)) {
+			add(lineNo, "shortcode split over more than one line")
+		}
+		if openTag.MatchString(inlineCode.ReplaceAllString(ln, "")) {
+			add(lineNo, "HTML tag split over more than one line")
+		}
+	}
+	checkError(scanner.Err())
+	if code {
+		add(fenceLine, "code fence is never closed")
+	}
+	return issues
+}
+
+var (
+	strictChecked   = map[string]bool{} // path -> skip it
+	strictCheckedMu sync.Mutex
+)
+
+// in strict mode, whether to leave a page alone. What's wrong with it is
+// printed the first time it comes up.
+func strictSkip(path string) bool {
+	if !strict {
+		return false
+	}
+	strictCheckedMu.Lock()
+	defer strictCheckedMu.Unlock()
+	if skip, ok := strictChecked[path]; ok {
+		return skip
+	}
+	issues := structuralProblems(path)
+	if len(issues) > 0 {
+		fmt.Printf("Skipping:\t %s (strict, %d problems)\n", path, len(issues))
+		for _, i := range issues {
+			fmt.Printf("\t%s\n", i)
+		}
+	}
+	strictChecked[path] = len(issues) > 0
+	return len(issues) > 0
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Hugo's summary divider. If it goes missing, or wanders off to the end
+// of the page, the summary turns into the whole article.
+var summaryDivider = regexp.MustCompile(`<!--\s*more\s*-->`)
+
+// translate the text either side of a divider in the middle of a line
+// separately, so it ends up exactly where it was
+func translateAroundDivider(ln string, tr func(string) string) string {
+	dividers := summaryDivider.FindAllString(ln, -1)
+	parts := summaryDivider.Split(ln, -1)
+	var b strings.Builder
+	for i, p := range parts {
+		if t := strings.TrimSpace(p); t != "" {
+			start := strings.Index(p, t)
+			p = p[:start] + tr(t) + p[start+len(t):]
+		}
+		b.WriteString(p)
+		if i < len(dividers) {
+			b.WriteString(dividers[i])
+		}
+	}
+	return b.String()
+}
+
+// which paragraph of the body each divider is in
+func dividerParagraphs(src []byte) []int {
+	if fm := frontMatterEnd(src); fm >= 0 {
+		src = src[fm:]
+		if nl := bytes.IndexByte(src, '\n'); nl >= 0 {
+			src = src[nl+1:]
+		}
+	}
+	var found []int
+	para := 0
+	prevBlank := true
+	scanner := bufio.NewScanner(bytes.NewReader(src))
+	for scanner.Scan() {
+		ln := scanner.Text()
+		blank := strings.TrimSpace(ln) == ""
+		if !blank && prevBlank {
+			para++
+		}
+		prevBlank = blank
+		for range summaryDivider.FindAllString(ln, -1) {
+			found = append(found, para)
+		}
+	}
+	return found
+}
+
+// the dividers should be in the same paragraphs in both languages
+func checkSummary(name string, src []byte, translated []byte) {
+	want := dividerParagraphs(src)
+	got := dividerParagraphs(translated)
+	if fmt.Sprint(want) != fmt.Sprint(got) {
+		fmt.Printf("warning: %s: summary divider in paragraph(s) %v, the source has it in %v\n", name, got, want)
+	}
+}
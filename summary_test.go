@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDividerParagraphs(t *testing.T) {
+	for _, c := range []struct {
+		src  string
+		want []int
+	}{
+		{"Intro.\n\n<!--more-->\n\nRest.\n", []int{2}},
+		{"Intro. <!--more--> Rest.\n", []int{1}},
+		{"---\ntitle: Hi\n---\n\nIntro.\nMore intro.\n<!-- more -->\n\nRest.\n", []int{1}},
+		{"No divider.\n", nil},
+	} {
+		if got := dividerParagraphs([]byte(c.src)); fmt.Sprint(got) != fmt.Sprint(c.want) {
+			t.Errorf("dividerParagraphs(%q) = %v, want %v", c.src, got, c.want)
+		}
+	}
+}
+
+func TestTranslateAroundDivider(t *testing.T) {
+	tr := func(s string) string { return "<" + s + ">" }
+	for ln, want := range map[string]string{
+		"First bit. <!--more--> Second bit.": "<First bit.> <!--more--> <Second bit.>",
+		"First bit.<!-- more -->":            "<First bit.><!-- more -->",
+		"<!--more-->":                        "<!--more-->",
+	} {
+		if got := translateAroundDivider(ln, tr); got != want {
+			t.Errorf("translateAroundDivider(%q) = %q, want %q", ln, got, want)
+		}
+	}
+}
+
+func TestDividerInPages(t *testing.T) {
+	conf = defaultConfig()
+	for src, want := range map[string]string{
+		"First bit. <!--more--> Second bit.\n": "<First bit.> <!--more--> <Second bit.>\n",
+		"Intro.\n\n<!--more-->\n\nRest.\n":     "<Intro.>\n\n<!--more-->\n\n<Rest.>\n",
+	} {
+		if got := xlatePage(t, src); got != want {
+			t.Errorf("%q: got %q, want %q", src, got, want)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// translations somebody wrote by hand go in the cache under their own
+// provider name, never expire, and win over any machine translation
+const humanProvider = "human"
+
+// has a person already translated exactly this? Only the local cache,
+// so it doesn't cost a remote lookup for every line. Imported memories
+// don't have the spaces around our segments (the one after "title:"), so
+// those are tried without them too.
+func humanTranslation(from string, to string, text string) (string, bool) {
+	c := loadCache()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.Entries[cacheKey(humanProvider, from, to, text)]; ok {
+		return e.Text, true
+	}
+	trimmed := strings.TrimSpace(text)
+	if trimmed == text || trimmed == "" {
+		return "", false
+	}
+	if e, ok := c.Entries[cacheKey(humanProvider, from, to, trimmed)]; ok {
+		start := strings.Index(text, trimmed)
+		return text[:start] + e.Text + text[start+len(trimmed):], true
+	}
+	return "", false
+}
+
+func (c *transCache) seed(from string, to string, text string, translated string) {
+	key := cacheKey(humanProvider, from, to, text)
+	if e, ok := c.Entries[key]; ok && e.Text == translated {
+		return
+	}
+	c.Entries[key] = &cacheEntry{
+		Source:   text,
+		Text:     translated,
+		From:     from,
+		Lang:     to,
+		Provider: humanProvider,
+		Created:  time.Now(),
+	}
+	c.added(key)
+}
+
+// line up a page with its translation and remember every segment that
+// pairs up cleanly. Returns how many segments were seeded and how many
+// source blocks had to be skipped.
+func seedPage(c *transCache, from string, lang string, source string, target string) (int, int) {
+	src, err := files.ReadFile(source)
+	checkError(err)
+	dst, err := files.ReadFile(target)
+	checkError(err)
+	srcFields, srcBlocks := pageBlocks(src)
+	dstFields, dstBlocks := pageBlocks(dst)
+	seeded, skipped := 0, 0
+	for _, k := range []string{"title", "description"} {
+		s, ok := srcFields[k]
+		t, found := dstFields[k]
+		if ok && found && strings.TrimSpace(t) != "" {
+			c.seed(from, lang, s, strings.TrimSpace(t))
+			seeded++
+		}
+	}
+	for _, p := range alignBlocks(srcBlocks, dstBlocks) {
+		if p.src == nil || !p.src.translatable() {
+			continue
+		}
+		// a paragraph that was rewrapped by hand can't be split back
+		// into the lines we translate one at a time
+		if p.dst == nil || len(p.src.segs) != len(p.dst.segs) {
+			skipped++
+			continue
+		}
+		for i, s := range p.src.segs {
+			c.seed(from, lang, s, p.dst.segs[i])
+			seeded++
+		}
+	}
+	return seeded, skipped
+}
+
+// translator tm seed|export|import
+func tmCmd(args []string) {
+	if len(args) == 0 {
+		fmt.Println("usage: translate tm seed|export|import [--config translator.json]")
+		os.Exit(1)
+	}
+	switch args[0] {
+	case "seed":
+		tmSeed(args[1:])
+	case "export":
+		tmExport(args[1:])
+	case "import":
+		tmImport(args[1:])
+	default:
+		fmt.Println("usage: translate tm seed|export|import [--config translator.json]")
+		os.Exit(1)
+	}
+}
+
+// translator tm seed [--machine] [dir]
+func tmSeed(args []string) {
+	flags := flag.NewFlagSet("tm seed", flag.ExitOnError)
+	configFile := flags.String("config", defaultConfigFile, "config file")
+	flags.StringVar(&profile, "profile", profile, "config profile to use")
+	machine := flags.Bool("machine", false, "also seed from pages the translator wrote itself")
+	flags.Parse(args)
+	conf = loadConfig(*configFile)
+	dir := "."
+	if flags.NArg() > 0 {
+		dir = flags.Arg(0)
+	}
+	c := loadCache()
+	total := 0
+	for _, source := range sourcePages(conf.Source, dir) {
+		for _, lang := range conf.Languages {
+			target := targetFor(source, conf.Source, lang)
+			if _, err := files.Stat(target); os.IsNotExist(err) {
+				continue
+			}
+			if state.Pages[target] != nil && !*machine {
+				continue // we made this one, it isn't human wording
+			}
+			seeded, skipped := seedPage(c, conf.Source, lang, source, target)
+			fmt.Printf("Seeded:\t %s (%d segments", target, seeded)
+			if skipped > 0 {
+				fmt.Printf(", %d paragraphs didn't line up", skipped)
+			}
+			fmt.Println(")")
+			total += seeded
+		}
+	}
+	checkError(c.compact())
+	fmt.Printf("Seeded %d segments into %s\n", total, cachePath())
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"html"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TMX, the translation memory format translation vendors and CAT tools
+// use. tm export writes the cache out as one, tm import reads one in as
+// human translations.
+
+type tmxDoc struct {
+	XMLName xml.Name  `xml:"tmx"`
+	Version string    `xml:"version,attr"`
+	Header  tmxHeader `xml:"header"`
+	Units   []tmxUnit `xml:"body>tu"`
+}
+
+type tmxHeader struct {
+	CreationTool        string `xml:"creationtool,attr"`
+	CreationToolVersion string `xml:"creationtoolversion,attr"`
+	DataType            string `xml:"datatype,attr"`
+	SegType             string `xml:"segtype,attr"`
+	AdminLang           string `xml:"adminlang,attr"`
+	SrcLang             string `xml:"srclang,attr"`
+	OTmf                string `xml:"o-tmf,attr"`
+}
+
+type tmxUnit struct {
+	Variants []tmxVariant `xml:"tuv"`
+}
+
+type tmxVariant struct {
+	Lang    string `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
+	OldLang string `xml:"lang,attr,omitempty"` // TMX 1.1 didn't have xml:lang
+	Created string `xml:"creationdate,attr,omitempty"`
+	Seg     tmxSeg `xml:"seg"`
+}
+
+type tmxSeg struct {
+	Inner string `xml:",innerxml"`
+}
+
+// a seg can have inline markup (<bpt>, <ph> and so on) around the
+// original's formatting codes. The codes are kept, the markup isn't.
+var tmxInline = regexp.MustCompile(`<[^>]+>`)
+
+func (s tmxSeg) text() string {
+	return html.UnescapeString(tmxInline.ReplaceAllString(s.Inner, ""))
+}
+
+func newSeg(text string) tmxSeg {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(text))
+	return tmxSeg{b.String()}
+}
+
+func (v tmxVariant) lang() string {
+	if v.Lang != "" {
+		return v.Lang
+	}
+	return v.OldLang
+}
+
+// which of ours a TMX language is: the same code, or the same language
+// if ours doesn't have a region (fr-FR is fr, but pt-PT isn't pt-br)
+func tmxLang(code string, ours []string) string {
+	for _, l := range ours {
+		if strings.EqualFold(code, l) {
+			return l
+		}
+	}
+	base := strings.Split(strings.ToLower(code), "-")[0]
+	for _, l := range ours {
+		if !strings.Contains(l, "-") && strings.EqualFold(base, l) {
+			return l
+		}
+	}
+	return ""
+}
+
+// translator tm export [--human] [--out translations.tmx]
+func tmExport(args []string) {
+	flags := flag.NewFlagSet("tm export", flag.ExitOnError)
+	configFile := flags.String("config", defaultConfigFile, "config file")
+	flags.StringVar(&profile, "profile", profile, "config profile to use")
+	out := flags.String("out", "translations.tmx", "file to write")
+	human := flags.Bool("human", false, "only translations people wrote")
+	flags.Parse(args)
+	conf = loadConfig(*configFile)
+	c := loadCache()
+	now := time.Now()
+	best := map[string]map[string]*cacheEntry{} // source text -> language -> translation
+	missing := 0
+	for _, e := range c.Entries {
+		if e.From != conf.Source || e.expired(now) || (*human && e.Provider != humanProvider) {
+			continue
+		}
+		if e.Source == "" { // cached before the source text was kept
+			missing++
+			continue
+		}
+		source := strings.TrimSpace(e.Source)
+		if best[source] == nil {
+			best[source] = map[string]*cacheEntry{}
+		}
+		// what a person wrote, otherwise the newest
+		if b, ok := best[source][e.Lang]; ok {
+			if b.Provider == humanProvider || (e.Provider != humanProvider && b.Created.After(e.Created)) {
+				continue
+			}
+		}
+		best[source][e.Lang] = e
+	}
+	var sources []string
+	for s := range best {
+		sources = append(sources, s)
+	}
+	sort.Strings(sources)
+	doc := tmxDoc{
+		Version: "1.4",
+		Header: tmxHeader{
+			CreationTool:        "translate",
+			CreationToolVersion: "1",
+			DataType:            "plaintext",
+			SegType:             "sentence",
+			AdminLang:           "en",
+			SrcLang:             conf.Source,
+			OTmf:                "translate",
+		},
+	}
+	pairs := 0
+	for _, s := range sources {
+		u := tmxUnit{Variants: []tmxVariant{{Lang: conf.Source, Seg: newSeg(s)}}}
+		var langs []string
+		for l := range best[s] {
+			langs = append(langs, l)
+		}
+		sort.Strings(langs)
+		for _, l := range langs {
+			e := best[s][l]
+			u.Variants = append(u.Variants, tmxVariant{Lang: l, Created: e.Created.UTC().Format("20060102T150405Z"), Seg: newSeg(strings.TrimSpace(e.Text))})
+			pairs++
+		}
+		doc.Units = append(doc.Units, u)
+	}
+	f, err := os.Create(*out)
+	checkError(err)
+	defer f.Close()
+	f.WriteString(xml.Header)
+	enc := xml.NewEncoder(f)
+	enc.Indent("", "  ")
+	checkError(enc.Encode(doc))
+	f.WriteString("\n")
+	fmt.Printf("Exported %d segments (%d translations) to %s\n", len(doc.Units), pairs, *out)
+	if missing > 0 {
+		fmt.Printf("warning: %d older cache entries don't have their source text and were left out\n", missing)
+	}
+}
+
+// translator tm import <file.tmx>
+func tmImport(args []string) {
+	flags := flag.NewFlagSet("tm import", flag.ExitOnError)
+	configFile := flags.String("config", defaultConfigFile, "config file")
+	flags.StringVar(&profile, "profile", profile, "config profile to use")
+	flags.Parse(args)
+	conf = loadConfig(*configFile)
+	if flags.NArg() != 1 {
+		fmt.Println("usage: translate tm import [--config translator.json] <file.tmx>")
+		os.Exit(1)
+	}
+	f, err := os.Open(flags.Arg(0))
+	checkError(err)
+	defer f.Close()
+	var doc tmxDoc
+	checkError(xml.NewDecoder(f).Decode(&doc))
+	c := loadCache()
+	imported, skipped := 0, 0
+	other := map[string]bool{} // languages in the file we don't translate into
+	for _, u := range doc.Units {
+		source := ""
+		for _, v := range u.Variants {
+			if tmxLang(v.lang(), []string{conf.Source}) != "" {
+				source = v.Seg.text()
+			}
+		}
+		if strings.TrimSpace(source) == "" {
+			skipped++
+			continue
+		}
+		for _, v := range u.Variants {
+			lang := tmxLang(v.lang(), conf.Languages)
+			if lang == "" {
+				if tmxLang(v.lang(), []string{conf.Source}) == "" {
+					other[v.lang()] = true
+				}
+				continue
+			}
+			if t := v.Seg.text(); strings.TrimSpace(t) != "" {
+				c.seed(conf.Source, lang, source, t)
+				imported++
+			}
+		}
+	}
+	checkError(c.compact())
+	fmt.Printf("Imported %d translations from %d segments into %s\n", imported, len(doc.Units)-skipped, cachePath())
+	if skipped > 0 {
+		fmt.Printf("warning: %d segments had no %s text and were skipped\n", skipped, conf.Source)
+	}
+	if len(other) > 0 {
+		var langs []string
+		for l := range other {
+			langs = append(langs, l)
+		}
+		sort.Strings(langs)
+		fmt.Printf("warning: not translating into %s, so those were left out\n", strings.Join(langs, ", "))
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// every segment that went through a run, for working out why a sentence
+// came out the way it did, or for showing an auditor what left the
+// building
+type transcriptLog struct {
+	f       *os.File
+	verbose bool // also what was actually sent and what came back
+	redact  bool // no emails, phone numbers or keys in the log
+	mu      sync.Mutex
+}
+
+var transcript *transcriptLog
+
+func openTranscript(path string, verbose bool, redact bool) {
+	f, err := os.Create(path)
+	checkError(err)
+	transcript = &transcriptLog{f: f, verbose: verbose, redact: redact}
+}
+
+func closeTranscript() {
+	if transcript != nil {
+		transcript.f.Close()
+	}
+}
+
+func redactPII(text string) string {
+	text = emailRe.ReplaceAllString(text, "[redacted]")
+	text = tokenRe.ReplaceAllStringFunc(text, func(s string) string {
+		if tokenLike(s) {
+			return "[redacted]"
+		}
+		return s
+	})
+	return phoneRe.ReplaceAllString(text, "[redacted]")
+}
+
+// one segment: where it came from, how it got translated (api, reused or
+// human), what it was and what it became. sent and received are what the
+// provider saw, placeholders and all, and only go in when verbose.
+func logSegment(where string, lang string, how string, source string, sent string, received string, result string) {
+	t := transcript
+	if t == nil {
+		return
+	}
+	if where == "" {
+		where = "-"
+	}
+	lines := [][2]string{{"source", source}}
+	if t.verbose && sent != "" {
+		lines = append(lines, [2]string{"sent", sent}, [2]string{"received", received})
+	}
+	lines = append(lines, [2]string{"result", result})
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fmt.Fprintf(t.f, "%s [%s] (%s)\n", where, lang, how)
+	for _, l := range lines {
+		text := l[1]
+		if t.redact {
+			text = redactPII(text)
+		}
+		fmt.Fprintf(t.f, "  %-9s %s\n", l[0]+":", text)
+	}
+	fmt.Fprintln(t.f)
+}
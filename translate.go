@@ -2,12 +2,18 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	"cloud.google.com/go/translate"
 	readingtime "github.com/begmaroman/reading-time"
@@ -27,45 +33,96 @@ func AuthTranslate(jsonPath, projectID string) (*translate.Client, context.Conte
 }
 
 // this is directly copy/pasted from Google example
-func translateTextWithModel(targetLanguage, text, model string) (string, error) {
+func translateTextWithModel(targetLanguage string, texts []string, model string) ([]string, error) {
 
 	lang, err := language.Parse(targetLanguage)
 	if err != nil {
-		return "", fmt.Errorf("language.Parse: %v", err)
+		return nil, fmt.Errorf("language.Parse: %v", err)
 	}
-	client, ctx, err := AuthTranslate("google-secret.json", "103373479946395174633")
+	client, ctx, err := AuthTranslate(conf.Translation.Credentials, conf.Translation.ProjectID)
 	if err != nil {
-		return "", fmt.Errorf("translate.NewClient: %v", err)
+		return nil, fmt.Errorf("translate.NewClient: %v", err)
 	}
 	defer client.Close()
-	resp, err := client.Translate(ctx, []string{text}, lang, &translate.Options{
+	resp, err := client.Translate(ctx, texts, lang, &translate.Options{
 		Model: model, // Either "nmt" or "base".
 	})
 	if err != nil {
-		return "", fmt.Errorf("Translate: %v", err)
+		return nil, fmt.Errorf("Translate: %v", err)
 	}
-	if len(resp) == 0 {
-		return "", nil
+	if len(resp) != len(texts) {
+		return nil, fmt.Errorf("Translate: sent %d segments and got %d back", len(texts), len(resp))
 	}
-	return resp[0].Text, nil
+	var out []string
+	for _, r := range resp {
+		out = append(out, r.Text)
+	}
+	return out, nil
 }
 
 // I get tired of typing this all the time
 func checkError(err error) {
 	if err != nil {
+		rollbackPending()
+		finishRun(err) // so the history knows how it ended
 		log.Fatal(err)
 	}
 }
 
 func xl(fromLang string, toLang string, xlate string) string {
+	return xlAt(fromLang, toLang, xlate, "", false)
+}
+
+// xl, for text from file:line (for the transcript), and with the LLM
+// post-editing the translation if refine is set
+func xlAt(fromLang string, toLang string, xlate string, where string, refine bool) string {
+	if nothingToTranslate(xlate) || isLiteral(xlate) {
+		logSegment(where, toLang, "kept", xlate, "", "", xlate)
+		return xlate
+	}
+	if translated, ok := reuseTranslation(toLang, xlate); ok {
+		logSegment(where, toLang, "reused", xlate, "", "", translated)
+		return translated
+	}
+	if translated, ok := humanTranslation(fromLang, toLang, xlate); ok {
+		countHit(toLang, xlate)
+		rememberTranslation(toLang, xlate, translated)
+		logSegment(where, toLang, "human", xlate, "", "", translated)
+		return translated
+	}
 	// fix URLs because google translate changes [link](http://you.link) to
 	// [link] (http://your.link) and it *also* will translate any path
 	// components, thus breaking your URLs.
 	reg := regexp.MustCompile(`]\([-a-zA-Z0-9@:%._\+~#=\/]{1,256}\)`)
 	// get all the URLs with a single RegEx, keep them for later.
 	var foundUrls [][]byte = reg.FindAll([]byte(xlate), -1)
-	translated, err := translateTextWithModel(toLang, xlate, "nmt")
-	checkError(err)
+	send, pii := maskSegment(fromLang, toLang, xlate)
+	translated := send
+	how := "translated"
+	if fuzzy, ok := fuzzyTranslation(fromLang, toLang, send); ok && !refine {
+		translated = fuzzy
+		how = "fuzzy"
+		countFuzzy(toLang, send)
+	} else if !nothingToTranslate(send) { // no point paying to send "⟦T0000⟧"
+		var err error
+		translated, err = translateMasked(fromLang, toLang, send, where)
+		var rej *rejectedSegment
+		if err == errNotCached {
+			countMissed(toLang, send)
+			how = "not cached"
+			translated, err = send, nil
+		} else if errors.As(err, &rej) { // it's in the summary already, see segments.go
+			how = "rejected"
+			translated, err = send, nil
+		}
+		failPage(err)
+		if refine { // a page that's worth paying for twice
+			translated, err = refineText(fromLang, toLang, send, translated, where)
+			failPage(err)
+		}
+	}
+	received := translated
+	translated = pii.unmask(translated)
 	// a bunch of regexs to fix other broken stuff
 	reg = regexp.MustCompile(` (\*\*) ([A-za-z0-9]+) (\*\*)`) // fix bolds (**foo**)
 	translated = string(reg.ReplaceAll([]byte(translated), []byte(" $1$2$3")))
@@ -83,6 +140,7 @@ func xl(fromLang string, toLang string, xlate string) string {
 	translated = string(reg.ReplaceAll([]byte(translated), []byte("$1$2 video")))
 	reg = regexp.MustCompile(`({{)(<)[ ]{1,3}([yY]outube)`) // fix youtube shortcodes
 	translated = string(reg.ReplaceAll([]byte(translated), []byte("$1$2 youtube")))
+	translated = repairShortcodes(translated) // and any other shortcode
 	// Now it's time to go back and replace all the fucked up urls ...
 	reg = regexp.MustCompile(`] \([-a-zA-Z0-9@:%._\+~#=\/ ]{1,256}\)`)
 	for x := 0; x < len(foundUrls); x++ {
@@ -94,60 +152,278 @@ func xl(fromLang string, toLang string, xlate string) string {
 		t := []byte(translated)
 		translated = fmt.Sprintf("%s(%s%s", string(t[0:tmp[0]+1]), string(foundUrls[x][2:]), (string(t[tmp[1]:])))
 	}
+	translated = hookLinks(xlate, translated)
+	translated = runFixers(fromLang, toLang, xlate, translated)
+	translated = runFilters("post", fromLang, toLang, translated)
+	rememberTranslation(toLang, xlate, translated)
+	logSegment(where, toLang, how, xlate, send, received, translated)
 	return translated
 }
 
+// what actually goes to the provider for xlate, and what to put back
+// afterwards
+func maskSegment(fromLang string, toLang string, xlate string) (string, masker) {
+	send := runFilters("pre", fromLang, toLang, xlate)
+	var pii masker
+	send = pii.mask(send, placeholderRe, nil)         // __3__ (bold 3) of its own, so it's not taken for one of ours
+	send = pii.mask(send, htmlComment, hiddenComment) // and comments
+	send = pii.maskShortcuts(send, toLang)            // <kbd> and all, before the tags are taken apart
+	send = pii.maskTags(send, fromLang, toLang)       // raw HTML, all but the alt text
+	// reference labels, before anything in them is
+	send = pii.maskReferences(send)
+	if conf.Privacy.MaskPII { // keep emails, phone numbers and keys to ourselves
+		send = pii.maskPII(send)
+	}
+	send = pii.maskCode(send) // paths, flags and the like
+	send = pii.maskVersions(send)
+	return send, pii
+}
+
 // walk through the front matter, etc. and translate stuff
 func doXlate(from string, lang string, readFile string, writeFile string) {
-	file, err := os.Open(readFile)
-	checkError(err)
-	defer file.Close()
-	xfile, err := os.Create(writeFile)
+	started := time.Now()
+	defer func() { countElapsed(lang, time.Since(started)) }()
+	src, err := files.ReadFile(readFile)
 	checkError(err)
-	defer xfile.Close()
+	src = bytes.TrimPrefix(src, bom) // a BOM would hide the first ---
+	checkError(checkFrontMatter(readFile, src))
+	bump := conf.Lastmod.Bump && exists(writeFile) // a refresh, not a first translation
+	var xfile bytes.Buffer
+	refine := inRefinedSection(readFile)
+	prefetchPage(from, lang, readFile, writeFile, src, bump, refine)
+	var done map[string]string
+	if workerCount() > 1 {
+		done = prefetchSegments(from, lang, readFile, writeFile, src, bump, refine)
+	}
+	xlateLines(&xfile, from, lang, readFile, writeFile, src, bump, func(text string, where string) string {
+		translated, ok := done[text]
+		if !ok {
+			translated = xlAt(from, lang, text, where, refine)
+		}
+		checkSafety(readFile, lang, text, translated)
+		checkShortcodes(readFile, lang, translated)
+		return translated
+	})
+	if conf.Alternates.Mode == "shortcode" {
+		xfile.WriteString("\n" + alternatesShortcode(lang, readFile) + "\n")
+	}
+	checkError(loadCache().save()) // once a file, not once a line
+	out := xfile.Bytes()
+	failPage(checkNumericFields(writeFile, src, out)) // before there's anything on disk
+	checkError(writePending(writeFile, out))          // moved into place once it's all there
+	checkSummary(writeFile, src, out)
+}
+
+// the page a line at a time, written to xfile, with xl translating each
+// segment (the text and the file:line it's from)
+func xlateLines(xfile io.StringWriter, from string, lang string, readFile string, writeFile string, src []byte, bump bool, xl func(text string, where string) string) {
 	head := false
+	lineNo := 0
 	code := false
-	scanner := bufio.NewScanner(file)
+	var fc fence      // the fence that opened the current code block
+	indented := false // inside a 4-space/tab indented code block
+	inList := false   // indented lines in a list are list content, not code
+	prevBlank := true
+	comment := false   // inside an HTML comment
+	commentTr := false // and it's one we translate
+	scBody := ""       // inside this shortcode, and its body is code
+	// very long pages can be translated only up to a word budget
+	partial := conf.Partial.WordBudget > 0 && bodyWords(src) > conf.Partial.WordBudget
+	words := 0
+	cut := false   // past the budget, the rest stays as it is
+	assetKey := "" // the image field whose list we're in
+	title := ""    // translated, for a slug if there isn't one
+	hasSlug := false
+	tr := func(text string) string {
+		return xl(text, fmt.Sprintf("%s:%d", readFile, lineNo))
+	}
+	labels := refLabels(src) // shortcut reference links, see refs.go
+	xlBody := func(text string, where string) string {
+		return xl(fullReferences(text, labels), where)
+	}
+	trBody := func(text string) string {
+		return xlBody(text, fmt.Sprintf("%s:%d", readFile, lineNo))
+	}
+	parsed := conf.Markdown.Parser == "goldmark" && !partial // the body's parsed, see markdown.go
+	if parsed && frontMatterEnd(src) < 0 {
+		xlateMarkdown(xfile, src, 1, readFile, xlBody)
+		return
+	}
+	para := &paragraph{w: xfile, tr: func(text string, at int) string {
+		return xlBody(text, fmt.Sprintf("%s:%d", readFile, at))
+	}}
+	xfile = para // whatever's written next ends the paragraph
+	defer para.flush()
+	var block *blockScalar // a multi line front matter value we're in
+	scanner := bufio.NewScanner(bytes.NewReader(src))
 	for scanner.Scan() {
 		ln := scanner.Text()
-		if strings.HasPrefix(ln, "{{") {
+		lineNo++
+		blank := strings.TrimSpace(ln) == ""
+		if block != nil {
+			if block.takes(ln) {
+				block.lines = append(block.lines, ln)
+				continue
+			}
+			at := lineNo
+			lineNo = block.line
+			value, first := block.translate(tr)
+			lineNo = at
+			if block.key == "title" {
+				title = first
+			}
+			xfile.WriteString(value)
+			block = nil
+		}
+		if !head && !code {
+			if isIndentedCode(ln) && (indented || (prevBlank && !inList)) {
+				// classic indented code block, leave it alone
+				xfile.WriteString(ln + "\n")
+				indented = true
+				prevBlank = false
+				continue
+			}
+			if !blank {
+				indented = false
+				if listItem.MatchString(ln) {
+					inList = true
+				} else if !isIndentedCode(ln) {
+					inList = false
+				}
+			}
+			prevBlank = blank
+		}
+		if scBody != "" { // not prose, leave it alone
+			xfile.WriteString(ln + "\n")
+			if closesShortcode(ln, scBody) {
+				scBody = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(ln, "{{") && !code { // in a fence it's an example, not a shortcode
+			if !head && !cut {
+				ln, scBody = shortcodeLine(ln, tr) // only the arguments that are prose
+			}
+			xfile.WriteString(ln + "\n")
+			continue
+		}
+		if code && fc.closes(ln) { // end of fenced code
 			xfile.WriteString(ln + "\n")
+			code = false
 			continue
 		}
-		if strings.HasPrefix(ln, "```") { // deal with in-line code
+		if f, ok := openFence(ln); ok && !code { // deal with fenced code
 			xfile.WriteString(ln + "\n")
-			code = !code
+			fc = f
+			code = true
 			continue
 		}
 		if code { // I don't translate code!
 			xfile.WriteString(ln + "\n")
 			continue
 		}
-		if string(ln) == "---" { // start and end of front matter
+		if !head && !comment && opensComment(ln) {
+			comment = true
+			commentTr = translatableComment(ln)
+		}
+		if comment { // comments stay as they are, unless they're for people
+			comment = !strings.Contains(ln, "-->")
+			if commentTr && !cut {
+				ln = translateComment(ln, tr)
+			}
+			xfile.WriteString(ln + "\n")
+			continue
+		}
+		if (lineNo == 1 && strings.TrimRight(ln, " \r") == "---") || (head && isFrontMatterEnd(ln)) { // start and end of front matter
+			if head && partial {
+				xfile.WriteString("partial_translation: true\n")
+			}
+			if head && conf.Alternates.Mode == "front_matter" {
+				xfile.WriteString(alternatesFrontMatter(lang, readFile, writeFile))
+			}
+			if head && bump { // there wasn't one to update
+				xfile.WriteString(lastmodLine())
+				bump = false
+			}
+			if head {
+				xfile.WriteString(injectedFrontMatter(lang, readFile, src))
+				xfile.WriteString(noindexFrontMatter())
+			}
+			if head && conf.Pages.TranslateSlugs && !hasSlug && slugify(title) != "" {
+				xfile.WriteString("slug: " + slugify(title) + "\n")
+			}
 			xfile.WriteString(ln + "\n")
 			head = !head
+			prevBlank = true // the body starts a block, like after a blank line
+			if !head && parsed {
+				body := src[frontMatterEnd(src):]
+				if nl := bytes.IndexByte(body, '\n'); nl >= 0 {
+					xlateMarkdown(xfile, body[nl+1:], lineNo+1, readFile, xlBody)
+				}
+				return
+			}
 		} else if !head {
+			if cut {
+				xfile.WriteString(ln + "\n")
+				continue
+			}
+			words += len(strings.Fields(ln))
+			if partial && blank && words >= conf.Partial.WordBudget {
+				// stop at a paragraph break and say so
+				xfile.WriteString("\n*" + partialMarker(lang) + "*\n\n")
+				cut = true
+				continue
+			}
 			if strings.HasPrefix(ln, "!") { // translate the ALT-TEXT not the image path
 				bar := strings.Split(ln, "]")
 				desc := strings.Split(bar[0], "[")
-				translated := xl(from, lang, desc[1])
-				xfile.WriteString("![" + translated + "]" + bar[1] + "\n")
+				translated := tr(desc[1])
+				xfile.WriteString("![" + translated + "]" + translateImageTitle(bar[1], tr) + "\n")
 			} else { // blank lines and everything else
 				if ln == "" { // handle blank lines.
 					xfile.WriteString("\n")
+				} else if ln == "---" { // a horizontal rule, nothing to translate
+					xfile.WriteString(ln + "\n")
+				} else if isLinkDefinition(ln) { // [id]: https://..., a link not a sentence
+					xfile.WriteString(ln + "\n")
+				} else if quoted, ok := translateQuote(ln, trBody); ok { // not the > or a callout's [!note]
+					xfile.WriteString(quoted + "\n")
+				} else if caption, ok := translateCaption(ln, tr); ok { // just the caption, not its markup
+					xfile.WriteString(caption + "\n")
+				} else if summaryDivider.MatchString(ln) { // the summary ends mid paragraph
+					xfile.WriteString(translateAroundDivider(ln, tr) + "\n")
+				} else if conf.Paragraphs.Join && joinable(ln) { // translated when the paragraph ends
+					para.add(ln, lineNo)
 				} else { // everything else
-					translated := xl(from, lang, ln)
+					translated := trBody(ln)
 					xfile.WriteString(translated + "\n")
 				}
 			}
 		} else { // handle header fields
 			headString := strings.Split(ln, ":")
-			if headString[0] == "title" { // title
-				translated := xl(from, lang, headString[1])
+			remapped, key, asset := assetLine(ln, lang, assetKey)
+			assetKey = key
+			if asset { // image paths for this language's build
+				xfile.WriteString(remapped + "\n")
+			} else if numericField.MatchString(ln) { // numbers are never translated
+				xfile.WriteString(ln + "\n")
+			} else if b, ok := openBlock(headString[0], strings.Join(headString[1:], ":"), lineNo+1); ok && (headString[0] == "title" || headString[0] == "description") {
+				block = b // translated as a whole once we're past it
+			} else if headString[0] == "title" { // title
+				translated := yamlSafe(tr(headString[1]))
+				title = unquote(translated)
 				xfile.WriteString(headString[0] + ": " + translated + "\n")
+			} else if headString[0] == "slug" && conf.Pages.TranslateSlugs {
+				hasSlug = true
+				xfile.WriteString("slug: " + translateSlug(headString[1], tr) + "\n")
 			} else if headString[0] == "description" { // description
-				translated := xl(from, lang, headString[1])
+				translated := yamlSafe(tr(headString[1]))
 				xfile.WriteString(headString[0] + ": " + translated + "\n")
+			} else if headString[0] == "lastmod" && bump {
+				xfile.WriteString(lastmodLine())
+				bump = false
+			} else if noindexKey(headString[0]) { // ours go in at the end
+				continue
 			} else { // all other header fields left as-is
 				xfile.WriteString(ln + "\n")
 			}
@@ -156,8 +432,188 @@ func doXlate(from string, lang string, readFile string, writeFile string) {
 	if err := scanner.Err(); err != nil {
 		log.Fatal(err)
 	}
-	xfile.Close()
-	file.Close()
+}
+
+// how many words are in the body, not counting front matter
+func bodyWords(src []byte) int {
+	if fm := frontMatterEnd(src); fm >= 0 {
+		src = src[fm:]
+		if nl := bytes.IndexByte(src, '\n'); nl >= 0 {
+			src = src[nl+1:]
+		} else {
+			src = nil
+		}
+	}
+	return len(strings.Fields(string(src)))
+}
+
+// the note at the end of a partial translation telling people the rest
+// is still in the original language. The default's English, whatever
+// the site's written in, so that's what it's translated from.
+func partialMarker(lang string) string {
+	if m, ok := conf.Partial.Marker[lang]; ok {
+		return m
+	}
+	marker := "This page is only partly translated, the rest continues in the original language below."
+	if lang == "en" {
+		return marker
+	}
+	return xl("en", lang, marker)
+}
+
+var bom = []byte("\xef\xbb\xbf")
+
+// YAML front matter ends with --- but Hugo takes ... too
+func isFrontMatterEnd(ln string) bool {
+	ln = strings.TrimRight(ln, " \r")
+	return ln == "---" || ln == "..."
+}
+
+// find where the closing front matter delimiter starts, or -1 if there
+// isn't any front matter
+func frontMatterEnd(src []byte) int {
+	skip := 0
+	if bytes.HasPrefix(src, bom) {
+		skip = len(bom)
+		src = src[skip:]
+	}
+	if !bytes.HasPrefix(src, []byte("---\n")) && !bytes.HasPrefix(src, []byte("---\r\n")) {
+		return -1
+	}
+	for pos := bytes.IndexByte(src, '\n') + 1; pos < len(src); {
+		end := bytes.IndexByte(src[pos:], '\n')
+		if end < 0 {
+			end = len(src) - pos
+		}
+		if isFrontMatterEnd(string(src[pos : pos+end])) {
+			return pos + skip
+		}
+		pos += end + 1
+	}
+	return -1
+}
+
+// front matter has to be the very first thing in the file, and it has to
+// be closed. Better to say so than to translate half a header.
+func checkFrontMatter(name string, src []byte) error {
+	src = bytes.TrimPrefix(src, bom)
+	for i, ln := range strings.Split(string(src), "\n") {
+		ln = strings.TrimRight(ln, " \r")
+		if ln == "" {
+			continue
+		}
+		if ln == "---" && i > 0 {
+			return fmt.Errorf("%s: front matter has to start on line 1, found it on line %d", name, i+1)
+		}
+		break
+	}
+	if bytes.HasPrefix(src, []byte("---")) && frontMatterEnd(src) < 0 {
+		return fmt.Errorf("%s: front matter is never closed with --- or ...", name)
+	}
+	return nil
+}
+
+// a code fence, ``` or ~~~ (or longer), and the blockquotes (>) and list
+// items it's in, which the line that closes it is in too
+type fence struct {
+	char   byte
+	length int
+	quotes int // how many > in front of it
+	indent int // how far in the list items it's in put it
+}
+
+// up to 3 spaces of indentation, as CommonMark allows in front of a fence
+// or a > (4 would make it indented code)
+func trimIndent(ln string) (string, bool) {
+	n := 0
+	for n < len(ln) && ln[n] == ' ' {
+		n++
+	}
+	return ln[n:], n <= 3
+}
+
+// take quotes > markers off the front of a line, and whether they were
+// all there
+func stripQuotes(ln string, quotes int) (string, bool) {
+	for i := 0; i < quotes; i++ {
+		rest, ok := trimIndent(ln)
+		if !ok || !strings.HasPrefix(rest, ">") {
+			return ln, false
+		}
+		ln = strings.TrimPrefix(rest[1:], " ")
+	}
+	return ln, true
+}
+
+// does this line open a fenced code block? CommonMark says 3 or more
+// backticks or tildes, and a backtick fence's info string can't have a
+// backtick in it. It can be in a blockquote or a list item.
+func openFence(ln string) (fence, bool) {
+	var f fence
+	for {
+		rest, ok := trimIndent(ln)
+		if ok && strings.HasPrefix(rest, ">") {
+			ln = strings.TrimPrefix(rest[1:], " ")
+			f.quotes++
+			f.indent = 0 // the list items outside the quote aren't on the closing line
+			continue
+		}
+		if m := listItem.FindString(ln); m != "" {
+			ln = ln[len(m):]
+			f.indent += len(m)
+			continue
+		}
+		break
+	}
+	ln, ok := trimIndent(ln)
+	if !ok || len(ln) < 3 || (ln[0] != '`' && ln[0] != '~') {
+		return fence{}, false
+	}
+	n := 0
+	for n < len(ln) && ln[n] == ln[0] {
+		n++
+	}
+	if n < 3 {
+		return fence{}, false
+	}
+	if ln[0] == '`' && strings.Contains(ln[n:], "`") {
+		return fence{}, false
+	}
+	f.char, f.length = ln[0], n
+	return f, true
+}
+
+// does this line close the fence? In the same blockquotes and list items,
+// the same character, at least as long, and nothing but whitespace after
+// it.
+func (f fence) closes(ln string) bool {
+	ln, ok := stripQuotes(ln, f.quotes)
+	if !ok {
+		return false
+	}
+	for i := 0; i < f.indent && strings.HasPrefix(ln, " "); i++ {
+		ln = ln[1:]
+	}
+	ln, ok = trimIndent(ln)
+	if !ok {
+		return false
+	}
+	n := 0
+	for n < len(ln) && ln[n] == f.char {
+		n++
+	}
+	return n >= f.length && strings.TrimSpace(ln[n:]) == ""
+}
+
+// list items start with -, *, + or 1. (or 1)) followed by a space
+var listItem = regexp.MustCompile(`^ {0,3}([-*+]|[0-9]{1,9}[.)])( |\t|$)`)
+
+// is this line indented enough to be part of an indented code block?
+func isIndentedCode(ln string) bool {
+	if strings.TrimSpace(ln) == "" {
+		return false
+	}
+	return strings.HasPrefix(ln, "    ") || strings.HasPrefix(ln, "\t")
 }
 
 // is a value in the array?
@@ -170,76 +626,152 @@ func isValueInList(value string, list []string) bool { // Test Written
 	return false
 }
 
-// future work for automagically translating all files.
-func getFile(from string, path string, lang string) {
-	thisDir, err := os.ReadDir(path)
-	checkError(err)
-	for _, f := range thisDir {
-		if f.IsDir() {
-			if f.Name() == "images" {
-				continue
-			}
-			//fmt.Println("going into ", path + "/" + f.Name())
-			getFile(from, path+"/"+f.Name(), lang) // fucking hell, recursion!
-		} else {
-			if strings.Split(f.Name(), ".")[0] == "_index" || strings.Split(f.Name(), ".")[0] == "index" {
-				fromFile := fmt.Sprintf("%s/%s.%s.md", path, strings.Split(f.Name(), ".")[0], from)
-				toFile := fmt.Sprintf("%s/%s.%s.md", path, strings.Split(f.Name(), ".")[0], lang)
-				// fmt.Println("From: ", fromFile)
-				// fmt.Println(toFile)
-				_, err := os.Stat(toFile)
-				if !os.IsNotExist(err) {
-					if !(strings.Split(f.Name(), ".")[0] == "_index") {
-						addReadingTime(fromFile)
-						addReadingTime(toFile)
-					}
-					// fmt.Printf("Already translated:\t %s/index.%s.md\n", path, lang)
-					continue
-				}
-				addReadingTime(fromFile) // get the reading time first.
-				// fmt.Printf("Found a file to translate:\t %s/%s\n", path, f.Name())
-				fmt.Printf("Translating:\t %s\nto: \t\t%s\n", fromFile, toFile)
-				doXlate(from, lang, fromFile, toFile)
-				// }
-				continue
-			}
-		}
-	}
-}
-
 func addReadingTime(file string) {
 	// fmt.Println("Reading: ", file)
-	f, err := os.ReadFile(file)
+	f, err := files.ReadFile(file)
 	if strings.Index(string(f), "reading_time:") > 0 {
 		return
 	}
 	checkError(err)
 	estimation := readingtime.Estimate(string(f))
-	fm := strings.LastIndex(string(f), "---")
+	fm := frontMatterEnd(f)
+	if fm < 0 { // no front matter to put it in
+		return
+	}
 	newArt := f[:fm]
-	fw, err := os.Create(file)
-	checkError(err)
-	defer fw.Close()
+	var fw strings.Builder
 	fw.WriteString(string(newArt))
 	mins := int(estimation.Duration.Minutes())
 	dur := ""
 	if mins > 1 {
-	dur = fmt.Sprintf("reading_time: %d minutes\n", mins)
+		dur = fmt.Sprintf("reading_time: %d minutes\n", mins)
 	} else if mins == 1 {
-	dur = fmt.Sprintf("reading_time: %d minute\n", mins)
+		dur = fmt.Sprintf("reading_time: %d minute\n", mins)
 	} else {
 	}
 	fw.WriteString(dur)
 	fw.WriteString(string(f[fm:]))
-	fw.Close()
+	checkError(files.WriteFile(file, []byte(fw.String())))
 }
 
 func main() {
-	fromLang := "en"
-	langs := [4]string{"nl", "fr", "de", "es"} // only doing these four languages right now
-	dir := os.Args[1]                          // only doing a directory passed in
-	for x := 0; x < len(langs); x++ {
-		lang := langs[x]
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "status":
+			statusCmd(os.Args[2:])
+			return
+		case "lint":
+			lintCmd(os.Args[2:])
+			return
+		case "cache":
+			cacheCmd(os.Args[2:])
+			return
+		case "tm":
+			tmCmd(os.Args[2:])
+			return
+		case "diff":
+			diffCmd(os.Args[2:])
+			return
+		case "compare":
+			compareCmd(os.Args[2:])
+			return
+		case "rollback":
+			rollbackCmd(os.Args[2:])
+			return
+		case "history":
+			historyCmd(os.Args[2:])
+			return
+		case "config":
+			configCmd(os.Args[2:])
+			return
+		case "analyze":
+			analyzeCmd(os.Args[2:])
+			return
+		case "preview":
+			previewCmd(os.Args[2:])
+			return
+		}
+	}
+	configFile := flag.String("config", defaultConfigFile, "config file")
+	flag.StringVar(&profile, "profile", profile, "config profile to use")
+	hidden := flag.Bool("hidden", false, "translate headless and never rendered pages too")
+	summaryJSON := flag.String("summary-json", "", "also write the end of run summary to this file as JSON")
+	transcriptFile := flag.String("transcript", "", "log every segment translated, and where it came from, to this file")
+	transcriptVerbose := flag.Bool("transcript-verbose", false, "include what was sent to the API and what came back in the transcript")
+	redact := flag.Bool("redact", false, "leave emails, phone numbers and keys out of the transcript")
+	flag.BoolVar(&force, "force", false, "overwrite translations that were edited by hand")
+	flag.BoolVar(&respectLocks, "respect-locks", true, "never overwrite translations with translation: manual or notranslate: true")
+	batch := flag.Bool("batch", false, "translate everything a directory needs in one Google batch job first")
+	providerName := flag.String("provider", "", "use this provider instead of the config's, noop to change nothing")
+	flag.IntVar(&workers, "workers", 0, "pages, and segments of a page, to translate at the same time (default from the config)")
+	flag.StringVar(&sampleSpec, "sample", "", "only translate a random sample of the pages that need it, like 5% or 20")
+	flag.Int64Var(&sampleSeed, "seed", 1, "which random sample --sample takes")
+	flag.BoolVar(&cacheReadonly, "cache-readonly", false, "only use translations that are already cached, and say what the rest would cost")
+	flag.BoolVar(&strict, "strict", false, "skip pages with shortcodes, fences or HTML the translator can't protect")
+	flag.BoolVar(&resume, "resume", false, "carry on with the last run, if it was interrupted, instead of starting again")
+	flag.Parse()
+	resumeRun()
+	conf = loadConfig(*configFile)
+	files = siteFilesFor(files) // the modules' content where it's mounted
+	if workers > 0 {
+		conf.Workers = workers
+	}
+	if *providerName != "" {
+		conf.Translation.Provider = *providerName
+	}
+	if offline(conf.Translation.Provider) {
+		conf.Refine.Sections = nil
+		conf.Translation.Fallbacks = nil
+	}
+	if *hidden {
+		conf.Pages.TranslateHidden = true
+	}
+	if *transcriptFile != "" {
+		openTranscript(*transcriptFile, *transcriptVerbose, *redact)
+		defer closeTranscript()
+	}
+	defer closeFilters()
+	startRun()
+	checkLanguages()
+	warnHugoLanguages()
+	fromLang := conf.Source
+	dir := flag.Arg(0) // only doing a directory passed in
+	if dir == "" {
+		fmt.Println("usage: translate [--config translator.json] <file or directory>")
+		os.Exit(1)
+	}
+	fi, err := os.Stat(dir)
+	checkError(err)
+	if fi.IsDir() { // every bundle, every language
+		if *batch {
+			batchPrefetch(fromLang, dir)
+		}
+		translateDir(fromLang, dir)
+		for _, lang := range conf.Languages {
+			if sampleSpec != "" { // the sample is of dir's pages
+				break
+			}
+			translateMounts(fromLang, lang)
+			translateData(fromLang, lang)
+			translateOGImages(fromLang, lang)
+			translateParams(fromLang, lang)
+		}
+		resolveSlugCollisions(fromLang, dir)
+		indexReviewed(fromLang, sourcePages(fromLang, dir))
+		checkLinks(fromLang, sourcePages(fromLang, dir))
+		reportConsistency()
+		printSummary(*summaryJSON)
+		sampleEstimate()
+		printRunID()
+		finishRun(nil)
+		return
+	}
+	if sampleSpec != "" {
+		checkError(fmt.Errorf("--sample takes a directory, not a single file"))
+	}
+	syncTranslationKey(fromLang, dir)
+	for x := 0; x < len(conf.Languages); x++ {
+		lang := conf.Languages[x]
 		// fmt.Print("Translating: \n" + dir + "\nTo: ")
 		// switch lang {
 		// case "es":
@@ -251,19 +783,62 @@ func main() {
 		// case "nl":
 		// 	fmt.Println("Dutch")
 		// }
-		fi, err := os.Stat(dir)
-		checkError(err)
 		switch mode := fi.Mode(); {
-		case mode.IsDir():
-			// do directory stuff
-			getFile(fromLang, dir, lang)
 		case mode.IsRegular(): // we're just doing one file
+			if !translatedInto(dir, lang) {
+				fmt.Printf("Skipping:\t %s (not translated into %s, see pages.languages)\n", dir, lang)
+				continue
+			}
 			pt := strings.Split(dir, "/")
 			fn := strings.Split(pt[len(pt)-1], ".")
 			path := strings.TrimRight(dir, pt[len(pt)-1])
-			writeFile := fmt.Sprintf("%s%s.%s.%s", path, fn[0], lang, fn[len(fn)-1])
-			doXlate(fromLang, lang, dir, writeFile)
+			writeFile := fmt.Sprintf("%s%s.%s.%s", path, fn[0], fileCode(lang), fn[len(fn)-1])
+			if dirLayout() {
+				writeFile = targetFor(dir, fromLang, lang)
+				checkError(files.MkdirAll(filepath.Dir(writeFile)))
+			}
+			if reason := skipReason(dir); reason != "" {
+				fmt.Printf("Skipping:\t %s (%s)\n", dir, reason)
+				countSkipped(lang)
+				continue
+			}
+			if strictSkip(dir) {
+				countSkipped(lang)
+				continue
+			}
+			if locked(writeFile) {
+				fmt.Printf("Skipping:\t %s (locked, translation: manual)\n", writeFile)
+				countSkipped(lang)
+				continue
+			}
+			if doneBefore(writeFile) {
+				fmt.Printf("Skipping:\t %s (done before run %s was interrupted)\n", writeFile, runID)
+				countSkipped(lang)
+				continue
+			}
+			if !force && newerTarget(dir, writeFile) {
+				fmt.Printf("Skipping:\t %s (newer than %s, edited by hand? --force to overwrite)\n", writeFile, dir)
+				countSkipped(lang)
+				continue
+			}
+			warnLint(dir)
+			state.mark(dir, writeFile, lang, "failed")
+			status := translatePage(fromLang, lang, dir, writeFile)
+			if status == "" {
+				continue
+			}
+			commitTranslation(writeFile)
+			state.mark(dir, writeFile, lang, status)
+			if status == "done" {
+				countCreated(lang)
+			}
 		}
 	}
-
+	resolveSlugCollisions(fromLang, slugSection(dir))
+	indexReviewed(fromLang, []string{dir})
+	checkLinks(fromLang, []string{dir})
+	reportConsistency()
+	printSummary(*summaryJSON)
+	printRunID()
+	finishRun(nil)
 }
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFences(t *testing.T) {
+	for _, c := range []struct {
+		open, ln string
+		closes   bool
+	}{
+		{"```", "```", true},
+		{"```", "````", true},
+		{"````", "```", false},
+		{"```", "~~~", false},
+		{"```", "   ```", true},
+		{"```", "    ```", false}, // indented code, not a fence
+		{"```", "- ```", false},
+		{"```", "> ```", false},
+		{"> ```", "> ```", true},
+		{"> ```", "```", false},
+		{"- ```", "  ```", true},
+		{"1. ```", "   ```", true},
+		{"> - ```", ">   ```", true},
+	} {
+		f, ok := openFence(c.open)
+		if !ok {
+			t.Errorf("%q doesn't open a fence", c.open)
+			continue
+		}
+		if got := f.closes(c.ln); got != c.closes {
+			t.Errorf("%q closes %q: %v, want %v", c.ln, c.open, got, c.closes)
+		}
+	}
+	for _, ln := range []string{"    ```", "``", "``` a`b", "\t```"} {
+		if _, ok := openFence(ln); ok {
+			t.Errorf("%q opens a fence", ln)
+		}
+	}
+}
+
+func TestFencesInPages(t *testing.T) {
+	conf = defaultConfig()
+	for _, c := range []struct {
+		name, src, want string
+	}{
+		{
+			"a list item in the code",
+			"```\n- ```\ncode line\n```\n\nAfter.\n",
+			"```\n- ```\ncode line\n```\n\n<After.>\n",
+		},
+		{
+			"an indented fence in the code",
+			"```\ncode\n    ```\nmore code\n```\n\nAfter.\n",
+			"```\ncode\n    ```\nmore code\n```\n\n<After.>\n",
+		},
+		{
+			"in a blockquote",
+			"> ```\n> x = 1\n> ```\n\nAfter.\n",
+			"> ```\n> x = 1\n> ```\n\n<After.>\n",
+		},
+		{
+			"in a list",
+			"1. Step one:\n   ```sh\n   make\n   ```\n2. Step two.\n",
+			"<1. Step one:>\n   ```sh\n   make\n   ```\n<2. Step two.>\n",
+		},
+	} {
+		if got := xlatePage(t, c.src); got != c.want {
+			t.Errorf("%s:\n%s\nwant\n%s", c.name, got, c.want)
+		}
+	}
+}
+
+func TestIndentedCode(t *testing.T) {
+	conf = defaultConfig()
+	for _, c := range []struct {
+		name, src, want string
+	}{
+		{
+			"after the front matter",
+			"---\ntitle: Hi\n---\n    indented code here\n\nText.\n",
+			"---\ntitle: < Hi>\n---\n    indented code here\n\n<Text.>\n",
+		},
+		{
+			"after a blank line",
+			"Text.\n\n    indented code here\n",
+			"<Text.>\n\n    indented code here\n",
+		},
+		{
+			"a paragraph's continuation",
+			"Text\n    more text.\n",
+			"<Text>\n<    more text.>\n",
+		},
+		{
+			"in a list",
+			"- Item\n\n    more of the item.\n",
+			"<- Item>\n\n<    more of the item.>\n",
+		},
+	} {
+		if got := xlatePage(t, c.src); got != c.want {
+			t.Errorf("%s:\n%q\nwant\n%q", c.name, got, c.want)
+		}
+	}
+}
+
+// a provider that says which language it was told it was given
+type fromProvider struct{}
+
+func (fromProvider) Name() string {
+	return "from"
+}
+
+func (fromProvider) Translate(texts []string, from string, to string) ([]string, error) {
+	var out []string
+	for _, t := range texts {
+		out = append(out, from+": "+t)
+	}
+	return out, nil
+}
+
+func TestPartialMarker(t *testing.T) {
+	memSite(t, nil)
+	conf.Source = "de"
+	current = fromProvider{}
+	if got := partialMarker("fr"); !strings.HasPrefix(got, "en: ") || strings.Contains(got, "English") {
+		t.Errorf("partialMarker = %q", got)
+	}
+	if got := partialMarker("en"); strings.HasPrefix(got, "en: ") {
+		t.Errorf("the English marker was translated into English: %q", got)
+	}
+	conf.Partial.Marker = map[string]string{"fr": "La suite est en allemand."}
+	if got := partialMarker("fr"); got != "La suite est en allemand." {
+		t.Errorf("partialMarker = %q", got)
+	}
+}
+
+func TestFrontMatterEnd(t *testing.T) {
+	for _, c := range []struct {
+		src  string
+		want int
+	}{
+		{"---\ntitle: Hi\n---\nBody.\n", 14},
+		{"---\ntitle: Hi\n...\nBody.\n", 14},
+		{"---\r\ntitle: Hi\r\n---\r\nBody.\r\n", 16},
+		{"\xef\xbb\xbf---\ntitle: Hi\n---\n", 17},
+		{"---\ntitle: Hi\n--- \n", 14},
+		{"---\ntitle: Hi\n", -1},
+		{"Body.\n---\n", -1},
+		{"----\ntitle: Hi\n---\n", -1},
+	} {
+		if got := frontMatterEnd([]byte(c.src)); got != c.want {
+			t.Errorf("frontMatterEnd(%q) = %d, want %d", c.src, got, c.want)
+		}
+	}
+}
+
+func TestCheckFrontMatter(t *testing.T) {
+	for _, c := range []struct {
+		src string
+		ok  bool
+	}{
+		{"---\ntitle: Hi\n---\nBody.\n", true},
+		{"---\ntitle: Hi\n...\nBody.\n", true},
+		{"Body.\n\n---\n\nMore.\n", true}, // a thematic break
+		{"\n---\ntitle: Hi\n---\n", false},
+		{"---\ntitle: Hi\n\nBody.\n", false},
+	} {
+		if err := checkFrontMatter("a.md", []byte(c.src)); (err == nil) != c.ok {
+			t.Errorf("checkFrontMatter(%q) = %v", c.src, err)
+		}
+	}
+}
+
+func TestDotsEndTheFrontMatter(t *testing.T) {
+	conf = defaultConfig()
+	if got, want := xlatePage(t, "---\ntitle: Hi\n...\n\nBody.\n"), "---\ntitle: < Hi>\n...\n\n<Body.>\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// a provider that puts «» round what it's given, so the tests can see
+// what was sent and what wasn't
+type markProvider struct{}
+
+func (markProvider) Name() string {
+	return "mark"
+}
+
+func (markProvider) Translate(texts []string, from string, to string) ([]string, error) {
+	var out []string
+	for _, t := range texts {
+		out = append(out, "«"+t+"»")
+	}
+	return out, nil
+}
+
+func TestNothingToTranslate(t *testing.T) {
+	conf = defaultConfig()
+	for text, want := range map[string]bool{
+		"":                        true,
+		"   ":                     true,
+		"42":                      true,
+		"3.14 %":                  true,
+		"🎉 !":                     true,
+		"https://example.com/a b": false,
+		"https://example.com/a":   true,
+		"⟦T0001⟧ ⟦T0002⟧":         true,
+		"__3__":                   true,
+		"⟦T0001⟧ and more":        false,
+		"Hello":                   false,
+		"日本語":                     false,
+	} {
+		if got := nothingToTranslate(text); got != want {
+			t.Errorf("nothingToTranslate(%q) = %v, want %v", text, got, want)
+		}
+	}
+}
+
+func TestIsLiteral(t *testing.T) {
+	conf = defaultConfig()
+	literalPatterns = nil
+	defer func() { literalPatterns = nil }()
+	for text, want := range map[string]bool{
+		"true":                   true,
+		` "False" `:              true,
+		"null":                   true,
+		"#FFAA00":                true,
+		"rgba(0, 0, 0, 0.5)":     true,
+		"2024-01-02T10:00:00Z":   true,
+		"1,234,567":              true,
+		"https://gohugo.io/":     true,
+		"mailto:me@example.com":  true,
+		"me@example.com":         true,
+		"true story":             false,
+		"The color #FFAA00":      false,
+		"":                       false,
+		`""`:                     false,
+		"Released on 2024-01-02": false,
+	} {
+		if got := isLiteral(text); got != want {
+			t.Errorf("isLiteral(%q) = %v, want %v", text, got, want)
+		}
+	}
+	conf.Literals.Kinds = nil
+	conf.Literals.Patterns = []string{`[A-Z]{2,5}-\d+`}
+	literalPatterns = nil
+	if isLiteral("true") || !isLiteral("JIRA-123") {
+		t.Errorf("literals.kinds and literals.patterns aren't what's used")
+	}
+}
+
+func TestFrontMatter(t *testing.T) {
+	conf = defaultConfig()
+	for _, c := range []struct {
+		name, src, want string
+	}{
+		{
+			"title and description",
+			"---\ntitle: \"Hello\"\ndescription: A page\ndate: 2024-01-02\ntags: [a, b]\n---\n",
+			"---\ntitle: < \"Hello\">\ndescription: < A page>\ndate: 2024-01-02\ntags: [a, b]\n---\n",
+		},
+		{
+			"numbers",
+			"---\ntitle: Hi\nweight: 10\n---\n",
+			"---\ntitle: < Hi>\nweight: 10\n---\n",
+		},
+		{
+			"block scalar",
+			"---\ntitle: Hi\ndescription: >\n  A long\n  description.\n---\n",
+			"---\ntitle: < Hi>\ndescription: >\n  <A long\n  description.>\n---\n",
+		},
+	} {
+		if got := xlatePage(t, c.src); got != c.want {
+			t.Errorf("%s:\n%s\nwant\n%s", c.name, got, c.want)
+		}
+	}
+}
+
+func TestTranslateDataFile(t *testing.T) {
+	for _, c := range []struct {
+		file DataFile
+		src  string
+		want string
+	}{
+		{
+			DataFile{Path: "data/authors.yaml", Translate: []string{"bio"}},
+			"jane:\n  name: Jane Doe\n  bio: Writes the docs.\n  role: \"editor\"\n",
+			"jane:\n  name: Jane Doe\n  bio: «Writes the docs.»\n  role: \"editor\"\n",
+		},
+		{
+			DataFile{Path: "data/authors.yaml", Translate: []string{"bio"}},
+			"jane:\n  bio: |\n    Writes the docs.\n    And the blog.\n\nbob:\n  bio: 'It''s Bob.'\n",
+			"jane:\n  bio: |\n    «Writes the\n    docs. And the\n    blog.»\n\nbob:\n  bio: '«It''s Bob.»'\n",
+		},
+		{
+			DataFile{Path: "data/menu.json", Translate: []string{"label"}},
+			"{\n  \"label\": \"Get \\\"started\\\"\",\n  \"url\": \"/start/\"\n}\n",
+			"{\n  \"label\": \"«Get \\\"started\\\"»\",\n  \"url\": \"/start/\"\n}\n",
+		},
+		{
+			DataFile{Path: "data/site.toml", Translate: []string{"tagline"}},
+			"tagline = 'Fast sites'\ncount = 3\n",
+			"tagline = '«Fast sites»'\ncount = 3\n",
+		},
+		{
+			DataFile{Path: "data/tips.yaml", Translate: []string{"-"}},
+			"- Save often.\n- \"Back up, too.\"\n",
+			"- «Save often.»\n- \"«Back up, too.»\"\n",
+		},
+	} {
+		memSite(t, nil)
+		current = markProvider{}
+		if got := translateDataFile(c.file, "en", "fr", c.src); got != c.want {
+			t.Errorf("%s:\n%s\nwant\n%s", c.file.Path, got, c.want)
+		}
+	}
+}
+
+func TestCheckNumericFields(t *testing.T) {
+	src := "---\ntitle: Hi\nweight: 10\norder: -2\nimages:\n  - width: 640\n    height: 480.5\n---\n\nBody.\n"
+	for _, c := range []struct {
+		name, translated string
+		ok               bool
+	}{
+		{"the same", "---\ntitle: Salut\nweight: 10\norder: -2\nimages:\n  - width: 640\n    height: 480.5\n---\n\nCorps.\n", true},
+		{"moved about", "---\nweight: 10\ntitle: Salut\nimages:\n  - width: 640\n    height: 480.5\norder: -2\n---\n", true},
+		{"quoted", "---\ntitle: Salut\nweight: \"10\"\norder: -2\nimages:\n  - width: 640\n    height: 480.5\n---\n", false},
+		{"changed", "---\ntitle: Salut\nweight: 10\norder: -2\nimages:\n  - width: 640\n    height: 480,5\n---\n", false},
+		{"gone", "---\ntitle: Salut\nweight: 10\nimages:\n  - width: 640\n    height: 480.5\n---\n", false},
+	} {
+		if err := checkNumericFields("index.fr.md", []byte(src), []byte(c.translated)); (err == nil) != c.ok {
+			t.Errorf("%s: %v", c.name, err)
+		}
+	}
+}
+
+func TestNumericFieldsRoundTrip(t *testing.T) {
+	conf = defaultConfig()
+	src := "---\ntitle: Hi\nweight: 10\norder: -2\nimages:\n  - width: 640\n    height: 480.5\nmenu:\n  main:\n    weight: 3\n---\n\nBody.\n"
+	out := xlatePage(t, src)
+	if err := checkNumericFields("index.fr.md", []byte(src), []byte(out)); err != nil {
+		t.Errorf("%v:\n%s", err, out)
+	}
+}
+
+func TestNumericFieldsFailThePage(t *testing.T) {
+	m := memSite(t, map[string]string{
+		"content/a/index.en.md": "---\ntitle: Hi\nweight: 10\n---\n\nBody.\n",
+	})
+	conf.Failures.Then = "skip"
+	bad := []byte("---\ntitle: Salut\nweight: \"10\"\n---\n")
+	err := tryPage(func() {
+		failPage(checkNumericFields("content/a/index.fr.md", []byte(m.dump()["content/a/index.en.md"]), bad))
+	})
+	if err == nil {
+		t.Errorf("a quoted weight didn't fail the page")
+	}
+	if err := tryPage(func() { doXlate("en", "fr", "content/a/index.en.md", "content/a/index.fr.md") }); err != nil {
+		t.Errorf("doXlate: %v", err)
+	}
+	if _, ok := m.dump()["content/a/index.fr.md"+pendingSuffix]; !ok {
+		t.Errorf("a good page wasn't written")
+	}
+}
+
+func TestDataSources(t *testing.T) {
+	m := memSite(t, map[string]string{
+		"data/authors.yaml":             "- name: Ann\n",
+		"data/products/en/items.yaml":   "- title: Hi\n",
+		"data/products/en/parts.yaml":   "- title: Hi\n",
+		"data/products/fr/items.yaml":   "- title: Salut\n",
+		"data/shop/en/items.en.yaml":    "- title: Hi\n",
+		"data/products/en/notes/a.yaml": "- title: Hi\n",
+	})
+	for _, c := range []struct {
+		path, out string
+		want      map[string]string // source: where the French goes
+	}{
+		{"data/authors.yaml", "", map[string]string{"data/authors.yaml": "data/fr/authors.yaml"}},
+		{"data/authors.yaml", "data/authors.{lang}.yaml", map[string]string{"data/authors.yaml": "data/authors.fr.yaml"}},
+		{"data/products/{lang}/items.yaml", "", map[string]string{"data/products/en/items.yaml": "data/products/fr/items.yaml"}},
+		{"data/products/{lang}/*.yaml", "", map[string]string{
+			"data/products/en/items.yaml": "data/products/fr/items.yaml",
+			"data/products/en/parts.yaml": "data/products/fr/parts.yaml",
+		}},
+		{"data/shop/{lang}/items.{lang}.yaml", "", map[string]string{"data/shop/en/items.en.yaml": "data/shop/fr/items.fr.yaml"}},
+		{"data/missing/{lang}/items.yaml", "", map[string]string{}},
+	} {
+		got := map[string]string{}
+		for _, f := range dataSources(DataFile{Path: c.path, Out: c.out}, "en") {
+			got[filepath.ToSlash(f.Path)] = filepath.ToSlash(dataTarget(f, "fr"))
+		}
+		if fmt.Sprint(got) != fmt.Sprint(c.want) {
+			t.Errorf("%s: %v, want %v", c.path, got, c.want)
+		}
+	}
+	current = markProvider{}
+	conf.Data.Files = []DataFile{{Path: "data/products/{lang}/*.yaml", Translate: []string{"title"}}}
+	translateData("en", "fr")
+	if got := m.dump()["data/products/fr/parts.yaml"]; got != "- title: «Hi»\n" {
+		t.Errorf("data/products/fr/parts.yaml: %q", got)
+	}
+}
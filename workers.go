@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io"
+	"sync"
+)
+
+// how many bundles, and segments, to translate at once: workers (or
+// --workers), or adaptive.max_workers when the limiter decides how many
+// actually run
+func workerCount() int {
+	workers := conf.Workers
+	if conf.Adaptive.MaxWorkers > 0 {
+		workers = conf.Adaptive.MaxWorkers
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	return workers
+}
+
+var workers int // --workers, 0 to go by the config
+
+var (
+	segmentSlots     chan struct{} // one for each segment being translated, across every page
+	segmentSlotsOnce sync.Once
+)
+
+// with more than one worker, a page's segments are all translated first,
+// that many at a time, and then the page is put together from them in
+// order. A long post no longer waits on one line at a time.
+func prefetchSegments(from string, lang string, readFile string, writeFile string, src []byte, bump bool, refine bool) map[string]string {
+	segmentSlotsOnce.Do(func() {
+		warmUp() // a single file doesn't get it otherwise
+		segmentSlots = make(chan struct{}, workerCount())
+	})
+	type segment struct {
+		text, where string
+	}
+	var todo []segment
+	seen := map[string]bool{}
+	// the same walk through the page, keeping the segments instead
+	xlateLines(io.Discard.(io.StringWriter), from, lang, readFile, writeFile, src, bump, func(text string, where string) string {
+		if !seen[text] {
+			seen[text] = true
+			todo = append(todo, segment{text, where})
+		}
+		return text
+	})
+	done := map[string]string{}
+	var failed error // the first, for failures.then
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, s := range todo {
+		segmentSlots <- struct{}{}
+		wg.Add(1)
+		go func(s segment) {
+			defer func() { <-segmentSlots; wg.Done() }()
+			var translated string
+			err := tryPage(func() { translated = xlAt(from, lang, s.text, s.where, refine) })
+			mu.Lock()
+			if err != nil && failed == nil {
+				failed = err
+			}
+			done[s.text] = translated
+			mu.Unlock()
+		}(s)
+	}
+	wg.Wait()
+	if failed != nil {
+		panic(pageFailure{failed}) // back in the page's goroutine
+	}
+	return done
+}